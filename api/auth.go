@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/auth"
+	"github.com/megamsys/vertice/carton"
+)
+
+// authorizationRequiredHandler runs only for authenticated requests.
+type authorizationRequiredHandler func(http.ResponseWriter, *http.Request, auth.Token) error
+
+func (fn authorizationRequiredHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t, err := requestToken(r)
+	if err != nil {
+		context.AddRequestError(r, err)
+		return
+	}
+	context.AddRequestError(r, fn(w, r, t))
+}
+
+// authenticated requires a token before handing over to h, it guards the
+// handlers that aren't ours like the socket server.
+func authenticated(h http.Handler) http.Handler {
+	return authorizationRequiredHandler(func(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+		h.ServeHTTP(w, r)
+		return nil
+	})
+}
+
+// requestToken returns the token set by authTokenMiddleware. The browsers
+// can't send headers on a websocket, so the token can be a query param.
+func requestToken(r *http.Request) (auth.Token, error) {
+	if t := context.GetAuthToken(r); t != nil {
+		return t, nil
+	}
+	if q := r.URL.Query().Get("token"); q != "" {
+		t, err := validate(q, r)
+		if err != nil {
+			return nil, &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()}
+		}
+		return t, nil
+	}
+	return nil, &errors.HTTP{Code: http.StatusUnauthorized, Message: "no token provided"}
+}
+
+// ownedAssembly returns the assembly when the user of the token can act on
+// it with perm.
+func ownedAssembly(t auth.Token, id string, perm auth.Permission) (*carton.Assembly, error) {
+	asm, err := carton.NewAssembly(id, t.GetUserName(), "")
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err = checkAccess(t, asm.AccountId, asm.OrgId, perm); err != nil {
+		return nil, err
+	}
+	return asm, nil
+}
+
+// checkAccess lets the owner of an assembly in, or a member of its org
+// whose role in that org grants perm.
+func checkAccess(t auth.Token, accountId, orgId string, perm auth.Permission) error {
+	if accountId == t.GetUserName() {
+		return nil
+	}
+	if u, err := t.User(); err == nil {
+		if r, ok := u.RoleIn(orgId); ok && r.Can(perm) {
+			return nil
+		}
+	}
+	return &errors.HTTP{Code: http.StatusForbidden, Message: "assembly not owned by the user"}
+}
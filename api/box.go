@@ -1,18 +1,35 @@
 package api
 
 import (
-//	"encoding/json"
+	"encoding/json"
 	"fmt"
+	"net/http"
+
 	log "github.com/Sirupsen/logrus"
 	"github.com/googollee/go-socket.io"
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/auth"
+	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/provision"
 )
 
-func logHandler(so socketio.Socket) {
+// logRequest is the message of logInit, name is the box of the assembly whose
+// logs are streamed.
+type logRequest struct {
+	AssemblyId string `json:"assembly_id"`
+	Name       string `json:"name"`
+}
+
+func logHandler(so socketio.Socket, t auth.Token) {
 	so.On("logInit", func(msg string) {
 		var entry provision.Box
-		entry.Name = msg
+		name, err := logBoxName(t, msg)
+		if err != nil {
+			so.Emit("error", err.Error())
+			return
+		}
+		entry.Name = name
 		l, _ := provision.NewLogListener(&entry)
 		go func() {
 			so.On("logDisconnect", func(data string) {
@@ -20,7 +37,6 @@ func logHandler(so socketio.Socket) {
 				log.Debugf(cmd.Colorfy("  > [nsqd] unsub   ", "blue", "", "bold") + fmt.Sprintf("Unsubscribing from the Queue"))
 			})
 			for logbox := range l.B {
-			//	logData, _ := json.Marshal(logbox)
 				so.Emit(entry.Name, logbox)
 			}
 		}()
@@ -33,6 +49,43 @@ func logHandler(so socketio.Socket) {
 
 }
 
+// logBoxName returns the name of the box asked in the logInit message, when
+// the user of the token can read its assembly.
+func logBoxName(t auth.Token, msg string) (string, error) {
+	var lr logRequest
+	if err := json.Unmarshal([]byte(msg), &lr); err != nil || lr.AssemblyId == "" || lr.Name == "" {
+		return "", &errors.HTTP{Code: http.StatusBadRequest, Message: "logInit expects the assembly_id and the name of a box"}
+	}
+	c, err := carton.NewCarton("", lr.AssemblyId, t.GetUserName())
+	if err != nil {
+		return "", &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err = checkAccess(t, c.AccountId, c.OrgId, auth.PermRead); err != nil {
+		return "", err
+	}
+	if err = c.ToBox(); err != nil {
+		return "", err
+	}
+	for _, b := range *c.Boxes {
+		if b.Name == lr.Name || b.GetFullName() == lr.Name {
+			return lr.Name, nil
+		}
+	}
+	return "", &errors.HTTP{Code: http.StatusNotFound, Message: provision.ErrBoxNotFound.Error()}
+}
+
+// socketToken resolves the token of the socket handshake, the context of its
+// request is already cleared when the events are received.
+func socketToken(r *http.Request) (auth.Token, error) {
+	if token := r.Header.Get("Authorization"); token != "" {
+		return validate(token, r)
+	}
+	if r.Header.Get(APIKEY_HEADER) != "" {
+		return validateAPIKey(r)
+	}
+	return requestToken(r)
+}
+
 /*import (
 	"encoding/json"
 	"fmt"
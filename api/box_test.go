@@ -1,10 +1,31 @@
 package api
 
 import (
-//"github.com/megamsys/vertice/config"
-//"gopkg.in/check.v1"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	//"github.com/megamsys/vertice/config"
+	"gopkg.in/check.v1"
 )
 
+func (s *S) TestLogBoxNameNeedsTheAssembly(c *check.C) {
+	for _, msg := range []string{"vm1.megambox.com", `{"name":"vm1.megambox.com"}`, `{"assembly_id":"ASM001"}`} {
+		_, err := logBoxName(&s.token, msg)
+		e, ok := err.(*errors.HTTP)
+		c.Assert(ok, check.Equals, true)
+		c.Assert(e.Code, check.Equals, http.StatusBadRequest)
+	}
+}
+
+func (s *S) TestSocketTokenWithoutToken(c *check.C) {
+	request, err := http.NewRequest("GET", "/logs/", nil)
+	c.Assert(err, check.IsNil)
+	_, err = socketToken(request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
 /*
 func (s *S) TestAppLogShouldReturnNotFoundWhenAppDoesNotExist(c *check.C) {
 	request, err := http.NewRequest("GET", "/apps/unknown/log/?:app=unknown&lines=10", nil)
@@ -12,6 +12,7 @@ const (
 	tokenContextKey int = iota
 	errorContextKey
 	delayedHandlerKey
+	permissionContextKey
 )
 
 func Clear(r *http.Request) {
@@ -58,3 +59,15 @@ func GetDelayedHandler(r *http.Request) http.Handler {
 	}
 	return nil
 }
+
+func SetPermission(r *http.Request, p auth.Permission) {
+	context.Set(r, permissionContextKey, p)
+}
+
+// GetPermission returns the permission required by the route of the request.
+func GetPermission(r *http.Request) auth.Permission {
+	if v := context.Get(r, permissionContextKey); v != nil {
+		return v.(auth.Permission)
+	}
+	return ""
+}
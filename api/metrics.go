@@ -0,0 +1,49 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/auth"
+	"github.com/megamsys/vertice/stats"
+)
+
+// MetricsToken is the static bearer token of the Prometheus scrapers, the
+// tokens of the admins are accepted too.
+var MetricsToken string
+
+// metrics exposes the sensors and the internal counters in the Prometheus
+// text format.
+func metrics(w http.ResponseWriter, r *http.Request) error {
+	if err := canScrape(r); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", stats.ContentType)
+	return stats.WritePrometheus(w)
+}
+
+func canScrape(r *http.Request) error {
+	if MetricsToken != "" {
+		if value, err := auth.ParseToken(r.Header.Get("Authorization")); err == nil &&
+			subtle.ConstantTimeCompare([]byte(value), []byte(MetricsToken)) == 1 {
+			return nil
+		}
+	}
+	t, err := requestToken(r)
+	if err != nil {
+		return err
+	}
+	u, err := t.User()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()}
+	}
+	if !u.Can(auth.PermAdmin) {
+		return &errors.HTTP{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("user %s doesn't have the %s permission", u.Email, auth.PermAdmin),
+		}
+	}
+	return nil
+}
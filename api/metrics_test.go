@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/stats"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestMetricsExposesCounters(c *check.C) {
+	carton.MessagesReceived.Inc("vms")
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, &s.adminToken)
+	c.Assert(metrics(recorder, request), check.IsNil)
+	c.Assert(recorder.Header().Get("Content-Type"), check.Equals, stats.ContentType)
+	c.Assert(recorder.Body.String(), check.Matches, `(?s).*vertice_nsq_messages_total\{topic="vms"\} \d+.*`)
+}
+
+func (s *S) TestMetricsWithScrapeToken(c *check.C) {
+	defer func(t string) { MetricsToken = t }(MetricsToken)
+	MetricsToken = "prometheus-scrape"
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "Bearer prometheus-scrape")
+	c.Assert(metrics(httptest.NewRecorder(), request), check.IsNil)
+	request.Header.Set("Authorization", "Bearer guessed")
+	err = metrics(httptest.NewRecorder(), request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestMetricsNeedsAnAdmin(c *check.C) {
+	request, err := http.NewRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, &s.token)
+	err = metrics(httptest.NewRecorder(), request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+}
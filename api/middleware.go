@@ -14,6 +14,12 @@ import (
 	"github.com/megamsys/vertice/auth"
 )
 
+const (
+	// the headers to authenticate with the api key of the user.
+	EMAIL_HEADER  = "X-Megam-Email"
+	APIKEY_HEADER = "X-Megam-Apikey"
+)
+
 func validate(token string, r *http.Request) (auth.Token, error) {
 	t, err := Auth(token)
 	if err != nil {
@@ -22,6 +28,10 @@ func validate(token string, r *http.Request) (auth.Token, error) {
 	return t, nil
 }
 
+func validateAPIKey(r *http.Request) (auth.Token, error) {
+	return auth.CheckAPIKey(r.Header.Get(EMAIL_HEADER), r.Header.Get(APIKEY_HEADER))
+}
+
 func contextClearerMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	defer context.Clear(r)
 	next(w, r)
@@ -56,18 +66,56 @@ func errorHandlingMiddleware(w http.ResponseWriter, r *http.Request, next http.H
 }
 
 func authTokenMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	token := r.Header.Get("Authorization")
-	if token != "" {
-		t, err := validate(token, r)
-		if err != nil {
-			if err != auth.ErrInvalidToken {
-				context.AddRequestError(r, err)
-				return
-			}
-			log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
-		} else {
+	var (
+		t   auth.Token
+		err error
+	)
+	if token := r.Header.Get("Authorization"); token != "" {
+		t, err = validate(token, r)
+	} else if r.Header.Get(APIKEY_HEADER) != "" {
+		t, err = validateAPIKey(r)
+	}
+	switch err {
+	case nil:
+		if t != nil {
 			context.SetAuthToken(r, t)
 		}
+	case auth.ErrInvalidToken:
+		log.Debugf("Ignored invalid token for %s: %s", r.URL.Path, err.Error())
+	case auth.ErrTokenExpired, auth.ErrInvalidAPIKey, auth.ErrUserNotFound:
+		context.AddRequestError(r, &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()})
+		return
+	default:
+		context.AddRequestError(r, err)
+		return
+	}
+	next(w, r)
+}
+
+// authorizationMiddleware checks the user has the permission declared for
+// the route.
+func authorizationMiddleware(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	perm := context.GetPermission(r)
+	if perm == "" {
+		next(w, r)
+		return
+	}
+	t, err := requestToken(r)
+	if err != nil {
+		context.AddRequestError(r, err)
+		return
+	}
+	u, err := t.User()
+	if err != nil {
+		context.AddRequestError(r, &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()})
+		return
+	}
+	if !u.Can(perm) {
+		context.AddRequestError(r, &errors.HTTP{
+			Code:    http.StatusForbidden,
+			Message: fmt.Sprintf("user %s doesn't have the %s permission", u.Email, perm),
+		})
+		return
 	}
 	next(w, r)
 }
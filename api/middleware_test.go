@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +10,7 @@ import (
 	"github.com/megamsys/libgo/errors"
 	"github.com/megamsys/libgo/io"
 	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/auth"
 	"gopkg.in/check.v1"
 )
 
@@ -115,6 +117,131 @@ func (s *S) TestAuthTokenMiddlewareWithToken(c *check.C) {
 	c.Assert(t.GetUserName(), check.Equals, s.token.GetUserName())
 }
 
+func (s *S) TestAuthTokenMiddlewareWithUnknownToken(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Authorization", "bearer unknown")
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	c.Assert(context.GetAuthToken(request), check.IsNil)
+}
+
+func (s *S) TestAuthTokenMiddlewareWithAPIKey(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set(EMAIL_HEADER, s.token.GetUserName())
+	request.Header.Set(APIKEY_HEADER, "secret")
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+	t := context.GetAuthToken(request)
+	c.Assert(t, check.NotNil)
+	c.Assert(t.GetUserName(), check.Equals, s.token.GetUserName())
+}
+
+func (s *S) TestAuthTokenMiddlewareWithWrongAPIKey(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set(EMAIL_HEADER, s.token.GetUserName())
+	request.Header.Set(APIKEY_HEADER, "guess")
+	h, log := doHandler()
+	authTokenMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	e, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestAuthorizationRequiredHandlerWithoutToken(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	authorizationRequiredHandler(createToken).ServeHTTP(recorder, request)
+	e, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestCreateToken(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("POST", "/tokens", nil)
+	c.Assert(err, check.IsNil)
+	err = createToken(recorder, request, &s.token)
+	c.Assert(err, check.IsNil)
+	c.Assert(recorder.Code, check.Equals, http.StatusCreated)
+	var data map[string]string
+	c.Assert(json.Unmarshal(recorder.Body.Bytes(), &data), check.IsNil)
+	t, err := Auth("bearer " + data["token"])
+	c.Assert(err, check.IsNil)
+	c.Assert(t.GetUserName(), check.Equals, s.token.GetUserName())
+}
+
+func (s *S) TestAuthorizationMiddlewareWithoutPermission(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/ping", nil)
+	c.Assert(err, check.IsNil)
+	h, log := doHandler()
+	authorizationMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestAuthorizationMiddlewareAllowsRole(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/logs/", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, &s.token)
+	context.SetPermission(request, auth.PermControl)
+	h, log := doHandler()
+	authorizationMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, true)
+}
+
+func (s *S) TestAuthorizationMiddlewareForbidsRole(c *check.C) {
+	s.backend.AddUser(&auth.User{Email: "viewer@megam.io", Roles: []auth.Role{auth.RoleReadOnly}})
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/vnc/", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, &Token{Token: "bbbb", UserEmail: "viewer@megam.io"})
+	context.SetPermission(request, auth.PermControl)
+	h, log := doHandler()
+	authorizationMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	e, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusForbidden)
+}
+
+func (s *S) TestAuthorizationMiddlewareWithoutToken(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/logs/", nil)
+	c.Assert(err, check.IsNil)
+	context.SetPermission(request, auth.PermRead)
+	h, log := doHandler()
+	authorizationMiddleware(recorder, request, h)
+	c.Assert(log.called, check.Equals, false)
+	e, ok := context.GetRequestError(request).(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestCheckAccessForOrgMember(c *check.C) {
+	s.backend.AddUser(&auth.User{Email: "mate@megam.io", Orgs: []auth.Membership{
+		{OrgId: "ORG001", Role: auth.RoleReadOnly},
+		{OrgId: "ORG003", Role: auth.RoleMember},
+	}})
+	t := &Token{Token: "cccc", UserEmail: "mate@megam.io"}
+	c.Assert(checkAccess(t, "info@megam.io", "ORG001", auth.PermRead), check.IsNil)
+	c.Assert(checkAccess(t, "info@megam.io", "ORG001", auth.PermControl), check.NotNil)
+	c.Assert(checkAccess(t, "info@megam.io", "ORG003", auth.PermControl), check.IsNil)
+	c.Assert(checkAccess(t, "info@megam.io", "ORG002", auth.PermRead), check.NotNil)
+	c.Assert(checkAccess(t, "info@megam.io", "", auth.PermRead), check.NotNil)
+	c.Assert(checkAccess(&s.token, "info@megam.io", "ORG002", auth.PermControl), check.IsNil)
+}
+
 func (s *S) TestRunDelayedHandlerWithoutHandler(c *check.C) {
 	recorder := httptest.NewRecorder()
 	request, err := http.NewRequest("GET", "/", nil)
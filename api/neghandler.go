@@ -6,7 +6,9 @@ import (
 	"github.com/codegangsta/negroni"
 	"github.com/googollee/go-socket.io"
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/auth"
 	"github.com/rs/cors"
+	"golang.org/x/net/websocket"
 	"net/http"
 )
 
@@ -46,10 +48,13 @@ func NewNegHandler() *negroni.Negroni {
 
 	m.Add("Get", "/", Handler(index))
 	//m.Add("Get", "/logs", Handler(logs))
-	m.Add("Post", "/logs/", socketServer)
-	m.Add("Get", "/logs/", socketServer)
+	m.AddWithPermission("Post", "/logs/", auth.PermRead, authenticated(socketServer))
+	m.AddWithPermission("Get", "/logs/", auth.PermRead, authenticated(socketServer))
+	m.AddWithPermission("Post", "/tokens", auth.PermRead, authorizationRequiredHandler(createToken))
 	m.Add("Get", "/ping", Handler(ping))
-	m.Add("Get", "/vnc/", Handler(vnc))
+	m.Add("Get", "/metrics", Handler(metrics)) //the scrape token or an admin token
+	m.AddWithPermission("Get", "/vnc/", auth.PermControl, Handler(vnc))
+	m.AddWithPermission("Get", "/shell/{id}", auth.PermControl, websocket.Server{Handshake: shellHandshake, Handler: remoteShellHandler})
 
 	socketHandler(socketServer)
 
@@ -62,6 +67,7 @@ func NewNegHandler() *negroni.Negroni {
 	n.Use(negroni.HandlerFunc(flushingWriterMiddleware))
 	n.Use(negroni.HandlerFunc(errorHandlingMiddleware))
 	n.Use(negroni.HandlerFunc(authTokenMiddleware))
+	n.Use(negroni.HandlerFunc(authorizationMiddleware))
 	n.UseHandler(http.HandlerFunc(runDelayedHandler))
 	return n
 }
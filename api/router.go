@@ -6,10 +6,12 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/auth"
 )
 
 type delayedRouter struct {
 	mux.Router
+	perms map[*mux.Route]auth.Permission
 }
 
 func (r *delayedRouter) registerVars(req *http.Request, vars map[string]string) {
@@ -24,6 +26,16 @@ func (r *delayedRouter) Add(method string, path string, h http.Handler) *mux.Rou
 	return r.Router.Handle(path, h).Methods(method)
 }
 
+// AddWithPermission binds a path to a handler for the users with perm.
+func (r *delayedRouter) AddWithPermission(method string, path string, perm auth.Permission, h http.Handler) *mux.Route {
+	route := r.Add(method, path, h)
+	if r.perms == nil {
+		r.perms = make(map[*mux.Route]auth.Permission)
+	}
+	r.perms[route] = perm
+	return route
+}
+
 // AddAll binds a path to GET, POST, PUT and DELETE methods.
 func (r *delayedRouter) AddAll(path string, h http.Handler) *mux.Route {
 	return r.Router.Handle(path, h).Methods("GET", "POST", "PUT", "DELETE")
@@ -36,5 +48,8 @@ func (r *delayedRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	r.registerVars(req, match.Vars)
+	if perm, ok := r.perms[match.Route]; ok {
+		context.SetPermission(req, perm)
+	}
 	context.SetDelayedHandler(req, match.Handler)
 }
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"net/http/httptest"
 
+	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/auth"
 	"gopkg.in/check.v1"
 )
 
@@ -41,3 +43,13 @@ func (s *S) TestDelayedRouterAddAll(c *check.C) {
 		called = false
 	}
 }
+
+func (s *S) TestDelayedRouterAddWithPermission(c *check.C) {
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest("GET", "/dream/tel'aran'rhiod", nil)
+	c.Assert(err, check.IsNil)
+	router := &delayedRouter{}
+	router.AddWithPermission("GET", "/dream/{world}", auth.PermControl, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	router.ServeHTTP(recorder, request)
+	c.Assert(context.GetPermission(request), check.Equals, auth.PermControl)
+}
@@ -6,12 +6,17 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/errors"
-	"github.com/megamsys/vertice/api/context"
+	"github.com/megamsys/vertice/auth"
 	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/provision"
 	"golang.org/x/net/websocket"
 )
 
+//the shell is opened from one of the UIHosts, like the vnc.
+func shellHandshake(config *websocket.Config, r *http.Request) error {
+	return checkOrigin(r)
+}
+
 func remoteShellHandler(ws *websocket.Conn) {
 	var httpErr *errors.HTTP
 	defer func() {
@@ -28,24 +33,15 @@ func remoteShellHandler(ws *websocket.Conn) {
 		}
 	}()
 	r := ws.Request()
-	token := context.GetAuthToken(r)
-	if token == nil {
-		httpErr = &errors.HTTP{
-			Code:    http.StatusUnauthorized,
-			Message: "no token provided",
-		}
-		return
-	}
-	user, err := token.User()
+	token, err := requestToken(r)
 	if err != nil {
-		httpErr = &errors.HTTP{
-			Code:    http.StatusInternalServerError,
-			Message: err.Error(),
-		}
+		httpErr = err.(*errors.HTTP)
 		return
 	}
+	email := token.GetUserName()
 	assembly_id := r.URL.Query().Get(":id") //send the assembly_id
-	box, err := getBox(assembly_id)
+	boxId := r.URL.Query().Get("id")
+	box, err := getBox(token, assembly_id, boxId)
 	if err != nil {
 		if herr, ok := err.(*errors.HTTP); ok {
 			httpErr = herr
@@ -57,21 +53,28 @@ func remoteShellHandler(ws *websocket.Conn) {
 		}
 		return
 	}
-	boxId := r.URL.Query().Get("id")
 	width, _ := strconv.Atoi(r.URL.Query().Get("width"))
 	height, _ := strconv.Atoi(r.URL.Query().Get("height"))
 	term := r.URL.Query().Get("term")
-	log.Debugf("%s %s %s %s %s", user, boxId, width, height, term)
+	log.Debugf("shell %s %s %d %d %s", email, box.Id, width, height, term)
 
+	p, err := carton.GetProvisioner(box.Provider)
+	if err != nil {
+		httpErr = &errors.HTTP{
+			Code:    http.StatusBadRequest,
+			Message: err.Error(),
+		}
+		return
+	}
 	opts := provision.ShellOptions{
 		Box:    box,
 		Conn:   ws,
 		Width:  width,
 		Height: height,
-		Unit:   boxId,
+		Unit:   box.Id,
 		Term:   term,
 	}
-	err = carton.ProvisionerMap[box.Provider].Shell(opts) //BUG: we need get the provisioner of the correct provider
+	err = p.Shell(opts)
 	if err != nil {
 		httpErr = &errors.HTTP{
 			Code:    http.StatusInternalServerError,
@@ -80,7 +83,28 @@ func remoteShellHandler(ws *websocket.Conn) {
 	}
 }
 
-//Return the Box object ?  Get the carton, and make a Box
-func getBox(a string) (*provision.Box, error) {
-	return nil, nil
+// getBox makes the carton of the assembly and returns its box with the
+// boxId, or its first box when boxId is empty.
+func getBox(t auth.Token, assemblyId, boxId string) (*provision.Box, error) {
+	c, err := carton.NewCarton("", assemblyId, t.GetUserName())
+	if err != nil {
+		return nil, &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
+	if err = checkAccess(t, c.AccountId, c.OrgId, auth.PermControl); err != nil {
+		return nil, err
+	}
+	return pickBox(c, boxId)
+}
+
+func pickBox(c *carton.Carton, boxId string) (*provision.Box, error) {
+	if err := c.ToBox(); err != nil {
+		return nil, err
+	}
+	boxes := *c.Boxes
+	for i := range boxes {
+		if boxId == "" || boxes[i].Id == boxId {
+			return &boxes[i], nil
+		}
+	}
+	return nil, &errors.HTTP{Code: http.StatusNotFound, Message: provision.ErrBoxNotFound.Error()}
 }
@@ -1,5 +1,45 @@
 package api
 
+import (
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestShellHandshakeChecksTheOrigin(c *check.C) {
+	request, err := http.NewRequest("GET", "http://localhost:7777/shell/ASM001", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "https://evil.io")
+	c.Assert(shellHandshake(&websocket.Config{}, request), check.NotNil)
+	request.Header.Set("Origin", "http://localhost:7777")
+	c.Assert(shellHandshake(&websocket.Config{}, request), check.IsNil)
+}
+
+func (s *S) TestPickBoxByUnit(c *check.C) {
+	cart := &carton.Carton{Boxes: &[]provision.Box{
+		provision.Box{Id: "COM001", Provider: "one"},
+		provision.Box{Id: "COM002", Provider: "docker"},
+	}}
+	b, err := pickBox(cart, "COM002")
+	c.Assert(err, check.IsNil)
+	c.Assert(b.Provider, check.Equals, "docker")
+	b, err = pickBox(cart, "")
+	c.Assert(err, check.IsNil)
+	c.Assert(b.Id, check.Equals, "COM001")
+}
+
+func (s *S) TestPickBoxNotFound(c *check.C) {
+	cart := &carton.Carton{Boxes: &[]provision.Box{provision.Box{Id: "COM001"}}}
+	_, err := pickBox(cart, "COM009")
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusNotFound)
+}
+
 /*
 func (s *S) TestAppShellSpecifyUnit(c *check.C) {
 	a := app.App{
@@ -18,12 +18,17 @@ const (
 func socketHandler(server *socketio.Server) {
 	server.On("connection", func(so socketio.Socket) {
 		log.Debugf(cmd.Colorfy("  > [socket] ", "blue", "", "bold") + fmt.Sprintf("Connecting new client : %s", so.Id()))
+		t, err := socketToken(so.Request())
+		if err != nil {
+			so.Emit("error", err.Error())
+			return
+		}
 		so.On("category_connect", func(category string) {
 			switch category {
 			case LOG:
-				logHandler(so)
+				logHandler(so, t)
 			case VNC:
-				//vncHandler(so)
+				so.Emit("error", "vnc is served as a websocket on /vnc/")
 			default:
 				so.Emit("error", NOTFOUND)
 			}
@@ -3,13 +3,16 @@ package api
 import (
 	"testing"
 
+	"github.com/megamsys/vertice/auth"
 	"gopkg.in/check.v1"
 )
 
 func Test(t *testing.T) { check.TestingT(t) }
 
 type S struct {
-	token Token
+	token      Token
+	adminToken Token
+	backend    *auth.MemoryBackend
 }
 
 var _ = check.Suite(&S{})
@@ -21,6 +24,16 @@ func resetHandlers() {
 func (s *S) SetUpSuite(c *check.C) {
 	s.token = getTok()
 	c.Assert(s.token.GetUserName(), check.Equals, "info@megam.io")
+	s.backend = auth.NewMemoryBackend()
+	s.backend.AddUser(&auth.User{Email: s.token.UserEmail, APIKey: "secret"})
+	s.adminToken = Token{Token: "bbbb", UserEmail: "admin@megam.io"}
+	s.backend.AddUser(&auth.User{Email: s.adminToken.UserEmail, Roles: []auth.Role{auth.RoleAdmin}})
+	auth.SetBackend(s.backend)
+	u, err := s.backend.GetUserByEmail(s.token.UserEmail)
+	c.Assert(err, check.IsNil)
+	t, err := auth.CreateToken(u)
+	c.Assert(err, check.IsNil)
+	s.token.Token = t.Value
 }
 
 func getTok() Token {
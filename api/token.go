@@ -1,6 +1,13 @@
 package api
 
-import "github.com/megamsys/vertice/auth"
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/auth"
+)
 
 type Token struct {
 	Token     string
@@ -19,10 +26,30 @@ func (t *Token) GetUserName() string {
 	return t.UserEmail
 }
 
-func Auth(t string) (auth.Token, error) {
-	tt := Token{
-		Token:     "aaaa",
-		UserEmail: "info@megam.io",
+// Auth returns the token of an Authorization header: 'bearer token' or 'token'.
+func Auth(header string) (auth.Token, error) {
+	value, err := auth.ParseToken(header)
+	if err != nil {
+		return nil, err
+	}
+	return auth.CheckToken(value)
+}
+
+// createToken issues a token to the authenticated user, usually with its
+// api key: POST /tokens
+func createToken(w http.ResponseWriter, r *http.Request, t auth.Token) error {
+	u, err := t.User()
+	if err != nil {
+		return &errors.HTTP{Code: http.StatusUnauthorized, Message: err.Error()}
+	}
+	ut, err := auth.CreateToken(u)
+	if err != nil {
+		return err
 	}
-	return &tt, nil
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	return json.NewEncoder(w).Encode(map[string]string{
+		"token":      ut.Value,
+		"expires_at": ut.ExpiresAt.Format(time.RFC3339),
+	})
 }
@@ -1,12 +1,82 @@
 package api
 
 import (
-	//"encoding/json"
-	"net/http"  
+	"fmt"
+	"net/http"
+	"net/url"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/auth"
+	"github.com/megamsys/vertice/govnc"
+	"golang.org/x/net/websocket"
 )
 
+const binaryProtocol = "binary"
+
+// UIHosts are the hosts (host[:port]) of the web consoles allowed to open the
+// websockets, when empty only the origin of the api host is.
+var UIHosts []string
+
+// vnc proxies a websocket (noVNC) to the VNC server of the machine of an
+// assembly: /vnc/?id=<assembly_id>&token=<token>
 func vnc(w http.ResponseWriter, r *http.Request) error {
+	token, err := requestToken(r)
+	if err != nil {
+		return err
+	}
+	asm, err := ownedAssembly(token, r.URL.Query().Get("id"), auth.PermControl)
+	if err != nil {
+		return err
+	}
+	host, port := asm.VncHost()
+	vh := &govnc.VncHost{IpAddress: host, Port: port}
+	if _, err = vh.Addr(); err != nil {
+		return &errors.HTTP{Code: http.StatusNotFound, Message: err.Error()}
+	}
 
+	websocket.Server{
+		Handshake: vncHandshake,
+		Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+			if err := govnc.Proxy(ws, vh, govnc.IdleTimeout); err != nil {
+				log.Errorf("vnc proxy to %s:%s closed : %s", host, port, err)
+			}
+		},
+	}.ServeHTTP(w, r)
 	return nil
+}
+
+//noVNC asks for the binary subprotocol, the origin must be one of the UIHosts.
+func vncHandshake(config *websocket.Config, r *http.Request) error {
+	if err := checkOrigin(r); err != nil {
+		return err
+	}
+	for _, p := range config.Protocol {
+		if p == binaryProtocol {
+			config.Protocol = []string{binaryProtocol}
+			return nil
+		}
+	}
+	config.Protocol = nil
+	return nil
+}
 
+// checkOrigin fails unless the Origin of the request is one of the UIHosts.
+func checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	u, err := url.Parse(origin)
+	if origin == "" || err != nil || u.Host == "" {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	hosts := UIHosts
+	if len(hosts) == 0 {
+		hosts = []string{r.Host}
+	}
+	for _, h := range hosts {
+		if u.Host == h {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
 }
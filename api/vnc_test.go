@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/megamsys/libgo/errors"
+	"github.com/megamsys/vertice/api/context"
+	"golang.org/x/net/websocket"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRequestTokenWithoutToken(c *check.C) {
+	request, err := http.NewRequest("GET", "/vnc/?id=ASM001", nil)
+	c.Assert(err, check.IsNil)
+	_, err = requestToken(request)
+	e, ok := err.(*errors.HTTP)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Code, check.Equals, http.StatusUnauthorized)
+}
+
+func (s *S) TestRequestTokenFromContext(c *check.C) {
+	request, err := http.NewRequest("GET", "/vnc/?id=ASM001", nil)
+	c.Assert(err, check.IsNil)
+	context.SetAuthToken(request, &s.token)
+	t, err := requestToken(request)
+	c.Assert(err, check.IsNil)
+	c.Assert(t.GetUserName(), check.Equals, s.token.GetUserName())
+}
+
+func (s *S) TestVncHandshakePicksBinary(c *check.C) {
+	request, err := http.NewRequest("GET", "http://localhost:7777/vnc/?id=ASM001", nil)
+	c.Assert(err, check.IsNil)
+	request.Header.Set("Origin", "http://localhost:7777")
+	config := &websocket.Config{Protocol: []string{"base64", "binary"}}
+	c.Assert(vncHandshake(config, request), check.IsNil)
+	c.Assert(config.Protocol, check.DeepEquals, []string{"binary"})
+}
+
+func (s *S) TestCheckOrigin(c *check.C) {
+	defer func(h []string) { UIHosts = h }(UIHosts)
+	request, err := http.NewRequest("GET", "http://localhost:7777/vnc/?id=ASM001", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(checkOrigin(request), check.NotNil)
+	request.Header.Set("Origin", "http://localhost:7777")
+	c.Assert(checkOrigin(request), check.IsNil)
+	request.Header.Set("Origin", "https://console.megam.io")
+	c.Assert(checkOrigin(request), check.NotNil)
+	UIHosts = []string{"console.megam.io"}
+	c.Assert(checkOrigin(request), check.IsNil)
+	request.Header.Set("Origin", "https://evil.io")
+	c.Assert(checkOrigin(request), check.NotNil)
+}
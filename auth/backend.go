@@ -0,0 +1,27 @@
+package auth
+
+import "sync"
+
+// Backend is where the users are looked up, the tokens issued to them are
+// signed and aren't kept.
+type Backend interface {
+	GetUserByEmail(email string) (*User, error)
+}
+
+var (
+	bmu     sync.RWMutex
+	backend Backend = NewMemoryBackend()
+)
+
+// SetBackend swaps the auth backend, the httpd service sets the gateway one.
+func SetBackend(b Backend) {
+	bmu.Lock()
+	defer bmu.Unlock()
+	backend = b
+}
+
+func getBackend() Backend {
+	bmu.RLock()
+	defer bmu.RUnlock()
+	return backend
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/api"
+	"github.com/megamsys/vertice/meta"
+)
+
+const (
+	ACCOUNTS = "/accounts/"
+	ORGS     = "/orgs"
+)
+
+// GatewayBackend looks up the users in the megam gateway (meta.MC.Api).
+type GatewayBackend struct{}
+
+func NewGatewayBackend() *GatewayBackend {
+	return &GatewayBackend{}
+}
+
+// gatewayAccount is the response of GET /accounts/<email>, the authority of
+// its states is "admin" or "user".
+type gatewayAccount struct {
+	Results struct {
+		Email    string `json:"email"`
+		ApiKey   string `json:"api_key"`
+		Password struct {
+			Password string `json:"password"`
+		} `json:"password"`
+		States struct {
+			Authority string `json:"authority"`
+		} `json:"states"`
+	} `json:"results"`
+}
+
+// gatewayOrgs is the response of GET /orgs, the orgs the account belongs to.
+// The org created for the account has it as accounts_id, its members are
+// listed with the role the org admin gave them.
+type gatewayOrgs struct {
+	Results []struct {
+		Id         string          `json:"id"`
+		AccountsId string          `json:"accounts_id"`
+		Name       string          `json:"name"`
+		Members    []gatewayMember `json:"members"`
+	} `json:"results"`
+}
+
+type gatewayMember struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (g *GatewayBackend) GetUserByEmail(email string) (*User, error) {
+	args := api.ApiArgs{
+		Master_Key: meta.MC.MasterKey,
+		Url:        meta.MC.Api,
+		Email:      email,
+	}
+	response, err := api.NewClient(args, ACCOUNTS+email).Get()
+	if err != nil {
+		return nil, err
+	}
+	ac := &gatewayAccount{}
+	if err = json.Unmarshal(response, ac); err != nil {
+		return nil, err
+	}
+	if ac.Results.Email == "" {
+		return nil, ErrUserNotFound
+	}
+	orgs := &gatewayOrgs{}
+	if response, err = api.NewClient(args, ORGS).Get(); err == nil {
+		err = json.Unmarshal(response, orgs)
+	}
+	if err != nil {
+		log.Warnf("unable to get the orgs of %s : %s", email, err)
+	}
+	return newGatewayUser(ac, orgs), nil
+}
+
+// newGatewayUser maps the account and the orgs of the gateway to a user. It
+// is the admin of the org of its account, in the other orgs it has the role
+// it is listed with, read-only when it isn't.
+func newGatewayUser(ac *gatewayAccount, orgs *gatewayOrgs) *User {
+	u := &User{
+		Email:    ac.Results.Email,
+		Password: ac.Results.Password.Password,
+		APIKey:   ac.Results.ApiKey,
+		Roles:    []Role{ParseRole(ac.Results.States.Authority)},
+	}
+	for _, o := range orgs.Results {
+		m := Membership{OrgId: o.Id, Role: RoleAdmin}
+		if o.AccountsId != u.Email {
+			m.Role = memberRole(o.Members, u.Email)
+		}
+		u.Orgs = append(u.Orgs, m)
+	}
+	return u
+}
+
+func memberRole(members []gatewayMember, email string) Role {
+	for _, mb := range members {
+		if mb.Email == email {
+			return ParseRole(mb.Role)
+		}
+	}
+	return RoleReadOnly
+}
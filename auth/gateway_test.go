@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/check.v1"
+)
+
+const (
+	accountResponse = `{"json_claz":"Megam::Account","results":{"id":"ACT001","email":"info@megam.io",
+"api_key":"faa4b4dc","password":{"password":"secret"},"states":{"authority":"%s","active":"true"}}}`
+	orgsResponse = `{"json_claz":"Megam::OrganizationsCollection","results":[
+{"id":"ORG001","accounts_id":"partner@megam.io","name":"partner"},
+{"id":"ORG002","accounts_id":"info@megam.io","name":"megam"},
+{"id":"ORG003","accounts_id":"team@megam.io","name":"team","members":[{"email":"info@megam.io","role":"read-only"}]},
+{"id":"ORG004","accounts_id":"ops@megam.io","name":"ops","members":[{"email":"info@megam.io","role":"org-member"}]}]}`
+)
+
+func gatewayResponses(c *check.C, authority, orgs string) (*gatewayAccount, *gatewayOrgs) {
+	ac, os := &gatewayAccount{}, &gatewayOrgs{}
+	c.Assert(json.Unmarshal([]byte(fmt.Sprintf(accountResponse, authority)), ac), check.IsNil)
+	c.Assert(json.Unmarshal([]byte(orgs), os), check.IsNil)
+	return ac, os
+}
+
+func (s *S) TestNewGatewayUser(c *check.C) {
+	ac, orgs := gatewayResponses(c, "admin", orgsResponse)
+	u := newGatewayUser(ac, orgs)
+	c.Assert(u.Email, check.Equals, "info@megam.io")
+	c.Assert(u.Password, check.Equals, "secret")
+	c.Assert(u.APIKey, check.Equals, "faa4b4dc")
+	c.Assert(u.Roles, check.DeepEquals, []Role{RoleAdmin})
+	c.Assert(u.Orgs, check.DeepEquals, []Membership{
+		{OrgId: "ORG001", Role: RoleReadOnly},
+		{OrgId: "ORG002", Role: RoleAdmin},
+		{OrgId: "ORG003", Role: RoleReadOnly},
+		{OrgId: "ORG004", Role: RoleMember},
+	})
+}
+
+func (s *S) TestNewGatewayUserMemberOfAnOrg(c *check.C) {
+	ac, orgs := gatewayResponses(c, "user", `{"results":[{"id":"ORG001","accounts_id":"partner@megam.io"}]}`)
+	u := newGatewayUser(ac, orgs)
+	c.Assert(u.Roles, check.DeepEquals, []Role{RoleMember})
+	c.Assert(u.Can(PermControl), check.Equals, true)
+	c.Assert(u.Can(PermAdmin), check.Equals, false)
+	r, ok := u.RoleIn("ORG001")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(r.Can(PermControl), check.Equals, false)
+}
+
+func (s *S) TestNewGatewayUserWithoutOrgs(c *check.C) {
+	ac, orgs := gatewayResponses(c, "", `{"results":[]}`)
+	u := newGatewayUser(ac, orgs)
+	c.Assert(u.Orgs, check.HasLen, 0)
+	c.Assert(u.Roles, check.DeepEquals, []Role{RoleMember})
+}
@@ -0,0 +1,29 @@
+package auth
+
+import "sync"
+
+// MemoryBackend keeps the users in memory, it's used by the tests.
+type MemoryBackend struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{users: make(map[string]User)}
+}
+
+func (m *MemoryBackend) AddUser(u *User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[u.Email] = *u
+}
+
+func (m *MemoryBackend) GetUserByEmail(email string) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.users[email]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return &u, nil
+}
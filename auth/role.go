@@ -0,0 +1,46 @@
+package auth
+
+import "strings"
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleMember   Role = "org-member"
+	RoleReadOnly Role = "read-only"
+)
+
+// Permission is what a route asks from the user.
+type Permission string
+
+const (
+	PermRead    Permission = "read"
+	PermControl Permission = "control"
+	PermAdmin   Permission = "admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin:    {PermRead, PermControl, PermAdmin},
+	RoleMember:   {PermRead, PermControl},
+	RoleReadOnly: {PermRead},
+}
+
+// ParseRole returns the role of a name, the unknown ones are read-only.
+func ParseRole(name string) Role {
+	switch r := Role(strings.ToLower(strings.TrimSpace(name))); r {
+	case RoleAdmin, RoleMember, RoleReadOnly:
+		return r
+	case "", "user", "member":
+		return RoleMember
+	}
+	return RoleReadOnly
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, rp := range rolePermissions[r] {
+		if rp == p {
+			return true
+		}
+	}
+	return false
+}
@@ -1,11 +1,35 @@
 package auth
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-var ErrInvalidToken = errors.New("Invalid token")
+// DefaultTokenTTL is how long an issued token is valid.
+const DefaultTokenTTL = 24 * time.Hour
+
+var (
+	ErrInvalidToken  = errors.New("Invalid token")
+	ErrTokenExpired  = errors.New("Token expired")
+	ErrInvalidAPIKey = errors.New("Invalid api key")
+)
+
+// TokenTTL is the validity of the tokens issued by CreateToken.
+var TokenTTL = DefaultTokenTTL
+
+// TokenKey signs the tokens, they aren't stored: every vertice sharing the
+// key validates the tokens issued by the others, across restarts too. The
+// random default is set by the httpd service from its config.
+var TokenKey = randomKey()
 
 type Token interface {
 	GetValue() string
@@ -15,6 +39,45 @@ type Token interface {
 	GetUserName() string
 }
 
+// UserToken is a token issued to a user. A zero ExpiresAt never expires.
+type UserToken struct {
+	Value     string
+	UserEmail string
+	ExpiresAt time.Time
+	user      *User
+}
+
+func (t *UserToken) GetValue() string {
+	return t.Value
+}
+
+// User returns the user of the token, it is looked up once per token and
+// cached till the token expires.
+func (t *UserToken) User() (*User, error) {
+	if t.user != nil {
+		return t.user, nil
+	}
+	if u, ok := users.get(t.Value); ok {
+		t.user = u
+		return u, nil
+	}
+	u, err := GetUserByEmail(t.UserEmail)
+	if err != nil {
+		return nil, err
+	}
+	users.put(t.Value, u, t.ExpiresAt)
+	t.user = u
+	return u, nil
+}
+
+func (t *UserToken) GetUserName() string {
+	return t.UserEmail
+}
+
+func (t *UserToken) IsExpired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().After(t.ExpiresAt)
+}
+
 // ParseToken extracts token from a header:
 // 'type token' or 'token'
 func ParseToken(header string) (string, error) {
@@ -28,3 +91,123 @@ func ParseToken(header string) (string, error) {
 	}
 	return value, ErrInvalidToken
 }
+
+// CreateToken issues a token for the user valid for TokenTTL.
+func CreateToken(u *User) (*UserToken, error) {
+	t := &UserToken{
+		UserEmail: u.Email,
+		ExpiresAt: time.Unix(time.Now().Add(TokenTTL).Unix(), 0),
+		user:      u,
+	}
+	t.Value = sign(t.UserEmail, t.ExpiresAt)
+	users.put(t.Value, u, t.ExpiresAt)
+	return t, nil
+}
+
+// CheckToken returns the token of the value when its signature is valid and
+// it hasn't expired.
+func CheckToken(value string) (Token, error) {
+	t, err := verify(value)
+	if err != nil {
+		return nil, err
+	}
+	if t.IsExpired() {
+		return nil, ErrTokenExpired
+	}
+	return t, nil
+}
+
+// CheckAPIKey authenticates the user of the email by its api key. The token
+// returned isn't saved and doesn't expire.
+func CheckAPIKey(email, key string) (Token, error) {
+	u, err := GetUserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if u.APIKey == "" || subtle.ConstantTimeCompare([]byte(u.APIKey), []byte(key)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	return &UserToken{Value: key, UserEmail: u.Email, user: u}, nil
+}
+
+//the value of a token is email.expiry.mac, the email base64 encoded.
+func sign(email string, expiresAt time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(email)) + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + mac(payload)
+}
+
+func verify(value string) (*UserToken, error) {
+	i := strings.LastIndex(value, ".")
+	if i < 0 || !hmac.Equal([]byte(value[i+1:]), []byte(mac(value[:i]))) {
+		return nil, ErrInvalidToken
+	}
+	parts := strings.Split(value[:i], ".")
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	email, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &UserToken{Value: value, UserEmail: string(email), ExpiresAt: time.Unix(expires, 0)}, nil
+}
+
+func mac(payload string) string {
+	h := hmac.New(sha256.New, TokenKey)
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func randomKey() []byte {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// userCache keeps the users of the tokens till they expire, a request with a
+// token doesn't ask the gateway for its user again.
+type userCache struct {
+	mu    sync.Mutex
+	users map[string]cachedUser
+}
+
+type cachedUser struct {
+	user      User
+	expiresAt time.Time
+}
+
+var users = &userCache{users: make(map[string]cachedUser)}
+
+func (c *userCache) get(value string) (*User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cu, ok := c.users[value]
+	if !ok || time.Now().After(cu.expiresAt) {
+		return nil, false
+	}
+	u := cu.user
+	return &u, true
+}
+
+// put caches the user of a token till expiresAt, the tokens without expiry
+// aren't cached.
+func (c *userCache) put(value string, u *User, expiresAt time.Time) {
+	if expiresAt.IsZero() {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for v, cu := range c.users {
+		if now.After(cu.expiresAt) {
+			delete(c.users, v)
+		}
+	}
+	c.users[value] = cachedUser{user: *u, expiresAt: expiresAt}
+}
@@ -1,6 +1,11 @@
 package auth
 
-import "gopkg.in/check.v1"
+import (
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
 
 func (s *S) TestParseToken(c *check.C) {
 	t, err := ParseToken("type token")
@@ -16,3 +21,82 @@ func (s *S) TestParseToken(c *check.C) {
 	c.Assert(err, check.Equals, ErrInvalidToken)
 	c.Assert(t, check.Equals, "")
 }
+
+func (s *S) TestCreateAndCheckToken(c *check.C) {
+	b := NewMemoryBackend()
+	b.AddUser(&User{Email: "info@megam.io", APIKey: "secret"})
+	SetBackend(b)
+	u, err := b.GetUserByEmail("info@megam.io")
+	c.Assert(err, check.IsNil)
+	t, err := CreateToken(u)
+	c.Assert(err, check.IsNil)
+	tok, err := CheckToken(t.Value)
+	c.Assert(err, check.IsNil)
+	c.Assert(tok.GetUserName(), check.Equals, "info@megam.io")
+	u, err = tok.User()
+	c.Assert(err, check.IsNil)
+	c.Assert(u.APIKey, check.Equals, "secret")
+	_, err = CheckToken("unknown")
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestCheckTokenExpired(c *check.C) {
+	_, err := CheckToken(sign("info@megam.io", time.Now().Add(-time.Minute)))
+	c.Assert(err, check.Equals, ErrTokenExpired)
+}
+
+func (s *S) TestCheckTokenSignedByAnotherKey(c *check.C) {
+	t, err := CreateToken(&User{Email: "info@megam.io"})
+	c.Assert(err, check.IsNil)
+	defer func(k []byte) { TokenKey = k }(TokenKey)
+	TokenKey = []byte("another vertice")
+	_, err = CheckToken(t.Value)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+func (s *S) TestCheckTokenTampered(c *check.C) {
+	t, err := CreateToken(&User{Email: "info@megam.io"})
+	c.Assert(err, check.IsNil)
+	forged := sign("admin@megam.io", t.ExpiresAt)
+	forged = forged[:strings.LastIndex(forged, ".")] + t.Value[strings.LastIndex(t.Value, "."):]
+	_, err = CheckToken(forged)
+	c.Assert(err, check.Equals, ErrInvalidToken)
+}
+
+type countingBackend struct {
+	*MemoryBackend
+	lookups int
+}
+
+func (b *countingBackend) GetUserByEmail(email string) (*User, error) {
+	b.lookups++
+	return b.MemoryBackend.GetUserByEmail(email)
+}
+
+func (s *S) TestTokenCachesItsUser(c *check.C) {
+	b := &countingBackend{MemoryBackend: NewMemoryBackend()}
+	b.AddUser(&User{Email: "cached@megam.io", APIKey: "secret"})
+	SetBackend(b)
+	value := sign("cached@megam.io", time.Now().Add(time.Hour))
+	for i := 0; i < 3; i++ {
+		t, err := CheckToken(value)
+		c.Assert(err, check.IsNil)
+		u, err := t.User()
+		c.Assert(err, check.IsNil)
+		c.Assert(u.APIKey, check.Equals, "secret")
+	}
+	c.Assert(b.lookups, check.Equals, 1)
+}
+
+func (s *S) TestCheckAPIKey(c *check.C) {
+	b := NewMemoryBackend()
+	b.AddUser(&User{Email: "info@megam.io", APIKey: "secret"})
+	SetBackend(b)
+	t, err := CheckAPIKey("info@megam.io", "secret")
+	c.Assert(err, check.IsNil)
+	c.Assert(t.GetUserName(), check.Equals, "info@megam.io")
+	_, err = CheckAPIKey("info@megam.io", "guess")
+	c.Assert(err, check.Equals, ErrInvalidAPIKey)
+	_, err = CheckAPIKey("nobody@megam.io", "secret")
+	c.Assert(err, check.Equals, ErrUserNotFound)
+}
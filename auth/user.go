@@ -1,20 +1,58 @@
 package auth
 
+import "errors"
+
+var ErrUserNotFound = errors.New("user not found")
+
+// User is an account of the gateway. Its Roles are granted on the routes, its
+// role in an org on the assemblies of the org.
 type User struct {
 	Email    string
 	Password string
 	APIKey   string
+	Orgs     []Membership
+	Roles    []Role
 }
 
+// Membership is the role of a user in an org.
+type Membership struct {
+	OrgId string
+	Role  Role
+}
+
+// GetUserByEmail returns the user of the email from the auth backend.
 func GetUserByEmail(email string) (*User, error) {
-	/*
-		var u User
-		conn, err := db.Conn()
-		if err != nil {
-			return nil, err
+	return getBackend().GetUserByEmail(email)
+}
+
+// GetRoles returns the roles of the user, an org member by default.
+func (u *User) GetRoles() []Role {
+	if len(u.Roles) == 0 {
+		return []Role{RoleMember}
+	}
+	return u.Roles
+}
+
+// Can tells if any role of the user grants the permission.
+func (u *User) Can(p Permission) bool {
+	for _, r := range u.GetRoles() {
+		if r.Can(p) {
+			return true
 		}
+	}
+	return false
+}
 
-		return &u, nil
-	*/
-	return nil, nil
+// RoleIn returns the role of the user in the org, false when it isn't a
+// member of it.
+func (u *User) RoleIn(orgId string) (Role, bool) {
+	if orgId == "" {
+		return "", false
+	}
+	for _, m := range u.Orgs {
+		if m.OrgId == orgId {
+			return m.Role, true
+		}
+	}
+	return "", false
 }
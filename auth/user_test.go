@@ -1,7 +1,7 @@
 package auth
 
 import (
-//	"gopkg.in/check.v1"
+	"gopkg.in/check.v1"
 )
 
 /*
@@ -31,3 +31,22 @@ func (s *S) TestGetUserByEmailWithInvalidEmail(c *check.C) {
 	c.Assert(e.Message, check.Equals, "invalid email")
 }
 */
+
+func (s *S) TestUserCan(c *check.C) {
+	u := User{Email: "info@megam.io"}
+	c.Assert(u.Can(PermControl), check.Equals, true)
+	c.Assert(u.Can(PermAdmin), check.Equals, false)
+	u.Roles = []Role{RoleReadOnly}
+	c.Assert(u.Can(PermRead), check.Equals, true)
+	c.Assert(u.Can(PermControl), check.Equals, false)
+	u.Roles = []Role{RoleReadOnly, RoleAdmin}
+	c.Assert(u.Can(PermAdmin), check.Equals, true)
+}
+
+func (s *S) TestParseRole(c *check.C) {
+	c.Assert(ParseRole("admin"), check.Equals, RoleAdmin)
+	c.Assert(ParseRole("user"), check.Equals, RoleMember)
+	c.Assert(ParseRole(""), check.Equals, RoleMember)
+	c.Assert(ParseRole("Read-Only"), check.Equals, RoleReadOnly)
+	c.Assert(ParseRole("guest"), check.Equals, RoleReadOnly)
+}
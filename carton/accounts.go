@@ -81,6 +81,19 @@ func NewAccounts(email string) (*Account, error) {
 	return new(Account).get(newArgs(email, ""))
 }
 
+func (a *Account) Update() error {
+	return a.update(newArgs(a.Email, ""))
+}
+
+func (a *Account) update(args api.ApiArgs) error {
+	cl := api.NewClient(args, "/accounts/update")
+	_, err := cl.Post(a)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 func (a *Account) get(args api.ApiArgs) (*Account, error) {
 	cl := api.NewClient(args, "/accounts/" + args.Email)
 	response, err := cl.Get()
@@ -39,6 +39,7 @@ const (
 	ASSEMBLYBUCKET        = "assembly"
 	ASM_UPDATE            = "/assembly/update"
 	SSHKEY                = "sshkey"
+	SSH_HOSTKEY           = "sshhostkey"
 	VNCPORT               = "vncport"
 	VNCHOST               = "vnchost"
 	INSTANCE_ID           = "instance_id"
@@ -47,12 +48,14 @@ const (
 	PUBLICIPV6            = "publicipv6"
 	PRIVATEIPV6           = "privateipv6"
 	QUOTAID               = "quota_id"
+	CONSTRAINTS           = "constraints"
 	VM_CPU_COST           = "vm_cpu_cost_per_hour"
 	VM_MEMORY_COST        = "vm_memory_cost_per_hour"
 	VM_DISK_COST          = "vm_disk_cost_per_hour"
 	CONTAINER_CPU_COST    = "container_cpu_cost_per_hour"
 	CONTAINER_MEMORY_COST = "container_memory_cost_per_hour"
 	CONTAINER_DISK_COST   = "container_disk_cost_per_hour"
+	LAST_BILLED_AT        = "lastbilledat"
 )
 
 type Policy struct {
@@ -184,6 +187,7 @@ func mkCarton(aies, ay, email string) (*Carton, error) {
 		PublicIp:     a.publicIp(),
 		Region:       a.region(),
 		Vnets:        a.vnets(),
+		Constraints:  a.constraints(),
 		InstanceId:   a.instanceId(),
 		Snapshot:     a.isSnap(),
 		ImageName:    a.imageName(),
@@ -231,6 +235,7 @@ func (a *Assembly) mkBoxes(aies string, args api.ApiArgs) ([]provision.Box, erro
 				b.Status = utils.Status(a.Status)
 				b.State = utils.State(a.State)
 				b.Vnets = vnet
+				b.Constraints = a.constraints()
 				b.InstanceId = instanceId
 				b.QuotaId  =  a.quotaID()
 				newBoxs = append(newBoxs, b)
@@ -336,6 +341,16 @@ func (a *Assembly) NukeAndSetOutputs(m map[string][]string) error {
 	return nil
 }
 
+// LastBilledAt returns when the assembly was billed last.
+func (a *Assembly) LastBilledAt() (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(a.Outputs.Match(LAST_BILLED_AT)))
+	return t, err == nil
+}
+
+func (a *Assembly) SetLastBilledAt(t time.Time) error {
+	return a.NukeAndSetOutputs(map[string][]string{LAST_BILLED_AT: []string{t.Format(time.RFC3339)}})
+}
+
 func (a *Assembly) Delete(asmid string) error {
 	args := newArgs(a.AccountId, a.OrgId)
 	cl := api.NewClient(args, "/assembly/"+asmid)
@@ -396,6 +411,12 @@ func (a *Assembly) vncHost() string {
 func (a *Assembly) vncPort() string {
 	return a.Outputs.Match(VNCPORT)
 }
+
+// VncHost returns the host and port of the VNC server of the machine.
+func (a *Assembly) VncHost() (string, string) {
+	return a.vncHost(), a.vncPort()
+}
+
 func (a *Assembly) instanceId() string {
 	return a.Outputs.Match(INSTANCE_ID)
 }
@@ -407,6 +428,23 @@ func (a *Assembly) imageName() string {
 	return a.Inputs.Match(SNAPSHOTNAME)
 }
 
+// the labels the nodes of the boxes must have, as "rack=r1,disk=ssd".
+func (a *Assembly) constraints() map[string]string {
+	return parseConstraints(a.Inputs.Match(CONSTRAINTS))
+}
+
+func parseConstraints(s string) map[string]string {
+	c := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) != 2 || strings.TrimSpace(pair[0]) == "" {
+			continue
+		}
+		c[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	}
+	return c
+}
+
 func (a *Assembly) quotaID() string {
 	return a.Inputs.Match(QUOTAID)
 }
@@ -430,8 +468,9 @@ func (a *Assembly) newCompute() provision.BoxCompute {
 
 func (a *Assembly) newSSH() provision.BoxSSH {
 	return provision.BoxSSH{
-		User:   meta.MC.User,
-		Prefix: a.sshkey(),
+		User:    meta.MC.User,
+		Prefix:  a.sshkey(),
+		HostKey: a.Outputs.Match(SSH_HOSTKEY),
 	}
 }
 
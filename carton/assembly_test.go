@@ -28,3 +28,13 @@ package carton
 // func (s *S) TestUpdateAssembly(c *check.C)  {
 //
 // }
+
+import (
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestParseConstraints(c *check.C) {
+	c.Assert(parseConstraints(""), check.DeepEquals, map[string]string{})
+	c.Assert(parseConstraints("rack=r1, disk = ssd"), check.DeepEquals, map[string]string{"rack": "r1", "disk": "ssd"})
+	c.Assert(parseConstraints("rack,=ssd,zone=a=b"), check.DeepEquals, map[string]string{"zone": "a=b"})
+}
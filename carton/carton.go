@@ -1,6 +1,8 @@
 package carton
 
 import (
+	"fmt"
+
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/api"
 	"github.com/megamsys/libgo/utils"
@@ -31,7 +33,9 @@ type Carton struct {
 	InstanceId   string
 	Region       string
 	Vnets        map[string]string
+	Constraints  map[string]string
 	Boxes        *[]provision.Box
+	Results      []BoxResult //per box report of the last operation
 	Status       utils.Status
 	State        utils.State
 }
@@ -57,6 +61,19 @@ func (c *Carton) lvl() provision.BoxLevel {
 	}
 }
 
+// GetProvisioner returns the provisioner of a provider set by the subd daemons.
+func GetProvisioner(provider string) (provision.Provisioner, error) {
+	if p, ok := ProvisionerMap[provider]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("provisioner %q isn't enabled", provider)
+}
+
+// ToBox converts a carton without components to a box.
+func (c *Carton) ToBox() error {
+	return c.toBox()
+}
+
 //Converts a carton to a box, (applicable in torpedo case)
 func (c *Carton) toBox() error { //assemblies id.
 	switch c.lvl() {
@@ -77,12 +94,14 @@ func (c *Carton) toBox() error { //assemblies id.
 			ImageName:    c.ImageName,
 			Snapshot:     c.Snapshot,
 			Compute:      c.Compute,
+			SSH:          c.SSH,
 			Provider:     c.Provider,
 			PublicIp:     c.PublicIp,
 			InstanceId:   c.InstanceId,
 			QuotaId:      c.QuotaId,
 			Region:       c.Region,
 			Vnets:        c.Vnets,
+			Constraints:  c.Constraints,
 			Tosca:        c.Tosca,
 			Status:       c.Status,
 			State:        c.State,
@@ -94,46 +113,37 @@ func (c *Carton) toBox() error { //assemblies id.
 
 // Deploy carton, which basically deploys the boxes.
 func (c *Carton) Deploy() error {
-	for _, box := range *c.Boxes {
-		err := Deploy(&DeployOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("deploy", func(b *provision.Box) error {
+		return Deploy(&DeployOpts{B: b})
+	})
+}
+
+// Rollback carton, which redeploys the boxes from their previous image.
+func (c *Carton) Rollback() error {
+	return c.run("rollback", func(b *provision.Box) error {
+		return Rollback(&DeployOpts{B: b})
+	})
 }
 
 func (c *Carton) Running() error {
-	for _, box := range *c.Boxes {
-		err := Running(&DeployOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("running", func(b *provision.Box) error {
+		return Running(&DeployOpts{B: b})
+	})
 }
 
 // Destroys a carton, which deletes its boxes.
 func (c *Carton) Destroy() error {
-	for _, box := range *c.Boxes {
-		err := Destroy(&DestroyOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("destroy", func(b *provision.Box) error {
+		return Destroy(&DestroyOpts{B: b})
+	})
 }
 
 // moves the state to the desired state
 // changing the boxes state to StatusStateup.
 func (c *Carton) Stateup() error {
-	for _, box := range *c.Boxes {
-		err := ChangeState(&StateChangeOpts{B: &box, Changed: utils.StatusStateupped})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("stateup", func(b *provision.Box) error {
+		return ChangeState(&StateChangeOpts{B: b, Changed: utils.StatusStateupped})
+	})
 }
 
 // Available returns true if at least one of N boxes which is started
@@ -148,92 +158,73 @@ func (c *Carton) Available() bool {
 
 //upgrade run thru all the ops.
 func (c *Carton) Upgrade() error {
-	for _, box := range *c.Boxes {
-		err := NewUpgradeable(&box).Upgrade()
-		if err != nil {
-			log.Errorf("Unable to upgrade box : %s", err)
-			return err
-		}
-	}
-	return nil
+	return c.run("upgrade", func(b *provision.Box) error {
+		return NewUpgradeable(b).Upgrade()
+	})
 }
 
 // starts box
 func (c *Carton) Start() error {
-	for _, box := range *c.Boxes {
-		err := Start(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to start the box  %s", err)
-			return err
-		}
-	}
-	return nil
+	return c.run("start", func(b *provision.Box) error {
+		return Start(&LifecycleOpts{B: b})
+	})
 }
 
 // stops the box
 func (c *Carton) Stop() error {
-	for _, box := range *c.Boxes {
-		err := Stop(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to stop the box %s", err)
-			return err
-		}
-	}
-	return nil
+	return c.run("stop", func(b *provision.Box) error {
+		return Stop(&LifecycleOpts{B: b})
+	})
 }
 
 // restarts the box
 func (c *Carton) Restart() error {
-	for _, box := range *c.Boxes {
-		err := Restart(&LifecycleOpts{B: &box})
-		if err != nil {
-			log.Errorf("Unable to restart the box %s", err)
-			return err
-		}
-	}
-	return nil
+	return c.run("restart", func(b *provision.Box) error {
+		return Restart(&LifecycleOpts{B: b})
+	})
 }
 
 // SnapCreate a carton, which creates an image by current state of its box.
 func (c *Carton) SaveImage() error {
-	for _, box := range *c.Boxes {
-		err := SaveImage(&DiskOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("snapcreate", func(b *provision.Box) error {
+		return SaveImage(&DiskOpts{B: b})
+	})
 }
 
 // SnapDelete a carton, which removes an existing image created from state of its box.
 func (c *Carton) DeleteImage() error {
-	for _, box := range *c.Boxes {
-		err := DeleteImage(&DiskOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("snapremove", func(b *provision.Box) error {
+		return DeleteImage(&DiskOpts{B: b})
+	})
 }
 
 // AttachDisk a carton, which creates a disk storage by current state of its box.
 func (c *Carton) AttachDisk() error {
-	for _, box := range *c.Boxes {
-		err := AttachDisk(&DiskOpts{B: &box})
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return c.run("attachdisk", func(b *provision.Box) error {
+		return AttachDisk(&DiskOpts{B: b})
+	})
 }
 
 // DetachDisk a carton, which removes an existing disk storage by current state of its box.
 func (c *Carton) DetachDisk() error {
-	for _, box := range *c.Boxes {
-		err := DetachDisk(&DiskOpts{B: &box})
-		if err != nil {
-			return err
+	return c.run("detachdisk", func(b *provision.Box) error {
+		return DetachDisk(&DiskOpts{B: b})
+	})
+}
+
+//runs the op on all the boxes in parallel, bounded by the configured workers.
+func (c *Carton) run(op string, fn boxFunc) error {
+	results, err := NewBoxExecutor().Run(op, *c.Boxes, fn)
+	c.Results = results
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			log.Warnf("  %s %s skipped", op, r.Name)
+		case r.Err != nil:
+			log.Errorf("  %s %s failed in %s", op, r.Name, r.Duration)
+		default:
+			log.Debugf("  %s %s done in %s", op, r.Name, r.Duration)
 		}
 	}
-	return nil
+	return err
 }
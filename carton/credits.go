@@ -0,0 +1,267 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/events/alerts"
+	"github.com/megamsys/libgo/events/bills"
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/provision"
+)
+
+// DefaultReservationsJournal is the journal of the reservations in the
+// vertice dir.
+const DefaultReservationsJournal = "reservations.journal"
+
+var (
+	ErrInsufficientCredit = errors.New("credit balance insufficient")
+	ErrNoBalance          = errors.New("no balance for the user")
+)
+
+// CreditReservations holds the estimated cost of the boxes of every account
+// against its credit balance, from their deploy till they are destroyed. A
+// box is launched only when the credit not held by the other boxes of the
+// account covers its estimate.
+//
+// Once opened, every reservation and release is journaled, so that the boxes
+// still hold their credit after a restart. The journal is compacted to the
+// amounts held when it is replayed.
+type CreditReservations struct {
+	mu      sync.Mutex
+	held    map[string]map[string]float64
+	journal *os.File
+}
+
+// reservationRecord is a line of the journal, a zero amount is a release.
+type reservationRecord struct {
+	AccountId string    `json:"account_id"`
+	CartonId  string    `json:"carton_id"`
+	Amount    float64   `json:"amount"`
+	At        time.Time `json:"at"`
+}
+
+// Reservations are the credits held by the boxes deployed by this vertice.
+// Its journal is in the dir of this vertice, so the credits held by the boxes
+// deployed by a vertice on another host aren't counted.
+var Reservations = NewCreditReservations()
+
+func NewCreditReservations() *CreditReservations {
+	return &CreditReservations{held: make(map[string]map[string]float64)}
+}
+
+// Open replays the journal at path and journals the reservations to come in
+// it. The reservations already journaled are kept when opened again.
+func (r *CreditReservations) Open(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.journal != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := r.replay(path); err != nil {
+		return err
+	}
+	if err := r.compact(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	r.journal = f
+	return nil
+}
+
+func (r *CreditReservations) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		rr := &reservationRecord{}
+		if err := json.Unmarshal(sc.Bytes(), rr); err != nil {
+			log.Warnf("  reservations journal %s skips a line : %s", path, err)
+			continue
+		}
+		if rr.Amount > 0 {
+			r.hold(rr.AccountId, rr.CartonId, rr.Amount)
+		} else {
+			r.release(rr.AccountId, rr.CartonId)
+		}
+	}
+	return sc.Err()
+}
+
+// compact rewrites the journal at path with the amounts held.
+func (r *CreditReservations) compact(path string) error {
+	var keys []string
+	records := make(map[string]*reservationRecord)
+	for account, boxes := range r.held {
+		for id, amount := range boxes {
+			k := account + "/" + id
+			keys = append(keys, k)
+			records[k] = &reservationRecord{AccountId: account, CartonId: id, Amount: amount, At: time.Now()}
+		}
+	}
+	sort.Strings(keys)
+	tmp := path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, k := range keys {
+		if err = enc.Encode(records[k]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// record journals the amount held by the box, the reservation is kept in
+// memory when it can't be journaled.
+func (r *CreditReservations) record(accountId, cartonId string, amount float64) {
+	if r.journal == nil {
+		return
+	}
+	b, err := json.Marshal(&reservationRecord{AccountId: accountId, CartonId: cartonId, Amount: amount, At: time.Now()})
+	if err == nil {
+		_, err = r.journal.Write(append(b, '\n'))
+	}
+	if err != nil {
+		log.Errorf("  reservations journal of %s for %s : %s", cartonId, accountId, err)
+	}
+}
+
+func (r *CreditReservations) hold(accountId, cartonId string, amount float64) {
+	if r.held[accountId] == nil {
+		r.held[accountId] = make(map[string]float64)
+	}
+	r.held[accountId][cartonId] = amount
+}
+
+func (r *CreditReservations) release(accountId, cartonId string) float64 {
+	held := r.held[accountId][cartonId]
+	delete(r.held[accountId], cartonId)
+	if len(r.held[accountId]) == 0 {
+		delete(r.held, accountId)
+	}
+	return held
+}
+
+// Reserve holds amount of the credit of the account for the box, the amount
+// already held by the box is replaced.
+func (r *CreditReservations) Reserve(accountId, cartonId string, credit, amount float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	available := credit
+	for id, held := range r.held[accountId] {
+		if id != cartonId {
+			available -= held
+		}
+	}
+	if available <= 0 || amount > available {
+		return ErrInsufficientCredit
+	}
+	r.hold(accountId, cartonId, amount)
+	r.record(accountId, cartonId, amount)
+	return nil
+}
+
+// Release returns the amount held by the box to the credit of the account.
+func (r *CreditReservations) Release(accountId, cartonId string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	held := r.release(accountId, cartonId)
+	if held > 0 {
+		r.record(accountId, cartonId, 0)
+	}
+	return held
+}
+
+// Reserved returns the credit of the account held by its boxes.
+func (r *CreditReservations) Reserved(accountId string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sum float64
+	for _, held := range r.held[accountId] {
+		sum += held
+	}
+	return sum
+}
+
+// ReserveCredits holds the estimated cost of the box against the credit
+// balance of its account, notifying an INSUFFICIENT_FUND when it isn't covered.
+func ReserveCredits(b *provision.Box, estimate float64, w io.Writer) error {
+	credit, err := Credit(b.AccountId)
+	if err == ErrNoBalance {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = Reservations.Reserve(b.AccountId, b.CartonId, credit, estimate); err != nil {
+		DoneNotify(b, w, alerts.INSUFFICIENT_FUND)
+		log.Debugf(" credit balance %.4f insufficient for the estimate %.4f of the user (%s)", credit-Reservations.Reserved(b.AccountId), estimate, b.AccountId)
+		return err
+	}
+	return nil
+}
+
+// Credit returns the credit balance of the account, ErrNoBalance when the
+// account has none.
+func Credit(email string) (float64, error) {
+	bal, err := bills.NewBalances(email, meta.MC.ToMap())
+	if err != nil {
+		return 0, err
+	}
+	if bal == nil {
+		return 0, ErrNoBalance
+	}
+	return strconv.ParseFloat(bal.Credit, 64)
+}
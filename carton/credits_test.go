@@ -0,0 +1,58 @@
+package carton
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReserveCredits(c *check.C) {
+	r := NewCreditReservations()
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 4), check.IsNil)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM002", 10, 4), check.IsNil)
+	c.Assert(r.Reserved("vino.v@megam.io"), check.Equals, float64(8))
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM003", 10, 4), check.Equals, ErrInsufficientCredit)
+	c.Assert(r.Reserve("info@megam.io", "ASM004", 10, 4), check.IsNil)
+}
+
+func (s *S) TestReserveCreditsReplacesTheBox(c *check.C) {
+	r := NewCreditReservations()
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 6), check.IsNil)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 9), check.IsNil)
+	c.Assert(r.Reserved("vino.v@megam.io"), check.Equals, float64(9))
+}
+
+func (s *S) TestReserveCreditsNoBalance(c *check.C) {
+	r := NewCreditReservations()
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 0.01, 2), check.Equals, ErrInsufficientCredit)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 0, 0), check.Equals, ErrInsufficientCredit)
+	c.Assert(r.Reserved("vino.v@megam.io"), check.Equals, float64(0))
+}
+
+func (s *S) TestReleaseCredits(c *check.C) {
+	r := NewCreditReservations()
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 6), check.IsNil)
+	c.Assert(r.Release("vino.v@megam.io", "ASM001"), check.Equals, float64(6))
+	c.Assert(r.Release("vino.v@megam.io", "ASM001"), check.Equals, float64(0))
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM002", 10, 8), check.IsNil)
+}
+
+func (s *S) TestReservationsSurviveARestart(c *check.C) {
+	path := filepath.Join(c.MkDir(), DefaultReservationsJournal)
+	r := NewCreditReservations()
+	c.Assert(r.Open(path), check.IsNil)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 4), check.IsNil)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM002", 10, 3), check.IsNil)
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM001", 10, 5), check.IsNil)
+	r.Release("vino.v@megam.io", "ASM002")
+
+	r = NewCreditReservations()
+	c.Assert(r.Open(path), check.IsNil)
+	c.Assert(r.Reserved("vino.v@megam.io"), check.Equals, float64(5))
+	c.Assert(r.Reserve("vino.v@megam.io", "ASM003", 10, 6), check.Equals, ErrInsufficientCredit)
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(b), "\n"), check.Equals, 1)
+}
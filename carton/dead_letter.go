@@ -0,0 +1,168 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	nsqc "github.com/crackcomm/nsqueue/consumer"
+	nsqp "github.com/crackcomm/nsqueue/producer"
+	"github.com/megamsys/vertice/meta"
+)
+
+const (
+	// DEADLETTER_TOPIC is where the requests given up are published.
+	DEADLETTER_TOPIC = "deadletters"
+
+	replayChannel = "replay"
+)
+
+// DeadLetter is a request that failed for good, along with why it failed.
+type DeadLetter struct {
+	Topic          string    `json:"topic"`
+	Payload        *Payload  `json:"payload"`
+	Request        *Requests `json:"request"`
+	Error          string    `json:"error"`
+	Attempts       int       `json:"attempts"`
+	FirstAttemptAt time.Time `json:"first_attempt_at"`
+	FailedAt       time.Time `json:"failed_at"`
+}
+
+// NewDeadLetter returns the dead letter of a payload received on topic. The
+// request is nil when the payload couldn't be converted.
+func NewDeadLetter(topic string, p *Payload, r *Requests, err error, attempts int) *DeadLetter {
+	d := &DeadLetter{
+		Topic:          topic,
+		Payload:        p,
+		Request:        r,
+		Attempts:       attempts,
+		FirstAttemptAt: time.Now(),
+		FailedAt:       time.Now(),
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	if e, ok := Ledger.Get(p.Key()); ok {
+		d.FirstAttemptAt = e.StartedAt
+	}
+	return d
+}
+
+// Publish sends the dead letter to the dead letter topic.
+func (d *DeadLetter) Publish() error {
+	pons := nsqp.New()
+	if err := pons.Connect(meta.MC.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+	bytes, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	log.Debugf("  pub to %s (%s)", DEADLETTER_TOPIC, bytes)
+	return pons.Publish(DEADLETTER_TOPIC, bytes)
+}
+
+// Replay publishes the payload back to the topic it was received on.
+func (d *DeadLetter) Replay() error {
+	pons := nsqp.New()
+	if err := pons.Connect(meta.MC.NSQd[0]); err != nil {
+		return err
+	}
+	defer pons.Stop()
+	bytes, err := json.Marshal(d.Payload)
+	if err != nil {
+		return err
+	}
+	log.Debugf("  replay to %s (%s)", d.Topic, bytes)
+	return pons.Publish(d.Topic, bytes)
+}
+
+// RetryPolicyFor returns the configured retry policy of a category/action.
+func RetryPolicyFor(category, action string) meta.RetryPolicy {
+	if meta.MC == nil {
+		return meta.DefaultRetryPolicy
+	}
+	return retryPolicy(meta.MC.Retries, category, action)
+}
+
+// the most specific policy wins: category and action, then category, then any.
+func retryPolicy(policies []meta.RetryPolicy, category, action string) meta.RetryPolicy {
+	best, score := meta.DefaultRetryPolicy, -1
+	for _, p := range policies {
+		s := 0
+		switch {
+		case p.Category == "" && p.Action == "":
+			s = 0
+		case p.Category == category && p.Action == "":
+			s = 1
+		case p.Category == category && p.Action == action:
+			s = 2
+		default:
+			continue
+		}
+		if s > score {
+			best, score = p, s
+		}
+	}
+	return best.WithDefaults()
+}
+
+// ReplayDeadLetters republishes the dead letters to their topics. It returns
+// the number of replayed requests once no dead letter arrived for idle.
+func ReplayDeadLetters(idle time.Duration) (int, error) {
+	var replayed int32
+	seen := make(chan bool, 1)
+	cons := nsqc.New()
+	if err := cons.Register(DEADLETTER_TOPIC, replayChannel, 1, func(msg *nsqc.Message) {
+		defer func() {
+			select {
+			case seen <- true:
+			default:
+			}
+		}()
+		d := &DeadLetter{}
+		if err := json.Unmarshal(msg.Body, d); err != nil || d.Payload == nil {
+			log.Errorf("Unparsable dead letter, ignoring: %s", string(msg.Body))
+			return
+		}
+		if err := d.Replay(); err != nil {
+			log.Errorf("Unable to replay %s to %s : %s", d.Payload.Key(), d.Topic, err)
+			msg.DisableAutoResponse()
+			msg.Requeue(time.Duration(meta.DefaultRetryPolicy.Backoff))
+			return
+		}
+		atomic.AddInt32(&replayed, 1)
+	}); err != nil {
+		return 0, err
+	}
+	if err := cons.Connect(meta.MC.NSQd...); err != nil {
+		return 0, err
+	}
+	go cons.Start(true)
+	defer cons.Stop()
+
+	for {
+		select {
+		case <-seen:
+		case <-time.After(idle):
+			return int(atomic.LoadInt32(&replayed)), nil
+		}
+	}
+}
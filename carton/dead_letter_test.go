@@ -0,0 +1,38 @@
+package carton
+
+import (
+	"time"
+
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/toml"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRetryPolicyMostSpecificWins(c *check.C) {
+	policies := []meta.RetryPolicy{
+		{MaxAttempts: 2},
+		{Category: STATE, MaxAttempts: 3},
+		{Category: STATE, Action: CREATE, MaxAttempts: 8, Backoff: toml.Duration(time.Minute)},
+	}
+	c.Assert(retryPolicy(policies, STATE, CREATE).MaxAttempts, check.Equals, 8)
+	c.Assert(retryPolicy(policies, STATE, DESTROY).MaxAttempts, check.Equals, 3)
+	c.Assert(retryPolicy(policies, CONTROL, STOP).MaxAttempts, check.Equals, 2)
+	c.Assert(retryPolicy(nil, CONTROL, STOP), check.DeepEquals, meta.DefaultRetryPolicy)
+}
+
+func (s *S) TestRetryPolicyDefaultsBackoff(c *check.C) {
+	policies := []meta.RetryPolicy{{Category: STATE, MaxAttempts: 3}}
+	r := retryPolicy(policies, STATE, CREATE)
+	c.Assert(time.Duration(r.Backoff), check.Equals, meta.DefaultBackoff)
+	c.Assert(time.Duration(r.MaxBackoff), check.Equals, meta.DefaultMaxBackoff)
+	c.Assert(r.Delay(2), check.Equals, 2*meta.DefaultBackoff)
+}
+
+func (s *S) TestDeadLetterKeepsPayload(c *check.C) {
+	p := &Payload{Id: "RIP001", Category: STATE, Action: CREATE}
+	d := NewDeadLetter("vms", p, nil, ErrNoRollbackImage, 3)
+	c.Assert(d.Topic, check.Equals, "vms")
+	c.Assert(d.Payload, check.Equals, p)
+	c.Assert(d.Attempts, check.Equals, 3)
+	c.Assert(d.Error, check.Equals, ErrNoRollbackImage.Error())
+}
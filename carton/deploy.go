@@ -18,33 +18,61 @@ package carton
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/api"
 	"github.com/megamsys/libgo/cmd"
 	"github.com/megamsys/libgo/events/alerts"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/repository"
 	"io"
+	"sort"
 	"strings"
 	"time"
 )
 
-const DOCKER_TYPE = "dockercontainer"
+const (
+	DOCKER_TYPE = "dockercontainer"
+	DEPLOYS     = "/deployments/"
+
+	//the origins of a deploy.
+	ORIGIN_GIT      = "git"
+	ORIGIN_ROLLBACK = "rollback"
+	ORIGIN_DEPLOY   = "app-deploy"
+)
+
+var ErrNoRollbackImage = errors.New("no previous image available to rollback")
+
+type ApiDeploys struct {
+	JsonClaz string       `json:"json_claz" cql:"json_claz"`
+	Results  []DeployData `json:"results" cql:"results"`
+}
 
 type DeployData struct {
-	BoxName     string
-	HookId      string
-	PrivateIp   string
-	PublicIp    string
-	Timestamp   time.Time
-	Duration    time.Duration
-	Commit      string
-	Image       string
-	Origin      string
-	CanRollback bool
+	Id          string        `json:"id" cql:"id"`
+	AccountId   string        `json:"account_id" cql:"account_id"`
+	OrgId       string        `json:"org_id" cql:"org_id"`
+	AssemblyId  string        `json:"asm_id" cql:"asm_id"`
+	BoxId       string        `json:"box_id" cql:"box_id"`
+	BoxName     string        `json:"box_name" cql:"box_name"`
+	HookId      string        `json:"hook_id" cql:"hook_id"`
+	PrivateIp   string        `json:"private_ip" cql:"private_ip"`
+	PublicIp    string        `json:"public_ip" cql:"public_ip"`
+	Timestamp   time.Time     `json:"timestamp" cql:"timestamp"`
+	Duration    time.Duration `json:"duration" cql:"duration"`
+	Commit      string        `json:"commit" cql:"commit"`
+	Image       string        `json:"image" cql:"image"`
+	Origin      string        `json:"origin" cql:"origin"`
+	CanRollback bool          `json:"can_rollback" cql:"can_rollback"`
+	Log         string        `json:"log" cql:"log"`
+	Error       string        `json:"error" cql:"error"`
 }
 
 type DeployOpts struct {
 	B *provision.Box
+	// Image when set, redeploys the box from this image (rollback).
+	Image string
 }
 
 // Deploy runs a deployment of an application. It will first try to run an
@@ -69,6 +97,18 @@ func Deploy(opts *DeployOpts) error {
 }
 
 func deployToProvisioner(opts *DeployOpts, writer io.Writer) (string, error) {
+	if len(strings.TrimSpace(opts.Image)) > 0 {
+		deployer, ok := ProvisionerMap[opts.B.Provider].(provision.ImageDeployer)
+		if !ok {
+			return "", provision.ErrNotImplemented
+		}
+		// the running box is replaced, not deployed a second time.
+		if err := ProvisionerMap[opts.B.Provider].Destroy(opts.B, writer); err != nil {
+			return "", err
+		}
+		return deployer.ImageDeploy(opts.B, opts.Image, writer)
+	}
+
 	if opts.B.Snapshot {
 		if deployer, ok := ProvisionerMap[opts.B.Provider].(provision.ImageDeployer); ok {
 		  return deployer.ImageDeploy(opts.B, opts.B.ImageName, writer)
@@ -107,35 +147,111 @@ func saveDeployData(opts *DeployOpts, imageId, dlog string, duration time.Durati
 		cmd.Colorfy(opts.B.GetFullName(), "cyan", "", "bold"),
 		cmd.Colorfy(duration.String(), "green", "", "bold"),
 		cmd.Colorfy(dlog, "yellow", "", ""))
-	//if there are deployments to track as follows in outputs: {} then do it here.
-	//Scylla: code to save the status of a deploy (created.)
-	// deploy :
-	//     name:
-	//     status:
-
-	/*deploy := DeployData {
-		App:       opts.App.Name,
-		Timestamp: time.Now(),
-		Duration:  duration,
-		Commit:    opts.Commit,
-		Image:     imageId,
-		Log:       log,
-	}
-	if opts.Commit != "" {
-		deploy.Origin = "git"
-	} else if opts.Image != "" {
-		deploy.Origin = "rollback"
+
+	deploy := &DeployData{
+		AccountId:  opts.B.AccountId,
+		OrgId:      opts.B.OrgId,
+		AssemblyId: opts.B.CartonId,
+		BoxId:      opts.B.Id,
+		BoxName:    opts.B.GetFullName(),
+		PublicIp:   opts.B.PublicIp,
+		Timestamp:  time.Now(),
+		Duration:   duration,
+		Commit:     opts.B.Commit,
+		Image:      imageId,
+		Log:        dlog,
+	}
+	if opts.Image != "" {
+		deploy.Origin = ORIGIN_ROLLBACK
+	} else if opts.B.Commit != "" {
+		deploy.Origin = ORIGIN_GIT
 	} else {
-		deploy.Origin = "app-deploy"
+		deploy.Origin = ORIGIN_DEPLOY
 	}
 	if deployError != nil {
 		deploy.Error = deployError.Error()
+	} else if _, ok := ProvisionerMap[opts.B.Provider].(provision.ImageDeployer); ok {
+		deploy.CanRollback = len(strings.TrimSpace(imageId)) > 0
+	}
+	return deploy.create()
+}
+
+func (d *DeployData) create() error {
+	cl := api.NewClient(newArgs(d.AccountId, d.OrgId), DEPLOYS+"content")
+	if _, err := cl.Post(d); err != nil {
+		return err
 	}
-	return db.Store(compid or assmid, &struct)
-	*/
 	return nil
 }
 
+// ListDeploys returns the deploy history of an assembly or a component,
+// the latest deploy first.
+func ListDeploys(id, email string) ([]DeployData, error) {
+	cl := api.NewClient(newArgs(email, ""), DEPLOYS+id)
+	response, err := cl.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ApiDeploys{}
+	err = json.Unmarshal(response, res)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byTimestamp(res.Results))
+	return res.Results, nil
+}
+
+// Rollback replaces a box by one from the image of the last good deploy
+// before the current one.
+func Rollback(opts *DeployOpts) error {
+	deploys, err := ListDeploys(opts.B.Id, opts.B.AccountId)
+	if err != nil {
+		return err
+	}
+	d, err := rollbackTarget(deploys)
+	if err != nil {
+		return err
+	}
+	log.Debugf("  rollback box (%s) to image %s deployed at %s", opts.B.GetFullName(), d.Image, d.Timestamp)
+	return Deploy(&DeployOpts{B: opts.B, Image: d.Image})
+}
+
+//picks the rollbackable deploy that succeeded before the current one, not of
+//the current image. A rollback undid the deploy before it, so it is skipped
+//along with that one, the next rollback going further back.
+//the deploys are expected to be sorted, latest first.
+func rollbackTarget(deploys []DeployData) (*DeployData, error) {
+	current := ""
+	skip := 1
+	for i := range deploys {
+		d := &deploys[i]
+		if d.Error != "" {
+			continue
+		}
+		if current == "" {
+			current = d.Image
+		}
+		if d.Origin == ORIGIN_ROLLBACK {
+			skip++
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		if d.CanRollback && d.Image != current {
+			return d, nil
+		}
+	}
+	return nil, ErrNoRollbackImage
+}
+
+type byTimestamp []DeployData
+
+func (b byTimestamp) Len() int           { return len(b) }
+func (b byTimestamp) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byTimestamp) Less(i, j int) bool { return b[i].Timestamp.After(b[j].Timestamp) }
 
 // Deploy runs a deployment of an application. It will first try to run an
 // image based deploy, and then fallback to the Git based deployment.
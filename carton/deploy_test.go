@@ -15,11 +15,11 @@
  */
 package carton
 
-/*
 import (
 	"gopkg.in/check.v1"
 )
 
+/*
 func (s *S) TestDeployToProvisioner(c *check.C) {
 	carton := provisiontest.NewFakeCarton("myapp", "tosca.torpedo.ubuntu", provision.BoxNone, 1)
 	defer s.provisioner.Destroy(&box)
@@ -42,3 +42,58 @@ func (s *S) TestDeployToProvisionerImage(c *check.C) {
 	c.Assert(logs, check.Equals, "Image deploy called")
 }
 */
+
+func (s *S) TestRollbackTargetSkipsCurrentImage(c *check.C) {
+	deploys := []DeployData{
+		{Image: "app:v3", CanRollback: true},
+		{Image: "app:v2", Error: "boom"},
+		{Image: "app:v3", CanRollback: true},
+		{Image: "app:v1", CanRollback: true},
+	}
+	d, err := rollbackTarget(deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Image, check.Equals, "app:v1")
+}
+
+func (s *S) TestRollbackTargetWithoutHistory(c *check.C) {
+	deploys := []DeployData{{Image: "app:v1", CanRollback: true}}
+	_, err := rollbackTarget(deploys)
+	c.Assert(err, check.Equals, ErrNoRollbackImage)
+}
+
+func (s *S) TestRollbackTargetGoesFurtherBackAfterARollback(c *check.C) {
+	deploys := []DeployData{
+		{Image: "app:v3", CanRollback: true},
+		{Image: "app:v2", CanRollback: true},
+		{Image: "app:v1", CanRollback: true},
+	}
+	d, err := rollbackTarget(deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Image, check.Equals, "app:v2")
+	deploys = append([]DeployData{{Image: "app:v2", Origin: ORIGIN_ROLLBACK, CanRollback: true}}, deploys...)
+	d, err = rollbackTarget(deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Image, check.Equals, "app:v1")
+	deploys = append([]DeployData{{Image: "app:v1", Origin: ORIGIN_ROLLBACK, CanRollback: true}}, deploys...)
+	_, err = rollbackTarget(deploys)
+	c.Assert(err, check.Equals, ErrNoRollbackImage)
+}
+
+func (s *S) TestRollbackTargetAfterADeployOverARollback(c *check.C) {
+	deploys := []DeployData{
+		{Image: "app:v4", CanRollback: true},
+		{Image: "app:v2", Origin: ORIGIN_ROLLBACK, CanRollback: true},
+		{Image: "app:v3", CanRollback: true},
+		{Image: "app:v2", CanRollback: true},
+		{Image: "app:v1", CanRollback: true},
+	}
+	d, err := rollbackTarget(deploys)
+	c.Assert(err, check.IsNil)
+	c.Assert(d.Image, check.Equals, "app:v2")
+}
+
+func (s *S) TestParseRollbackRequest(c *check.C) {
+	p, err := NewReqParser("ASM001").ParseRequest(OPERATIONS, ROLLBACK)
+	c.Assert(err, check.IsNil)
+	c.Assert(p.String(), check.Equals, "ROLLBACK CARTON ASM001")
+}
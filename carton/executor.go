@@ -0,0 +1,149 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/provision"
+)
+
+// DefaultBoxWorkers is the number of boxes operated at once when it isn't configured.
+const DefaultBoxWorkers = 5
+
+type boxFunc func(*provision.Box) error
+
+// BoxResult is the outcome of an operation on a single box.
+type BoxResult struct {
+	BoxId    string
+	Name     string
+	Err      error
+	Skipped  bool
+	Duration time.Duration
+}
+
+// BoxError represents an operation that failed on a box.
+type BoxError struct {
+	BoxId string
+	Name  string
+	Err   error
+}
+
+func (e *BoxError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", e.Name, e.BoxId, e.Err)
+}
+
+// MultiError aggregates the errors of all the failed boxes of an operation.
+type MultiError struct {
+	Op     string
+	Errors []*BoxError
+}
+
+func (m *MultiError) Error() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s failed on %d box(es)", m.Op, len(m.Errors))
+	for _, e := range m.Errors {
+		_, _ = buf.WriteString("\n  ")
+		_, _ = buf.WriteString(e.Error())
+	}
+	return buf.String()
+}
+
+// BoxExecutor runs an operation across the boxes of a carton using a bounded
+// number of workers. When ContinueOnError is false, no new box is started
+// after the first failure, the boxes already running are left to finish.
+type BoxExecutor struct {
+	Workers         int
+	ContinueOnError bool
+}
+
+// NewBoxExecutor returns an executor configured from the meta config.
+func NewBoxExecutor() *BoxExecutor {
+	e := &BoxExecutor{Workers: DefaultBoxWorkers}
+	if meta.MC != nil {
+		if meta.MC.BoxWorkers > 0 {
+			e.Workers = meta.MC.BoxWorkers
+		}
+		e.ContinueOnError = meta.MC.ContinueOnError
+	}
+	return e
+}
+
+// Run executes fn on every box and returns the per box results in the order
+// of the boxes. The error is a *MultiError when at least one box failed.
+func (e *BoxExecutor) Run(op string, boxes []provision.Box, fn boxFunc) ([]BoxResult, error) {
+	results := make([]BoxResult, len(boxes))
+	if len(boxes) == 0 {
+		return results, nil
+	}
+	workers := e.Workers
+	if workers <= 0 || workers > len(boxes) {
+		workers = len(boxes)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		failed int32
+	)
+	jobs := make(chan int)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				box := boxes[i]
+				start := time.Now()
+				err := fn(&box)
+				results[i] = BoxResult{
+					BoxId:    box.Id,
+					Name:     box.GetFullName(),
+					Err:      err,
+					Duration: time.Since(start),
+				}
+				if err != nil {
+					log.Errorf("Unable to %s the box %s : %s", op, box.GetFullName(), err)
+					atomic.StoreInt32(&failed, 1)
+				}
+			}
+		}()
+	}
+	for i := range boxes {
+		if !e.ContinueOnError && atomic.LoadInt32(&failed) == 1 {
+			results[i] = BoxResult{BoxId: boxes[i].Id, Name: boxes[i].GetFullName(), Skipped: true}
+			continue
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	merr := &MultiError{Op: op}
+	for _, r := range results {
+		if r.Err != nil {
+			merr.Errors = append(merr.Errors, &BoxError{BoxId: r.BoxId, Name: r.Name, Err: r.Err})
+		}
+	}
+	if len(merr.Errors) > 0 {
+		return results, merr
+	}
+	return results, nil
+}
@@ -0,0 +1,57 @@
+package carton
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+func fakeBoxes(n int) []provision.Box {
+	boxes := make([]provision.Box, n)
+	for i := range boxes {
+		boxes[i] = provision.Box{Id: string('a' + rune(i)), CartonName: "box" + string('a'+rune(i))}
+	}
+	return boxes
+}
+
+func (s *S) TestBoxExecutorRunsAllBoxes(c *check.C) {
+	var count int32
+	e := &BoxExecutor{Workers: 2}
+	results, err := e.Run("start", fakeBoxes(5), func(b *provision.Box) error {
+		atomic.AddInt32(&count, 1)
+		return nil
+	})
+	c.Assert(err, check.IsNil)
+	c.Assert(results, check.HasLen, 5)
+	c.Assert(atomic.LoadInt32(&count), check.Equals, int32(5))
+	c.Assert(results[2].BoxId, check.Equals, "c")
+}
+
+func (s *S) TestBoxExecutorContinueOnError(c *check.C) {
+	e := &BoxExecutor{Workers: 1, ContinueOnError: true}
+	results, err := e.Run("stop", fakeBoxes(3), func(b *provision.Box) error {
+		if b.Id == "a" {
+			return errors.New("boom")
+		}
+		return nil
+	})
+	c.Assert(err, check.NotNil)
+	merr, ok := err.(*MultiError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(merr.Errors, check.HasLen, 1)
+	c.Assert(merr.Errors[0].BoxId, check.Equals, "a")
+	c.Assert(results[1].Err, check.IsNil)
+	c.Assert(results[2].Skipped, check.Equals, false)
+}
+
+func (s *S) TestBoxExecutorStopsAfterFailure(c *check.C) {
+	e := &BoxExecutor{Workers: 1}
+	results, err := e.Run("deploy", fakeBoxes(3), func(b *provision.Box) error {
+		return errors.New("boom")
+	})
+	c.Assert(err, check.NotNil)
+	c.Assert(results[0].Err, check.NotNil)
+	c.Assert(results[2].Skipped, check.Equals, true)
+}
@@ -34,6 +34,9 @@ func (s CreateProcess) String() string {
 
 func (s CreateProcess) Process(ca Cartons) error {
 	for _, c := range ca {
+		if err := Suspensions.Allow(c.AccountId); err != nil {
+			return err
+		}
 		if err := c.Deploy(); err != nil {
 			return err
 		}
@@ -58,6 +61,7 @@ func (s DestroyProcess) Process(ca Cartons) error {
 		if err := c.Destroy(); err != nil {
 			return err
 		}
+		Suspensions.Forget(c.AccountId, c.Id)
 	}
 	return nil
 }
@@ -76,6 +80,9 @@ func (s StartProcess) String() string {
 
 func (s StartProcess) Process(ca Cartons) error {
 	for _, c := range ca {
+		if err := Suspensions.Allow(c.AccountId); err != nil {
+			return err
+		}
 		if err := c.Start(); err != nil {
 			return err
 		}
@@ -118,6 +125,9 @@ func (s RestartProcess) String() string {
 
 func (s RestartProcess) Process(ca Cartons) error {
 	for _, c := range ca {
+		if err := Suspensions.Allow(c.AccountId); err != nil {
+			return err
+		}
 		if err := c.Restart(); err != nil {
 			return err
 		}
@@ -139,6 +149,9 @@ func (s UpgradeProcess) String() string {
 
 func (s UpgradeProcess) Process(ca Cartons) error {
 	for _, c := range ca {
+		if err := Suspensions.Allow(c.AccountId); err != nil {
+			return err
+		}
 		if err := c.Upgrade(); err != nil {
 			return err
 		}
@@ -146,6 +159,30 @@ func (s UpgradeProcess) Process(ca Cartons) error {
 	return nil
 }
 
+// RollbackProcess represents a command for rolling back cartons to a previous image.
+type RollbackProcess struct {
+	Name string
+}
+
+func (s RollbackProcess) String() string {
+	var buf bytes.Buffer
+	_, _ = buf.WriteString("ROLLBACK CARTON ")
+	_, _ = buf.WriteString(s.Name)
+	return buf.String()
+}
+
+func (s RollbackProcess) Process(ca Cartons) error {
+	for _, c := range ca {
+		if err := Suspensions.Allow(c.AccountId); err != nil {
+			return err
+		}
+		if err := c.Rollback(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // StateupProcess represents a command for restarting  cartons.
 type StateupProcess struct {
 	Name string
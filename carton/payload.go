@@ -64,6 +64,15 @@ func (p *Payload) Convert() (*Requests, error) {
 
 }
 
+// Key identifies the payload in the request ledger. A payload carrying the
+// request as value has no id, hence its content is used.
+func (p *Payload) Key() string {
+	if len(strings.TrimSpace(p.Id)) > 0 {
+		return p.Id
+	}
+	return strings.Join([]string{p.CatId, p.Category, p.Action, p.CreatedAt.String()}, "/")
+}
+
 //The payload in the queue can be just a pointer or a value.
 //pointer means just the id will be available and rest is blank.
 //value means the id is blank and others are available.
@@ -1,11 +1,84 @@
 package carton
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/api"
 	"github.com/megamsys/libgo/pairs"
-	"encoding/json"
+	"github.com/megamsys/vertice/provision"
+)
+
+// the limits allowed by a quota, ram and disk are sizes (2 GB, 512 MB).
+const (
+	QUOTA_CPU       = "cpu"
+	QUOTA_RAM       = "ram"
+	QUOTA_DISK      = "disk"
+	QUOTA_INSTANCES = "instances"
+
+	// DefaultQuotaInstances is the number of boxes a quota is allocated to,
+	// when it doesn't allow instances.
+	DefaultQuotaInstances = 1
 )
 
+// QuotaExceededError is returned when a box asks for more than its quota.
+type QuotaExceededError struct {
+	QuotaId   string
+	Resource  string
+	Requested uint64
+	Allowed   uint64
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota %s exceeded: %s of %d requested, only %d allowed", e.QuotaId, e.Resource, e.Requested, e.Allowed)
+}
+
+// quotaLocks serializes the read-modify-write of a quota in this vertice, so
+// that the boxes created at once don't overwrite each other's allocation.
+var quotaLocks = newKeyedMutex()
+
+// keyedMutex locks per key, the unused keys are forgotten.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedLock
+}
+
+type keyedLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newKeyedMutex() *keyedMutex {
+	return &keyedMutex{locks: make(map[string]*keyedLock)}
+}
+
+// Lock locks the key and returns its unlock.
+func (k *keyedMutex) Lock(key string) func() {
+	k.mu.Lock()
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyedLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
+
 type Quota struct {
 	Id          string          `json:"id" cql:"id"`
 	AccountId   string          `json:"account_id" cql:"account_id"`
@@ -59,6 +132,146 @@ func (q *Quota) get(args api.ApiArgs) (*Quota, error) {
 }
 
 
+// Allocations returns the assemblies the quota is allocated to.
+func (q *Quota) Allocations() []string {
+	var ids []string
+	for _, id := range strings.Split(q.AllocatedTo, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Allocate adds the assembly to the ones the quota is allocated to.
+func (q *Quota) Allocate(id string) {
+	ids := q.Allocations()
+	for _, a := range ids {
+		if a == id {
+			return
+		}
+	}
+	q.AllocatedTo = strings.Join(append(ids, id), ",")
+}
+
+// Deallocate removes the assembly from the ones the quota is allocated to.
+func (q *Quota) Deallocate(id string) {
+	var ids []string
+	for _, a := range q.Allocations() {
+		if a != id {
+			ids = append(ids, a)
+		}
+	}
+	q.AllocatedTo = strings.Join(ids, ",")
+}
+
+// Allocated returns the compute of the assemblies the quota is allocated to,
+// but the one of except. The assemblies gone are skipped.
+func (q *Quota) Allocated(except string) []provision.BoxCompute {
+	var computes []provision.BoxCompute
+	for _, id := range q.Allocations() {
+		if id == except {
+			continue
+		}
+		asm, err := NewAssembly(id, q.AccountId, "")
+		if err != nil {
+			log.Warnf("  quota %s skips the assembly %s : %s", q.Id, id, err)
+			continue
+		}
+		computes = append(computes, asm.newCompute())
+	}
+	return computes
+}
+
+// Check returns a QuotaExceededError when the cores, ram, disk of the box
+// added to the allocated ones of the other boxes, or the number of boxes the
+// quota is allocated to would go over the allowed ones. The limits not
+// allowed by the quota aren't checked.
+func (q *Quota) Check(b *provision.Box, allocated []provision.BoxCompute) error {
+	limits := &provision.Box{Compute: provision.BoxCompute{
+		Cpushare: q.Allowed.Match(QUOTA_CPU),
+		Memory:   q.Allowed.Match(QUOTA_RAM),
+		HDD:      q.Allowed.Match(QUOTA_DISK),
+	}}
+	cpu, ram, disk := b.GetCpushare(), b.GetMemory(), b.GetHDD()
+	for _, c := range allocated {
+		a := &provision.Box{Compute: c}
+		cpu, ram, disk = cpu+a.GetCpushare(), ram+a.GetMemory(), disk+a.GetHDD()
+	}
+	checks := []struct {
+		resource           string
+		requested, allowed uint64
+	}{
+		{QUOTA_CPU, cpu, limits.GetCpushare()},
+		{QUOTA_RAM, ram, limits.GetMemory()},
+		{QUOTA_DISK, disk, limits.GetHDD()},
+	}
+	for _, c := range checks {
+		if q.Allowed.Match(c.resource) != "" && c.requested > c.allowed {
+			return &QuotaExceededError{QuotaId: q.Id, Resource: c.resource, Requested: c.requested, Allowed: c.allowed}
+		}
+	}
+
+	instances := uint64(DefaultQuotaInstances)
+	if v := q.Allowed.Match(QUOTA_INSTANCES); v != "" {
+		n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("quota %s allows unparsable instances %s", q.Id, v)
+		}
+		instances = n
+	}
+	requested := uint64(1)
+	for _, id := range q.Allocations() {
+		if id != b.CartonId {
+			requested++
+		}
+	}
+	if requested > instances {
+		return &QuotaExceededError{QuotaId: q.Id, Resource: QUOTA_INSTANCES, Requested: requested, Allowed: instances}
+	}
+	return nil
+}
+
+// EnforceQuota fails the creation of a box over its quota, notifying the
+// account with a QUOTA_EXCEEDED event, else it allocates the quota to the
+// box. The boxes without a quota pass.
+func EnforceQuota(b *provision.Box, w io.Writer) error {
+	if len(b.QuotaId) == 0 {
+		return nil
+	}
+	unlock := quotaLocks.Lock(b.QuotaId)
+	defer unlock()
+	q, err := NewQuota(b.AccountId, b.QuotaId)
+	if err != nil {
+		return err
+	}
+	if err = q.Check(b, q.Allocated(b.CartonId)); err != nil {
+		if _, ok := err.(*QuotaExceededError); ok {
+			log.Debugf(" %s for the user (%s)", err, b.AccountId)
+			DoneNotify(b, w, provision.QUOTA_EXCEEDED)
+		}
+		return err
+	}
+	q.Allocate(b.CartonId)
+	return q.Update()
+}
+
+// ReleaseQuota gives back the quota allocated to a box destroyed or not
+// created.
+func ReleaseQuota(b *provision.Box) error {
+	if len(b.QuotaId) == 0 {
+		return nil
+	}
+	unlock := quotaLocks.Lock(b.QuotaId)
+	defer unlock()
+	q, err := NewQuota(b.AccountId, b.QuotaId)
+	if err != nil {
+		return err
+	}
+	q.Deallocate(b.CartonId)
+	return q.Update()
+}
+
 func (q *Quota) ContainerQuota() (bool, error) {
 	asm, err := NewAssembly(q.AllocatedTo, q.AccountId, "")
 	if err != nil {
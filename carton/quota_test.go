@@ -13,3 +13,137 @@ func (s *S) TestGetQuota(c *check.C) {
   c.Assert(err, check.IsNil)
 }
 */
+
+import (
+	"sync"
+	"time"
+
+	"github.com/megamsys/libgo/pairs"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+func allowed(m map[string][]string) pairs.JsonPairs {
+	js := make(pairs.JsonPairs, 0)
+	js.NukeAndSet(m)
+	return js
+}
+
+func quotaBox(cpu, ram, hdd string) *provision.Box {
+	return &provision.Box{
+		CartonId: "ASM001",
+		Compute:  provision.BoxCompute{Cpushare: cpu, Memory: ram, HDD: hdd},
+	}
+}
+
+func (s *S) TestQuotaCheckWithin(c *check.C) {
+	q := &Quota{Id: "QUO001", Allowed: allowed(map[string][]string{
+		QUOTA_CPU:  []string{"2 Cores"},
+		QUOTA_RAM:  []string{"2 GB"},
+		QUOTA_DISK: []string{"20 GB"},
+	})}
+	c.Assert(q.Check(quotaBox("2", "2 GB", "20 GB"), nil), check.IsNil)
+	c.Assert(q.Check(quotaBox("1", "1 GB", "10 GB"), nil), check.IsNil)
+}
+
+func (s *S) TestQuotaCheckExceeded(c *check.C) {
+	q := &Quota{Id: "QUO001", Allowed: allowed(map[string][]string{
+		QUOTA_CPU: []string{"2"},
+		QUOTA_RAM: []string{"2 GB"},
+	})}
+	err := q.Check(quotaBox("4", "1 GB", "10 GB"), nil)
+	e, ok := err.(*QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Resource, check.Equals, QUOTA_CPU)
+	c.Assert(e.Requested, check.Equals, uint64(4))
+	c.Assert(e.Allowed, check.Equals, uint64(2))
+
+	err = q.Check(quotaBox("2", "4 GB", "10 GB"), nil)
+	e, ok = err.(*QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Resource, check.Equals, QUOTA_RAM)
+	c.Assert(e.Requested, check.Equals, uint64(4096))
+}
+
+func (s *S) TestQuotaCheckNotAllowedIsUnlimited(c *check.C) {
+	q := &Quota{Id: "QUO001", Allowed: allowed(map[string][]string{QUOTA_CPU: []string{"2"}})}
+	c.Assert(q.Check(quotaBox("2", "64 GB", "2000 GB"), nil), check.IsNil)
+}
+
+func (s *S) TestQuotaCheckInstances(c *check.C) {
+	q := &Quota{Id: "QUO001", Allowed: allowed(map[string][]string{})}
+	c.Assert(q.Check(quotaBox("1", "1 GB", "10 GB"), nil), check.IsNil)
+	q.Allocate("ASM001")
+	c.Assert(q.Check(quotaBox("1", "1 GB", "10 GB"), nil), check.IsNil)
+	q.Allocate("ASM002")
+	err := q.Check(quotaBox("1", "1 GB", "10 GB"), nil)
+	e, ok := err.(*QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Resource, check.Equals, QUOTA_INSTANCES)
+
+	q.Allowed = allowed(map[string][]string{QUOTA_INSTANCES: []string{"3"}})
+	c.Assert(q.Check(quotaBox("1", "1 GB", "10 GB"), nil), check.IsNil)
+}
+
+func (s *S) TestQuotaAllocate(c *check.C) {
+	q := &Quota{}
+	q.Allocate("ASM001")
+	q.Allocate("ASM002")
+	q.Allocate("ASM001")
+	c.Assert(q.AllocatedTo, check.Equals, "ASM001,ASM002")
+	c.Assert(q.Allocations(), check.DeepEquals, []string{"ASM001", "ASM002"})
+	q.Deallocate("ASM001")
+	c.Assert(q.AllocatedTo, check.Equals, "ASM002")
+	q.Deallocate("ASM003")
+	c.Assert(q.AllocatedTo, check.Equals, "ASM002")
+}
+
+func (s *S) TestQuotaCheckAddsTheAllocated(c *check.C) {
+	q := &Quota{Id: "QUO001", Allowed: allowed(map[string][]string{
+		QUOTA_CPU:       []string{"4"},
+		QUOTA_RAM:       []string{"4 GB"},
+		QUOTA_INSTANCES: []string{"3"},
+	})}
+	allocated := []provision.BoxCompute{{Cpushare: "2", Memory: "2 GB"}}
+	c.Assert(q.Check(quotaBox("2", "2 GB", "10 GB"), allocated), check.IsNil)
+	allocated = append(allocated, provision.BoxCompute{Cpushare: "1", Memory: "1 GB"})
+	err := q.Check(quotaBox("2", "1 GB", "10 GB"), allocated)
+	e, ok := err.(*QuotaExceededError)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Resource, check.Equals, QUOTA_CPU)
+	c.Assert(e.Requested, check.Equals, uint64(5))
+	c.Assert(e.Allowed, check.Equals, uint64(4))
+}
+
+func (s *S) TestKeyedMutexSerializesAKey(c *check.C) {
+	k := newKeyedMutex()
+	var (
+		mu      sync.Mutex
+		holders int
+		most    int
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock := k.Lock("QUO001")
+			defer unlock()
+			mu.Lock()
+			holders++
+			if holders > most {
+				most = holders
+			}
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+		}()
+	}
+	unlock := k.Lock("QUO002") //another quota isn't held up.
+	unlock()
+	wg.Wait()
+	c.Assert(most, check.Equals, 1)
+	c.Assert(k.locks, check.HasLen, 0)
+}
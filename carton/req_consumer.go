@@ -0,0 +1,144 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	nsq "github.com/crackcomm/nsqueue/consumer"
+)
+
+// TouchInterval is how often the messages in flight are touched, it must be
+// below the msg timeout of nsqd (60s by default) so they aren't redelivered.
+var TouchInterval = 30 * time.Second
+
+// ReqConsumer handles the requests received on a nsq topic. The requests of
+// an assembly are run in order through the Queue, their messages are touched
+// till they are finished, requeued or dead lettered.
+type ReqConsumer struct {
+	Topic    string
+	Serve    func(*Requests) error
+	Assembly func(*Requests) (string, error) //resolves the assembly queued on.
+	wg       sync.WaitGroup
+}
+
+func NewReqConsumer(topic string, serve func(*Requests) error) *ReqConsumer {
+	return &ReqConsumer{Topic: topic, Serve: serve, Assembly: AssemblyOf}
+}
+
+// Process is the nsq handler of the topic.
+func (rc *ReqConsumer) Process(msg *nsq.Message) {
+	log.Debugf(rc.Topic + "queue received message  :" + string(msg.Body))
+	MessagesReceived.Inc(rc.Topic)
+	p, err := NewPayload(msg.Body)
+	if err != nil {
+		return
+	}
+	msg.DisableAutoResponse()
+	re, err := p.Convert()
+	if err != nil {
+		log.Errorf("%s", err)
+		rc.retry(msg, p, nil, err)
+		return
+	}
+	stop := touch(msg, TouchInterval)
+	err = rc.submit(p, re, func(err error) {
+		close(stop)
+		rc.retry(msg, p, re, err)
+	})
+	if err == nil {
+		return
+	}
+	close(stop)
+	if err == ErrDuplicateRequest {
+		log.Warnf("%s skipped request %s : %s", rc.Topic, p.Key(), err)
+		msg.Finish()
+		return
+	}
+	// a conflicting one is redelivered after the running one, which may fail.
+	rc.retry(msg, p, re, err)
+}
+
+// submit queues the request behind the requests of its assembly, whatever
+// their category, done is called with the error of its serving.
+func (rc *ReqConsumer) submit(p *Payload, re *Requests, done func(error)) error {
+	asm, err := rc.Assembly(re)
+	if err != nil {
+		return err
+	}
+	if err = Ledger.Begin(p.Key(), asm, re); err != nil {
+		return err
+	}
+	rc.wg.Add(1)
+	depth := Queue.Submit(asm, func() {
+		defer rc.wg.Done()
+		Ledger.Run(p.Key())
+		err := rc.Serve(re)
+		Ledger.Done(p.Key(), err)
+		done(err)
+	})
+	log.Debugf("%s queued request %s on %s (depth %d)", rc.Topic, p.Key(), asm, depth)
+	return nil
+}
+
+// Wait blocks till the requests being served are done.
+func (rc *ReqConsumer) Wait() {
+	rc.wg.Wait()
+}
+
+// retry redelivers the message later when the error is a transient one or a
+// conflict and the retry policy of the request allows it, else the request is
+// dead lettered.
+func (rc *ReqConsumer) retry(msg *nsq.Message, p *Payload, re *Requests, err error) {
+	if err == nil {
+		msg.Finish()
+		return
+	}
+	category, action := p.Category, p.Action
+	if re != nil {
+		category, action = re.Category, re.Action
+	}
+	policy := RetryPolicyFor(category, action)
+	if (IsTransient(err) || err == ErrConflictingRequest) && int(msg.Attempts) < policy.MaxAttempts {
+		log.Warnf("%s requeue message (attempt %d) : %s", rc.Topic, msg.Attempts, err)
+		msg.Requeue(policy.Delay(int(msg.Attempts)))
+		return
+	}
+	if derr := NewDeadLetter(rc.Topic, p, re, err, int(msg.Attempts)).Publish(); derr != nil {
+		log.Errorf("%s unable to dead letter %s : %s", rc.Topic, p.Key(), derr)
+	}
+	msg.Finish()
+}
+
+// touch resets the timeout of the message every interval till stop is closed.
+func touch(msg *nsq.Message, interval time.Duration) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				msg.Touch()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return stop
+}
@@ -0,0 +1,45 @@
+package carton
+
+import (
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReqConsumerQueuesTheSnapshotAndTheDestroyOfAnAssembly(c *check.C) {
+	defer func(l *RequestLedger) { Ledger = l }(Ledger)
+	Ledger = NewRequestLedger(time.Hour)
+	release := make(chan bool)
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	rc := &ReqConsumer{
+		Topic: "vms",
+		Assembly: func(r *Requests) (string, error) {
+			return "ASM101", nil
+		},
+		Serve: func(r *Requests) error {
+			if r.Category == SNAPSHOT {
+				<-release
+			}
+			mu.Lock()
+			order = append(order, r.Action)
+			mu.Unlock()
+			return nil
+		},
+	}
+	done := make(chan error, 2)
+	finish := func(err error) { done <- err }
+	snap := &Payload{Id: "RIP101", CatId: "SNP101", Category: SNAPSHOT, Action: SNAPCREATE}
+	destroy := &Payload{Id: "RIP102", CatId: "AMS101", Category: STATE, Action: DESTROY}
+	c.Assert(rc.submit(snap, &Requests{CatId: snap.CatId, Category: snap.Category, Action: snap.Action}, finish), check.IsNil)
+	c.Assert(rc.submit(destroy, &Requests{CatId: destroy.CatId, Category: destroy.Category, Action: destroy.Action}, finish), check.IsNil)
+	c.Assert(Queue.Depth("ASM101"), check.Equals, 2)
+	close(release)
+	c.Assert(<-done, check.IsNil)
+	c.Assert(<-done, check.IsNil)
+	rc.Wait()
+	c.Assert(order, check.DeepEquals, []string{SNAPCREATE, DESTROY})
+}
@@ -0,0 +1,328 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/stats"
+)
+
+const (
+	// DefaultLedgerTTL is how long a finished request is remembered.
+	DefaultLedgerTTL = 24 * time.Hour
+
+	// DefaultLedgerJournal is the journal of the requests in the vertice dir.
+	DefaultLedgerJournal = "requests.journal"
+)
+
+type ReqStatus string
+
+const (
+	ReqInFlight  ReqStatus = "inflight"
+	ReqCompleted ReqStatus = "completed"
+	ReqFailed    ReqStatus = "failed"
+)
+
+var (
+	ErrDuplicateRequest   = errors.New("request was already processed")
+	ErrConflictingRequest = errors.New("the same action is already running on the assembly")
+	errInterrupted        = errors.New("interrupted by a restart")
+)
+
+// LedgerEntry records the processing of a single request.
+type LedgerEntry struct {
+	Id        string
+	CatId     string
+	AsmId     string //the assembly operated, the cat_id resolved.
+	Category  string
+	Action    string
+	Status    ReqStatus
+	Attempts  int
+	Err       string
+	StartedAt time.Time
+	RunningAt time.Time //when the current attempt left the queue.
+	UpdatedAt time.Time
+}
+
+// RequestLedger tracks the requests received from the queues, so that a
+// redelivered or a double published request isn't operated twice.
+//
+// Once opened, every change of an entry is journaled, so that a request
+// redelivered after a restart is still known. The requests inflight when
+// vertice stopped are replayed as failed, their redelivery runs again.
+type RequestLedger struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*LedgerEntry
+	running map[string]string //asmid/action => id of the exclusive request operating it.
+	journal *os.File
+}
+
+var (
+	// MessagesReceived counts the messages received per nsq topic.
+	MessagesReceived = stats.NewCounter("vertice_nsq_messages_total", "Messages received from the nsq topics.", "topic")
+
+	requestDuration = stats.NewHistogram("vertice_request_duration_seconds", "Time taken to operate a request.", nil, "category", "action")
+	requestFailures = stats.NewCounter("vertice_request_failures_total", "Requests failed to be operated.", "category", "action")
+)
+
+// Ledger is the request ledger shared by the subd daemons.
+var Ledger = NewRequestLedger(DefaultLedgerTTL)
+
+func NewRequestLedger(ttl time.Duration) *RequestLedger {
+	return &RequestLedger{
+		ttl:     ttl,
+		entries: make(map[string]*LedgerEntry),
+		running: make(map[string]string),
+	}
+}
+
+// Open replays the journal at path and journals the requests to come in it.
+func (l *RequestLedger) Open(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.journal != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := l.replay(path); err != nil {
+		return err
+	}
+	l.purge()
+	if err := l.compact(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	l.journal = f
+	return nil
+}
+
+func (l *RequestLedger) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		e := &LedgerEntry{}
+		if err := json.Unmarshal(sc.Bytes(), e); err != nil {
+			log.Warnf("  requests journal %s skips a line : %s", path, err)
+			continue
+		}
+		l.entries[e.Id] = e
+	}
+	for _, e := range l.entries {
+		if e.Status == ReqInFlight {
+			e.Status = ReqFailed
+			e.Err = errInterrupted.Error()
+		}
+	}
+	return sc.Err()
+}
+
+// compact rewrites the journal at path with the entries remembered.
+func (l *RequestLedger) compact(path string) error {
+	var ids []string
+	for id := range l.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	tmp := path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, id := range ids {
+		if err = enc.Encode(l.entries[id]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// record journals the entry, it is kept in memory when it can't be journaled.
+func (l *RequestLedger) record(e *LedgerEntry) {
+	if l.journal == nil {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err == nil {
+		_, err = l.journal.Write(append(b, '\n'))
+	}
+	if err != nil {
+		log.Errorf("  requests journal of %s : %s", e.Id, err)
+	}
+}
+
+// Begin records the request as inflight. It fails when the request is already
+// inflight or completed, or when it is a create/destroy and the same action is
+// already running on the assembly asm. A failed request can begin again.
+func (l *RequestLedger) Begin(id, asm string, r *Requests) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.purge()
+
+	e, ok := l.entries[id]
+	if ok && e.Status != ReqFailed {
+		return ErrDuplicateRequest
+	}
+	if isExclusive(r) {
+		if rid, running := l.running[runningKey(asm, r.Action)]; running && rid != id {
+			return ErrConflictingRequest
+		}
+		l.running[runningKey(asm, r.Action)] = id
+	}
+	if !ok {
+		e = &LedgerEntry{
+			Id:        id,
+			CatId:     r.CatId,
+			Category:  r.Category,
+			Action:    r.Action,
+			StartedAt: time.Now(),
+		}
+		l.entries[id] = e
+	}
+	e.AsmId = asm
+	e.Status = ReqInFlight
+	e.Attempts++
+	e.Err = ""
+	e.RunningAt = time.Time{}
+	e.UpdatedAt = time.Now()
+	l.record(e)
+	return nil
+}
+
+// Run marks the request as running, its duration is measured from now on.
+func (l *RequestLedger) Run(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[id]; ok {
+		e.RunningAt = time.Now()
+	}
+}
+
+// Done marks the request as completed, or failed when err isn't nil.
+func (l *RequestLedger) Done(id string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.entries[id]
+	if !ok {
+		return
+	}
+	if !e.RunningAt.IsZero() {
+		requestDuration.Observe(time.Since(e.RunningAt).Seconds(), e.Category, e.Action)
+	}
+	if err != nil {
+		requestFailures.Inc(e.Category, e.Action)
+		e.Status = ReqFailed
+		e.Err = err.Error()
+	} else {
+		e.Status = ReqCompleted
+	}
+	e.UpdatedAt = time.Now()
+	l.record(e)
+	if k := runningKey(e.AsmId, e.Action); l.running[k] == id {
+		delete(l.running, k)
+	}
+}
+
+// Get returns a copy of the entry of a request.
+func (l *RequestLedger) Get(id string) (LedgerEntry, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.entries[id]; ok {
+		return *e, true
+	}
+	return LedgerEntry{}, false
+}
+
+// forget the finished requests older than the ttl.
+func (l *RequestLedger) purge() {
+	for id, e := range l.entries {
+		if e.Status != ReqInFlight && time.Since(e.UpdatedAt) > l.ttl {
+			delete(l.entries, id)
+		}
+	}
+}
+
+func runningKey(asmId, action string) string {
+	return asmId + "/" + action
+}
+
+// a create or a destroy can't run twice at once on the same assembly.
+func isExclusive(r *Requests) bool {
+	return r.Category == STATE && (r.Action == CREATE || r.Action == DESTROY)
+}
+
+// IsTransient returns true when the error is worth retrying later, like
+// a provisioner or the gateway being unreachable.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch e := err.(type) {
+	case *url.Error:
+		return true
+	case net.Error:
+		return e.Temporary() || e.Timeout()
+	case *MultiError:
+		for _, be := range e.Errors {
+			if !IsTransient(be.Err) {
+				return false
+			}
+		}
+		return len(e.Errors) > 0
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "connection refused") ||
+		strings.Contains(msg, "connection reset") ||
+		strings.Contains(msg, "timeout") ||
+		strings.Contains(msg, "no route to host")
+}
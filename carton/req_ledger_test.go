@@ -0,0 +1,92 @@
+package carton
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestLedgerRejectsRedelivery(c *check.C) {
+	l := NewRequestLedger(time.Hour)
+	r := &Requests{CatId: "ASM001", Category: CONTROL, Action: STOP}
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.Equals, ErrDuplicateRequest)
+	l.Done("RIP001", nil)
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.Equals, ErrDuplicateRequest)
+	e, ok := l.Get("RIP001")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Status, check.Equals, ReqCompleted)
+}
+
+func (s *S) TestLedgerRetriesFailedRequest(c *check.C) {
+	l := NewRequestLedger(time.Hour)
+	r := &Requests{CatId: "ASM001", Category: STATE, Action: CREATE}
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	l.Done("RIP001", errors.New("connection refused"))
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	e, _ := l.Get("RIP001")
+	c.Assert(e.Attempts, check.Equals, 2)
+	c.Assert(e.Status, check.Equals, ReqInFlight)
+}
+
+func (s *S) TestLedgerTimesTheRunOnly(c *check.C) {
+	l := NewRequestLedger(time.Hour)
+	r := &Requests{CatId: "ASM001", Category: CONTROL, Action: START}
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	e, _ := l.Get("RIP001")
+	c.Assert(e.RunningAt.IsZero(), check.Equals, true)
+	l.Run("RIP001")
+	e, _ = l.Get("RIP001")
+	c.Assert(e.RunningAt.Before(e.UpdatedAt), check.Equals, false)
+	l.Done("RIP001", errors.New("connection refused"))
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	e, _ = l.Get("RIP001")
+	c.Assert(e.RunningAt.IsZero(), check.Equals, true)
+}
+
+func (s *S) TestLedgerRejectsDuplicateCreate(c *check.C) {
+	l := NewRequestLedger(time.Hour)
+	r := &Requests{CatId: "ASM001", Category: STATE, Action: CREATE}
+	c.Assert(l.Begin("RIP001", "ASM001", r), check.IsNil)
+	c.Assert(l.Begin("RIP002", "ASM001", r), check.Equals, ErrConflictingRequest)
+	c.Assert(l.Begin("RIP003", "ASM001", &Requests{CatId: "ASM001", Category: STATE, Action: DESTROY}), check.IsNil)
+	l.Done("RIP001", nil)
+	c.Assert(l.Begin("RIP002", "ASM001", r), check.IsNil)
+}
+
+func (s *S) TestLedgerConflictsOnTheAssembly(c *check.C) {
+	l := NewRequestLedger(time.Hour)
+	c.Assert(l.Begin("RIP001", "ASM001", &Requests{CatId: "AMS001", Category: STATE, Action: DESTROY}), check.IsNil)
+	c.Assert(l.Begin("RIP002", "ASM001", &Requests{CatId: "AMS002", Category: STATE, Action: DESTROY}), check.Equals, ErrConflictingRequest)
+	c.Assert(l.Begin("RIP003", "ASM002", &Requests{CatId: "AMS002", Category: STATE, Action: DESTROY}), check.IsNil)
+	l.Done("RIP001", nil)
+	c.Assert(l.Begin("RIP002", "ASM001", &Requests{CatId: "AMS002", Category: STATE, Action: DESTROY}), check.IsNil)
+}
+
+func (s *S) TestLedgerSurvivesARestart(c *check.C) {
+	path := filepath.Join(c.MkDir(), DefaultLedgerJournal)
+	l := NewRequestLedger(time.Hour)
+	c.Assert(l.Open(path), check.IsNil)
+	create := &Requests{CatId: "ASM001", Category: STATE, Action: CREATE}
+	c.Assert(l.Begin("RIP001", "ASM001", create), check.IsNil)
+	l.Done("RIP001", nil)
+	c.Assert(l.Begin("RIP002", "ASM002", &Requests{CatId: "ASM002", Category: STATE, Action: CREATE}), check.IsNil)
+
+	l = NewRequestLedger(time.Hour)
+	c.Assert(l.Open(path), check.IsNil)
+	c.Assert(l.Begin("RIP001", "ASM001", create), check.Equals, ErrDuplicateRequest)
+	e, ok := l.Get("RIP002")
+	c.Assert(ok, check.Equals, true)
+	c.Assert(e.Status, check.Equals, ReqFailed)
+	c.Assert(l.Begin("RIP002", "ASM002", &Requests{CatId: "ASM002", Category: STATE, Action: CREATE}), check.IsNil)
+	e, _ = l.Get("RIP002")
+	c.Assert(e.Attempts, check.Equals, 2)
+}
+
+func (s *S) TestIsTransient(c *check.C) {
+	c.Assert(IsTransient(nil), check.Equals, false)
+	c.Assert(IsTransient(errors.New("dial tcp 10.0.0.1:2633: connection refused")), check.Equals, true)
+	c.Assert(IsTransient(errors.New("template not found")), check.Equals, false)
+}
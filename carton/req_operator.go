@@ -78,6 +78,33 @@ func (p *ReqOperator) Get() (Cartons, error) {
 	}
 }
 
+// AssemblyOf returns the id of the assembly operated by the request, the
+// snapshots and the disks are resolved to the assembly they belong to. An
+// assemblies resolves to its first assembly.
+func AssemblyOf(r *Requests) (string, error) {
+	switch r.Category {
+	case SNAPSHOT:
+		s, err := GetSnap(r.CatId, r.AccountId)
+		if err != nil {
+			return "", err
+		}
+		return s.AssemblyId, nil
+	case DISKS:
+		d, err := GetDisks(r.CatId, r.AccountId)
+		if err != nil {
+			return "", err
+		}
+		return d.AssemblyId, nil
+	}
+	a, err := Get(r.CatId, r.AccountId)
+	if err != nil {
+		return "", err
+	}
+	if len(a.AssemblysId) > 0 {
+		return a.AssemblysId[0], nil
+	}
+	return r.CatId, nil
+}
 
 // MegdProcessor represents a single operation in vertice.
 type MegdProcessor interface {
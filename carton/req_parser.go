@@ -41,9 +41,10 @@ var (
 	START   = "start"
 	RESTART = "restart"
 
-	//the operation actions is just one called upgrade
+	//the operation actions are upgrade and rollback
 	OPERATIONS = "operations"
 	UPGRADE    = "upgrade"
+	ROLLBACK   = "rollback"
 
 	//snapshot actions
 	SNAPSHOT   = "snapshot"
@@ -138,8 +139,12 @@ func (p *ReqParser) parseOperations(action string) (MegdProcessor, error) {
 		return UpgradeProcess{
 			Name: p.name,
 		}, nil
+	case ROLLBACK:
+		return RollbackProcess{
+			Name: p.name,
+		}, nil
 	default:
-		return nil, newParseError([]string{OPERATIONS, action}, []string{UPGRADE})
+		return nil, newParseError([]string{OPERATIONS, action}, []string{UPGRADE, ROLLBACK})
 	}
 }
 
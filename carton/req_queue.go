@@ -0,0 +1,120 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/megamsys/vertice/stats"
+)
+
+// ReqQueue serializes the operations submitted for the same key (AsmId), they
+// run one after the other in arrival order. Operations of different keys run
+// in parallel.
+type ReqQueue struct {
+	mu     sync.Mutex
+	queues map[string]*catQueue
+}
+
+type catQueue struct {
+	pending []func()
+}
+
+// Queue is the request queue shared by the subd daemons.
+var Queue = NewReqQueue()
+
+func init() {
+	stats.Register(Queue)
+}
+
+func NewReqQueue() *ReqQueue {
+	return &ReqQueue{queues: make(map[string]*catQueue)}
+}
+
+// Submit queues fn behind the operations of the key and returns the depth
+// of its queue, including the running operation.
+func (q *ReqQueue) Submit(key string, fn func()) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cq, ok := q.queues[key]
+	if !ok {
+		cq = &catQueue{}
+		q.queues[key] = cq
+	}
+	cq.pending = append(cq.pending, fn)
+	if !ok {
+		go q.drain(key, cq)
+	}
+	return len(cq.pending)
+}
+
+// Depth returns the number of operations queued or running for the key.
+func (q *ReqQueue) Depth(key string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if cq, ok := q.queues[key]; ok {
+		return len(cq.pending)
+	}
+	return 0
+}
+
+// Depths returns the depth of all the non empty queues.
+func (q *ReqQueue) Depths() map[string]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	m := make(map[string]int, len(q.queues))
+	for k, cq := range q.queues {
+		m[k] = len(cq.pending)
+	}
+	return m
+}
+
+// Collect exposes the depth of the queues as a gauge per assembly.
+func (q *ReqQueue) Collect() []*stats.Family {
+	depths := q.Depths()
+	keys := make([]string, 0, len(depths))
+	for k := range depths {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	f := &stats.Family{Name: "vertice_request_queue_depth", Help: "Requests queued or running per assembly.", Type: stats.GAUGE}
+	for _, k := range keys {
+		f.Samples = append(f.Samples, stats.Sample{Labels: stats.Labels([]string{"catid"}, []string{k}), Value: float64(depths[k])})
+	}
+	return []*stats.Family{f}
+}
+
+//runs the operations of a key till its queue is empty.
+func (q *ReqQueue) drain(key string, cq *catQueue) {
+	for {
+		q.mu.Lock()
+		if len(cq.pending) == 0 {
+			delete(q.queues, key)
+			q.mu.Unlock()
+			return
+		}
+		fn := cq.pending[0]
+		q.mu.Unlock()
+
+		fn()
+
+		q.mu.Lock()
+		cq.pending[0] = nil
+		cq.pending = cq.pending[1:]
+		q.mu.Unlock()
+	}
+}
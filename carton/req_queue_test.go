@@ -0,0 +1,63 @@
+package carton
+
+import (
+	"sync"
+	"time"
+
+	"github.com/megamsys/vertice/stats"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestReqQueueRunsInArrivalOrder(c *check.C) {
+	q := NewReqQueue()
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		n := i
+		q.Submit("ASM001", func() {
+			defer wg.Done()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			order = append(order, n)
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+	c.Assert(order, check.DeepEquals, []int{0, 1, 2, 3, 4})
+}
+
+func (s *S) TestReqQueueDepth(c *check.C) {
+	q := NewReqQueue()
+	release := make(chan bool)
+	done := make(chan bool)
+	c.Assert(q.Submit("ASM001", func() { <-release }), check.Equals, 1)
+	c.Assert(q.Submit("ASM001", func() { close(done) }), check.Equals, 2)
+	c.Assert(q.Submit("ASM002", func() {}), check.Equals, 1)
+	c.Assert(q.Depth("ASM001"), check.Equals, 2)
+	close(release)
+	<-done
+	for q.Depth("ASM001") > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	c.Assert(q.Depths()["ASM001"], check.Equals, 0)
+}
+
+func (s *S) TestReqQueueCollect(c *check.C) {
+	q := NewReqQueue()
+	release := make(chan bool)
+	defer close(release)
+	q.Submit("ASM002", func() { <-release })
+	q.Submit("ASM001", func() { <-release })
+	q.Submit("ASM001", func() {})
+	fs := q.Collect()
+	c.Assert(fs, check.HasLen, 1)
+	c.Assert(fs[0].Type, check.Equals, stats.GAUGE)
+	c.Assert(fs[0].Samples, check.HasLen, 2)
+	c.Assert(fs[0].Samples[0].Labels, check.DeepEquals, []stats.Label{{Name: "catid", Value: "ASM001"}})
+	c.Assert(fs[0].Samples[0].Value, check.Equals, 2.0)
+	c.Assert(fs[0].Samples[1].Value, check.Equals, 1.0)
+}
@@ -0,0 +1,59 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/megamsys/libgo/api"
+)
+
+const SSHKEYS = "/sshkeys/"
+
+var ErrNoSshKey = errors.New("no ssh key found for the box")
+
+type ApiSshKeys struct {
+	JsonClaz string   `json:"json_claz"`
+	Results  []SshKey `json:"results"`
+}
+
+// SshKey is the key pair named by the sshkey input of an assembly.
+type SshKey struct {
+	Id         string `json:"id" cql:"id"`
+	Name       string `json:"name" cql:"name"`
+	OrgId      string `json:"org_id" cql:"org_id"`
+	PrivateKey string `json:"privatekey" cql:"privatekey"`
+	PublicKey  string `json:"publickey" cql:"publickey"`
+	CreatedAt  string `json:"created_at" cql:"created_at"`
+}
+
+// GetSshKey fetches the key pair named name of the owner of args.
+func GetSshKey(name string, args api.ApiArgs) (*SshKey, error) {
+	cl := api.NewClient(args, SSHKEYS+name)
+	response, err := cl.Get()
+	if err != nil {
+		return nil, err
+	}
+	ac := &ApiSshKeys{}
+	if err = json.Unmarshal(response, ac); err != nil {
+		return nil, err
+	}
+	if len(ac.Results) == 0 {
+		return nil, ErrNoSshKey
+	}
+	return &ac.Results[0], nil
+}
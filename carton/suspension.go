@@ -0,0 +1,529 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package carton
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/libgo/events"
+	"github.com/megamsys/libgo/events/alerts"
+	constants "github.com/megamsys/libgo/utils"
+)
+
+const (
+	// DefaultSuspendGrace is the time an account with a negative balance is
+	// warned before its boxes are stopped.
+	DefaultSuspendGrace = 24 * time.Hour
+
+	// DefaultSuspensionsJournal is the journal of the suspensions in the
+	// vertice dir.
+	DefaultSuspensionsJournal = "suspensions.journal"
+)
+
+var (
+	// ErrAccountSuspended fails the starts and the deploys of the boxes of an
+	// account suspended for its balance.
+	ErrAccountSuspended = errors.New("account suspended, its balance is negative")
+
+	// the assembly of a box stopped or started can't be found anymore.
+	errAssemblyGone = errors.New("assembly is gone")
+)
+
+// Suspender stops the running boxes of the accounts whose balance went
+// negative after billing, once they were warned for the grace period, and
+// starts them again when the balance is topped up.
+//
+// Once opened, the state of an account is journaled on every change, so that
+// the warnings, the suspensions and the boxes stopped outlive a restart. The
+// journal is compacted to the last state of every account when replayed.
+type Suspender struct {
+	Grace time.Duration
+
+	mu       sync.Mutex
+	accounts map[string]*suspension
+	journal  *os.File
+
+	credit  func(email string) (float64, error)
+	account func(email string) (*Account, error)
+	update  func(a *Account) error
+	stop    func(aies, ay, email string) (bool, error)
+	start   func(aies, ay, email string) error
+	warn    func(email string, credit float64) error
+	now     func() time.Time
+}
+
+type suspension struct {
+	assemblies map[string]string // assembly id to its assemblies id.
+	stopped    map[string]string
+	warnedAt   time.Time
+	suspended  bool
+	at         string // when the account was marked suspended.
+}
+
+// suspensionRecord is a line of the journal, the state of an account.
+type suspensionRecord struct {
+	AccountId  string            `json:"account_id"`
+	Assemblies map[string]string `json:"assemblies"`
+	Stopped    map[string]string `json:"stopped"`
+	WarnedAt   time.Time         `json:"warned_at"`
+	Suspended  bool              `json:"suspended"`
+	At         string            `json:"at"`
+}
+
+func (sp *suspension) record(email string) *suspensionRecord {
+	return &suspensionRecord{
+		AccountId:  email,
+		Assemblies: sp.assemblies,
+		Stopped:    sp.stopped,
+		WarnedAt:   sp.warnedAt,
+		Suspended:  sp.suspended,
+		At:         sp.at,
+	}
+}
+
+func newSuspension() *suspension {
+	return &suspension{assemblies: make(map[string]string), stopped: make(map[string]string)}
+}
+
+// Suspensions enforces the balances of the accounts billed by metricsd.
+var Suspensions = NewSuspender(DefaultSuspendGrace)
+
+func NewSuspender(grace time.Duration) *Suspender {
+	return &Suspender{
+		Grace:    grace,
+		accounts: make(map[string]*suspension),
+		credit:   Credit,
+		account:  NewAccounts,
+		update:   (*Account).Update,
+		stop:     stopCarton,
+		start:    startCarton,
+		warn:     warnInsufficientFund,
+		now:      time.Now,
+	}
+}
+
+// Open replays the journal at path and journals the changes to come in it.
+// The accounts already journaled are kept when opened again.
+func (s *Suspender) Open(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := s.replay(path); err != nil {
+		return err
+	}
+	if err := s.compact(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	s.journal = f
+	return nil
+}
+
+func (s *Suspender) replay(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		r := &suspensionRecord{}
+		if err := json.Unmarshal(sc.Bytes(), r); err != nil {
+			log.Warnf("  suspensions journal %s skips a line : %s", path, err)
+			continue
+		}
+		sp := newSuspension()
+		for ay, aies := range r.Assemblies {
+			sp.assemblies[ay] = aies
+		}
+		for ay, aies := range r.Stopped {
+			sp.stopped[ay] = aies
+		}
+		sp.warnedAt, sp.suspended, sp.at = r.WarnedAt, r.Suspended, r.At
+		s.accounts[r.AccountId] = sp
+	}
+	return sc.Err()
+}
+
+// compact rewrites the journal at path with the state of every account.
+func (s *Suspender) compact(path string) error {
+	emails := make([]string, 0, len(s.accounts))
+	for email := range s.accounts {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	tmp := path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, email := range emails {
+		if err = enc.Encode(s.accounts[email].record(email)); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// save journals the state of the account, s.mu is held. The state is kept in
+// memory when it can't be journaled.
+func (s *Suspender) save(email string, sp *suspension) {
+	if s.journal == nil {
+		return
+	}
+	b, err := json.Marshal(sp.record(email))
+	if err == nil {
+		_, err = s.journal.Write(append(b, '\n'))
+	}
+	if err != nil {
+		log.Errorf("  suspensions journal of %s : %s", email, err)
+	}
+}
+
+// Watch adds a billed box to the ones of the account.
+func (s *Suspender) Watch(email, aies, ay string) {
+	if email == "" || ay == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, ok := s.accounts[email]
+	if !ok {
+		sp = newSuspension()
+		s.accounts[email] = sp
+	}
+	if known, ok := sp.assemblies[ay]; !ok || known != aies {
+		sp.assemblies[ay] = aies
+		s.save(email, sp)
+	}
+}
+
+// Forget drops a destroyed box from the ones of the account.
+func (s *Suspender) Forget(email, ay string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, ok := s.accounts[email]
+	if !ok {
+		return
+	}
+	_, watched := sp.assemblies[ay]
+	_, stopped := sp.stopped[ay]
+	if !watched && !stopped {
+		return
+	}
+	delete(sp.assemblies, ay)
+	delete(sp.stopped, ay)
+	s.save(email, sp)
+}
+
+// Suspended returns whether the boxes of the account were stopped.
+func (s *Suspender) Suspended(email string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sp, ok := s.accounts[email]
+	return ok && sp.suspended
+}
+
+// Allow fails with ErrAccountSuspended when the account is suspended, its
+// boxes can't be started or deployed till it is resumed.
+func (s *Suspender) Allow(email string) error {
+	if s.Suspended(email) {
+		return ErrAccountSuspended
+	}
+	return nil
+}
+
+// Enforce warns, suspends or resumes every watched account per its balance.
+func (s *Suspender) Enforce() error {
+	s.mu.Lock()
+	emails := make([]string, 0, len(s.accounts))
+	for email := range s.accounts {
+		emails = append(emails, email)
+	}
+	s.mu.Unlock()
+	sort.Strings(emails)
+
+	var failed []string
+	for _, email := range emails {
+		if err := s.enforce(email); err != nil {
+			log.Errorf("  suspension of %s failed : %s", email, err)
+			failed = append(failed, email)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("suspension failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (s *Suspender) enforce(email string) error {
+	credit, err := s.credit(email)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	sp := s.accounts[email]
+	warnedAt, suspended := sp.warnedAt, sp.suspended
+	s.mu.Unlock()
+
+	switch {
+	case credit < 0 && warnedAt.IsZero():
+		log.Warnf("  balance of %s is %.4f, suspending its boxes in %s", email, credit, s.Grace)
+		if err = s.warn(email, credit); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		sp.warnedAt = s.now()
+		s.save(email, sp)
+		s.mu.Unlock()
+	case credit < 0 && s.now().Sub(warnedAt) >= s.Grace:
+		return s.suspend(email, sp, suspended)
+	case credit > 0 && suspended:
+		return s.resume(email, sp)
+	case credit >= 0 && !suspended && !warnedAt.IsZero():
+		s.mu.Lock()
+		sp.warnedAt = time.Time{}
+		s.save(email, sp)
+		s.mu.Unlock()
+	}
+	return nil
+}
+
+// stops the boxes of the account not stopped yet, and marks it suspended.
+func (s *Suspender) suspend(email string, sp *suspension, suspended bool) error {
+	s.mu.Lock()
+	pending := make(map[string]string)
+	for ay, aies := range sp.assemblies {
+		if _, ok := sp.stopped[ay]; !ok {
+			pending[ay] = aies
+		}
+	}
+	s.mu.Unlock()
+
+	var err error
+	for ay, aies := range pending {
+		running, serr := s.stop(aies, ay, email)
+		if serr != nil && serr != errAssemblyGone {
+			log.Errorf("  suspension of %s, stopping %s failed : %s", email, ay, serr)
+			err = serr
+			continue
+		}
+		s.mu.Lock()
+		if running {
+			sp.stopped[ay] = aies
+		} else {
+			// gone or not running, the box is left as the user had it.
+			delete(sp.assemblies, ay)
+		}
+		s.save(email, sp)
+		s.mu.Unlock()
+	}
+
+	if !suspended {
+		if aerr := s.markSuspended(email, sp, true); aerr != nil {
+			return aerr
+		}
+		log.Warnf("  suspended %s, %d boxes stopped", email, len(sp.stopped))
+		s.mu.Lock()
+		sp.suspended = true
+		s.save(email, sp)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// starts the boxes stopped on the suspension, unless the account is
+// suspended till later. The account stays suspended till all the boxes still
+// there are started.
+func (s *Suspender) resume(email string, sp *suspension) error {
+	a, err := s.account(email)
+	if err != nil {
+		return err
+	}
+	if till, terr := time.Parse(time.RFC3339, a.Suspend.SuspendedTill); terr == nil && till.After(s.now()) {
+		log.Debugf("  %s is suspended till %s, not resuming", email, a.Suspend.SuspendedTill)
+		return nil
+	}
+
+	s.mu.Lock()
+	stopped := make(map[string]string, len(sp.stopped))
+	for ay, aies := range sp.stopped {
+		stopped[ay] = aies
+	}
+	s.mu.Unlock()
+
+	for ay, aies := range stopped {
+		serr := s.start(aies, ay, email)
+		if serr != nil && serr != errAssemblyGone {
+			log.Errorf("  resuming %s, starting %s failed : %s", email, ay, serr)
+			err = serr
+			continue
+		}
+		s.mu.Lock()
+		delete(sp.stopped, ay)
+		if serr == errAssemblyGone {
+			delete(sp.assemblies, ay)
+		}
+		s.save(email, sp)
+		s.mu.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = s.markSuspended(email, sp, false); err != nil {
+		return err
+	}
+	log.Infof("  resumed %s, %d boxes started", email, len(stopped))
+	s.mu.Lock()
+	sp.suspended = false
+	sp.warnedAt = time.Time{}
+	s.save(email, sp)
+	s.mu.Unlock()
+	return nil
+}
+
+// records the suspension in the account, a suspension made by an admin is
+// left as it is on resume.
+func (s *Suspender) markSuspended(email string, sp *suspension, suspended bool) error {
+	a, err := s.account(email)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		at := s.now().Format(time.RFC3339)
+		a.Suspend = Suspend{Suspended: "true", SuspendedAt: at}
+		if err = s.update(a); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		sp.at = at
+		s.save(email, sp)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Lock()
+	at := sp.at
+	s.mu.Unlock()
+	if a.Suspend.SuspendedAt != at {
+		return nil
+	}
+	a.Suspend = Suspend{Suspended: "false"}
+	return s.update(a)
+}
+
+func warnInsufficientFund(email string, credit float64) error {
+	mi := make(map[string]string)
+	mi[constants.EMAIL] = email
+	mi["credit"] = strconv.FormatFloat(credit, 'f', 4, 64)
+	newEvent := events.NewMulti(
+		[]*events.Event{
+			&events.Event{
+				AccountsId:  email,
+				EventAction: alerts.INSUFFICIENT_FUND,
+				EventType:   constants.EventUser,
+				EventData:   alerts.EventData{M: mi},
+				Timestamp:   time.Now().Local(),
+			},
+		})
+	return newEvent.Write()
+}
+
+// stops the boxes of the assembly behind the requests queued on it, returning
+// whether any was running.
+func stopCarton(aies, ay, email string) (bool, error) {
+	running := false
+	err := onQueue(ay, func() error {
+		c, err := watchedCarton(aies, ay, email)
+		if err != nil {
+			return err
+		}
+		for i := range *c.Boxes {
+			if (&LifecycleOpts{B: &(*c.Boxes)[i]}).canCycleStop() {
+				running = true
+			}
+		}
+		if !running {
+			return nil
+		}
+		return c.Stop()
+	})
+	return running, err
+}
+
+// starts the boxes of the assembly behind the requests queued on it.
+func startCarton(aies, ay, email string) error {
+	return onQueue(ay, func() error {
+		c, err := watchedCarton(aies, ay, email)
+		if err != nil {
+			return err
+		}
+		return c.Start()
+	})
+}
+
+// onQueue runs op behind the requests queued on the assembly and waits for it.
+func onQueue(ay string, op func() error) error {
+	done := make(chan error, 1)
+	Queue.Submit(ay, func() { done <- op() })
+	return <-done
+}
+
+// the carton of a watched assembly, errAssemblyGone when it can't be found.
+func watchedCarton(aies, ay, email string) (*Carton, error) {
+	c, err := mkCarton(aies, ay, email)
+	if err != nil && !IsTransient(err) {
+		log.Warnf("  assembly %s of %s is gone : %s", ay, email, err)
+		return nil, errAssemblyGone
+	}
+	return c, err
+}
@@ -0,0 +1,191 @@
+package carton
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+type fakeBilling struct {
+	credit  float64
+	running map[string]bool
+	account Account
+	warned  int
+	now     time.Time
+}
+
+func newFakeSuspender(f *fakeBilling) *Suspender {
+	s := NewSuspender(time.Hour)
+	s.credit = func(string) (float64, error) { return f.credit, nil }
+	s.account = func(string) (*Account, error) { a := f.account; return &a, nil }
+	s.update = func(a *Account) error { f.account = *a; return nil }
+	s.stop = func(aies, ay, email string) (bool, error) {
+		running := f.running[ay]
+		f.running[ay] = false
+		return running, nil
+	}
+	s.start = func(aies, ay, email string) error { f.running[ay] = true; return nil }
+	s.warn = func(string, float64) error { f.warned++; return nil }
+	s.now = func() time.Time { return f.now }
+	return s
+}
+
+func (s *S) TestSuspenderWarnsThenSuspends(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true, "ASM002": false}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM002")
+
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.warned, check.Equals, 1)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, false)
+
+	f.now = f.now.Add(30 * time.Minute)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.warned, check.Equals, 1)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+
+	f.now = f.now.Add(time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.running["ASM001"], check.Equals, false)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, true)
+	c.Assert(f.account.Suspend.Suspended, check.Equals, "true")
+}
+
+func (s *S) TestSuspenderResumesOnTopUp(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true, "ASM002": false}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM002")
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, true)
+
+	f.credit = 10
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, false)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+	// the box stopped by the user stays stopped.
+	c.Assert(f.running["ASM002"], check.Equals, false)
+	c.Assert(f.account.Suspend.Suspended, check.Equals, "false")
+}
+
+func (s *S) TestSuspenderHonoursSuspendedTill(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+
+	f.account.Suspend.SuspendedTill = f.now.Add(time.Hour).Format(time.RFC3339)
+	f.credit = 10
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, true)
+	c.Assert(f.running["ASM001"], check.Equals, false)
+
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, false)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+}
+
+func (s *S) TestSuspenderClearsTheWarning(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.credit = 5
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.credit = -1
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.warned, check.Equals, 2)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+}
+
+func (s *S) TestSuspenderResumesAfterARestart(c *check.C) {
+	path := filepath.Join(c.MkDir(), DefaultSuspensionsJournal)
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	c.Assert(sp.Open(path), check.IsNil)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.running["ASM001"], check.Equals, false)
+
+	sp = newFakeSuspender(f)
+	c.Assert(sp.Open(path), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, true)
+	f.credit = 10
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, false)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+	c.Assert(f.account.Suspend.Suspended, check.Equals, "false")
+	c.Assert(f.warned, check.Equals, 1)
+}
+
+func (s *S) TestSuspenderResumesPastAFailedStart(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true, "ASM002": true, "ASM003": true}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	for _, ay := range []string{"ASM001", "ASM002", "ASM003"} {
+		sp.Watch("vino.v@megam.io", "AMS001", ay)
+	}
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+
+	failing := true
+	sp.start = func(aies, ay, email string) error {
+		switch {
+		case ay == "ASM002":
+			return errAssemblyGone
+		case ay == "ASM003" && failing:
+			return errors.New("one is down")
+		}
+		f.running[ay] = true
+		return nil
+	}
+	f.credit = 10
+	c.Assert(sp.Enforce(), check.NotNil)
+	c.Assert(f.running["ASM001"], check.Equals, true)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, true)
+	c.Assert(sp.accounts["vino.v@megam.io"].assemblies, check.DeepEquals, map[string]string{"ASM001": "AMS001", "ASM003": "AMS001"})
+
+	failing = false
+	c.Assert(sp.Enforce(), check.IsNil)
+	c.Assert(f.running["ASM003"], check.Equals, true)
+	c.Assert(sp.Suspended("vino.v@megam.io"), check.Equals, false)
+}
+
+func (s *S) TestSuspenderForgetsADestroyedBox(c *check.C) {
+	f := &fakeBilling{credit: -1, running: map[string]bool{"ASM001": true}, now: time.Now()}
+	sp := newFakeSuspender(f)
+	sp.Watch("vino.v@megam.io", "AMS001", "ASM001")
+	c.Assert(sp.Enforce(), check.IsNil)
+	f.now = f.now.Add(2 * time.Hour)
+	c.Assert(sp.Enforce(), check.IsNil)
+	sp.Forget("vino.v@megam.io", "ASM001")
+	c.Assert(sp.accounts["vino.v@megam.io"].assemblies, check.HasLen, 0)
+	c.Assert(sp.accounts["vino.v@megam.io"].stopped, check.HasLen, 0)
+}
+
+func (s *S) TestStartProcessFailsOnASuspendedAccount(c *check.C) {
+	sp := newSuspension()
+	sp.suspended = true
+	Suspensions.mu.Lock()
+	Suspensions.accounts["vino.v@megam.io"] = sp
+	Suspensions.mu.Unlock()
+	defer func() {
+		Suspensions.mu.Lock()
+		delete(Suspensions.accounts, "vino.v@megam.io")
+		Suspensions.mu.Unlock()
+	}()
+	err := StartProcess{Name: "ASM001"}.Process(Cartons{&Carton{Id: "ASM001", AccountId: "vino.v@megam.io"}})
+	c.Assert(err, check.Equals, ErrAccountSuspended)
+}
@@ -43,6 +43,7 @@ func cmdRegistry(name string) *cmd.Manager {
 		}
 	})
 	m.Register(&run.Start{})
+	m.Register(&run.Replay{})
 	return m
 }
 
@@ -45,3 +45,10 @@ func (s *S) TestStartIsRegistered(c *check.C) {
 	c.Assert(ok, check.Equals, true)
 	c.Assert(create, check.FitsTypeOf, &run.Start{})
 }
+
+func (s *S) TestReplayIsRegistered(c *check.C) {
+	manager := cmdRegistry("vertice")
+	replay, ok := manager.Commands["replay"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(replay, check.FitsTypeOf, &run.Replay{})
+}
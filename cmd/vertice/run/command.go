@@ -133,6 +133,9 @@ func (c *Start) ParseConfig(path string) (*Config, error) {
   if err := toml.Unmarshal(buf, &config); err != nil {
     panic(err)
   }
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 
 	log.Debug(config)
 	return config, nil
@@ -2,6 +2,7 @@ package run
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/storage"
@@ -73,5 +74,8 @@ func (c *Config) Validate() error {
 	if c.Meta.Dir == "" {
 		return errors.New("Meta.Dir must be specified")
 	}
+	if err := c.Deployd.Validate(); err != nil {
+		return fmt.Errorf("deployd: %s", err)
+	}
 	return nil
 }
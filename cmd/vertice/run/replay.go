@@ -0,0 +1,71 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package run
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/carton"
+	"launchpad.net/gnuflag"
+)
+
+const defaultReplayIdle = 10 * time.Second
+
+// Replay republishes the dead lettered requests to the topics they failed on.
+type Replay struct {
+	fs   *gnuflag.FlagSet
+	file configFile
+	idle time.Duration
+}
+
+func (g *Replay) Info() *cmd.Info {
+	desc := `replays the failed requests from the dead letter queue.
+
+`
+	return &cmd.Info{
+		Name:    "replay",
+		Usage:   `replay [--config] [--idle]`,
+		Desc:    desc,
+		MinArgs: 0,
+	}
+}
+
+func (c *Replay) Run(context *cmd.Context) error {
+	config, err := (&Start{}).ParseConfig(c.file.String())
+	if err != nil {
+		return fmt.Errorf("Failed to parse config: %s", err)
+	}
+	config.Meta.MkGlobal()
+
+	n, err := carton.ReplayDeadLetters(c.idle)
+	if err != nil {
+		return fmt.Errorf("replay: %s", err)
+	}
+	fmt.Fprintf(context.Stdout, "replayed %d request(s)\n", n)
+	return nil
+}
+
+func (c *Replay) Flags() *gnuflag.FlagSet {
+	if c.fs == nil {
+		c.fs = gnuflag.NewFlagSet("vertice", gnuflag.ExitOnError)
+		c.fs.Var(&c.file, "config", "Path to configuration file (default to /vertice/vertice.conf)")
+		c.fs.Var(&c.file, "c", "Path to configuration file (default to /vertice/vertice.conf)")
+		c.fs.DurationVar(&c.idle, "idle", defaultReplayIdle, "Stop once no dead letter arrived for this long")
+	}
+	return c.fs
+}
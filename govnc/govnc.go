@@ -1,26 +1,110 @@
 package govnc
 
 import (
-	"fmt"
-	//"net"
-	//"time"
-	//"log"
-	vnc "github.com/kward/go-vnc"
-	//"golang.org/x/net/context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
 )
 
-type VncListener struct {
-	B <-chan vnc.ServerMessage
-}
+const (
+	// DialTimeout is the time allowed to reach the VNC server of a box.
+	DialTimeout = 10 * time.Second
+
+	// IdleTimeout closes a proxied session without any frame in both directions.
+	IdleTimeout = 15 * time.Minute
+)
+
+var ErrNoVncHost = errors.New("vnc host or port isn't available for the box")
 
 type VncHost struct {
-  IpAddress string
-  Port string
-  Password string
+	IpAddress string
+	Port      string
+	Password  string
+}
+
+func (vh *VncHost) Addr() (string, error) {
+	if vh.IpAddress == "" || vh.Port == "" {
+		return "", ErrNoVncHost
+	}
+	return net.JoinHostPort(vh.IpAddress, vh.Port), nil
+}
+
+// Connect dials the RFB server of the vnc host.
+func Connect(vh *VncHost) (net.Conn, error) {
+	addr, err := vh.Addr()
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("  vnc connect %s", addr)
+	return net.DialTimeout("tcp", addr, DialTimeout)
 }
 
-func Connect(vh *VncHost) {
+// Proxy relays the RFB frames between the client (a websocket) and the VNC
+// server of the host in both directions. It returns when either side closes
+// or when no frame was relayed for idle.
+func Proxy(client net.Conn, vh *VncHost, idle time.Duration) error {
+	server, err := Connect(vh)
+	if err != nil {
+		return err
+	}
+	if idle <= 0 {
+		idle = IdleTimeout
+	}
 
-  fmt.Println(vh)
+	var (
+		lastSeen int64
+		once     sync.Once
+	)
+	done := make(chan error, 2)
+	closeAll := func() {
+		once.Do(func() {
+			client.Close()
+			server.Close()
+		})
+	}
+	defer closeAll()
+
+	touch := func() { atomic.StoreInt64(&lastSeen, time.Now().UnixNano()) }
+	touch()
+	go func() { done <- relay(server, client, touch) }()
+	go func() { done <- relay(client, server, touch) }()
+
+	ticker := time.NewTicker(idle / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		case <-ticker.C:
+			if time.Since(time.Unix(0, atomic.LoadInt64(&lastSeen))) > idle {
+				log.Debugf("  vnc session idle for %s, closing", idle)
+				return nil
+			}
+		}
+	}
+}
 
+//copies src into dst, noting down the time of every frame.
+func relay(dst io.Writer, src io.Reader, touch func()) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			touch()
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
 }
@@ -6,10 +6,13 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/toml"
 )
 
 const (
@@ -26,7 +29,6 @@ const (
 	// DefaultScylla is the default scylla if one is not provided.
 	DefaultScylla = "localhost"
 
-
 	// DefaultScyllaKeyspace is the default Scyllakeyspace if one is not provided.
 	DefaultScyllaKeyspace = "vertice"
 
@@ -36,18 +38,79 @@ const (
 	//default user
 	DefaultUser = "megam"
 
+	// DefaultMaxAttempts is the number of times a failing request is tried.
+	DefaultMaxAttempts = 5
+
+	// DefaultBackoff is the delay before the first retry of a failed request.
+	DefaultBackoff = 10 * time.Second
+
+	// DefaultMaxBackoff caps the exponential delay between two retries.
+	DefaultMaxBackoff = 10 * time.Minute
+
 	MEGAM_HOME = "MEGAM_HOME"
 )
 
 // Config represents the meta configuration.
 type Config struct {
-	Home           string   `toml:"home"`
-	Dir            string   `toml:"dir"`
-	NSQd           []string `toml:"nsqd"`
-	Api            string   `toml:"api"`
-	MasterKey      string   `toml:"master_key"`
-	MasterUser     string   `toml:"master_user"`
-	User           string   `toml:"user"`
+	Home            string        `toml:"home"`
+	Dir             string        `toml:"dir"`
+	NSQd            []string      `toml:"nsqd"`
+	Api             string        `toml:"api"`
+	MasterKey       string        `toml:"master_key"`
+	MasterUser      string        `toml:"master_user"`
+	User            string        `toml:"user"`
+	BoxWorkers      int           `toml:"box_workers"`
+	ContinueOnError bool          `toml:"continue_on_error"`
+	Retries         []RetryPolicy `toml:"retry"`
+}
+
+// RetryPolicy says how a request failing with a transient error is retried.
+// An empty action applies to all the actions of the category, an empty
+// category applies to all the requests.
+type RetryPolicy struct {
+	Category    string        `toml:"category"`
+	Action      string        `toml:"action"`
+	MaxAttempts int           `toml:"max_attempts"`
+	Backoff     toml.Duration `toml:"backoff"`
+	MaxBackoff  toml.Duration `toml:"max_backoff"`
+}
+
+// DefaultRetryPolicy is used when no policy matches a request.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: DefaultMaxAttempts,
+	Backoff:     toml.Duration(DefaultBackoff),
+	MaxBackoff:  toml.Duration(DefaultMaxBackoff),
+}
+
+// Delay returns the exponential backoff before the retry of the nth attempt.
+// The backoffs not set default to DefaultBackoff and DefaultMaxBackoff.
+func (r RetryPolicy) Delay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	r = r.WithDefaults()
+	d, max := time.Duration(r.Backoff), time.Duration(r.MaxBackoff)
+	for i := 1; i < attempts && i < 32; i++ {
+		d *= 2
+		if d >= max {
+			return max
+		}
+	}
+	return d
+}
+
+// WithDefaults returns the policy with its unset fields defaulted.
+func (r RetryPolicy) WithDefaults() RetryPolicy {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = DefaultMaxAttempts
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = toml.Duration(DefaultBackoff)
+	}
+	if r.MaxBackoff <= 0 {
+		r.MaxBackoff = toml.Duration(DefaultMaxBackoff)
+	}
+	return r
 }
 
 var MC *Config
@@ -64,6 +127,7 @@ func (c Config) String() string {
 	b.Write([]byte("Master User        " + "\t" + c.MasterUser + "\n"))
 	b.Write([]byte("Master Key       " + "\t" + c.MasterUser + "\n"))
 	b.Write([]byte("NSQd      " + "\t" + strings.Join(c.NSQd, ",") + "\n"))
+	b.Write([]byte("Box Workers      " + "\t" + strconv.Itoa(c.BoxWorkers) + "\n"))
 	b.Write([]byte("---\n"))
 	fmt.Fprintln(w)
 	w.Flush()
@@ -85,12 +149,12 @@ func NewConfig() *Config {
 
 	// Config represents the configuration format for the vertice.
 	return &Config{
-		Home:           homeDir,
-		Dir:            defaultDir,
-		User:           DefaultUser,
-		Api:            DefaultApi,
-		MasterKey:      DefaultMasterKey,
-		NSQd:           []string{DefaultNSQd},
+		Home:      homeDir,
+		Dir:       defaultDir,
+		User:      DefaultUser,
+		Api:       DefaultApi,
+		MasterKey: DefaultMasterKey,
+		NSQd:      []string{DefaultNSQd},
 	}
 }
 
@@ -1,6 +1,8 @@
 package meta
 
 import (
+	"time"
+
 	"github.com/BurntSushi/toml"
 	"gopkg.in/check.v1"
 )
@@ -13,12 +15,39 @@ func (s *S) TestMetaConfig_Parse(c *check.C) {
 dir = "/var/lib/megam/vertice/meta"
 api = "https://api.megam.io"
 nsqd = ["localhost:4150"]
+box_workers = 10
+continue_on_error = true
 scylla = ["103.56.92.24"]
 scylla_keyspace = "vertice"
+
+[[retry]]
+category = "state"
+action = "create"
+max_attempts = 8
+backoff = "30s"
 `, &cm); err != nil {
 		c.Fatal(err)
 	}
 	c.Assert(cm.Dir, check.Equals, "/var/lib/megam/vertice/meta")
 	c.Assert(cm.Api, check.Equals, "https://api.megam.io")
 	c.Assert(cm.NSQd, check.DeepEquals, []string{"localhost:4150"})
+	c.Assert(cm.BoxWorkers, check.Equals, 10)
+	c.Assert(cm.ContinueOnError, check.Equals, true)
+	c.Assert(cm.Retries, check.HasLen, 1)
+	c.Assert(cm.Retries[0].MaxAttempts, check.Equals, 8)
+	c.Assert(time.Duration(cm.Retries[0].Backoff), check.Equals, 30*time.Second)
+}
+
+func (s *S) TestRetryPolicyDelay(c *check.C) {
+	r := DefaultRetryPolicy
+	c.Assert(r.Delay(1), check.Equals, DefaultBackoff)
+	c.Assert(r.Delay(3), check.Equals, 4*DefaultBackoff)
+	c.Assert(r.Delay(20), check.Equals, DefaultMaxBackoff)
+}
+
+func (s *S) TestRetryPolicyDelayDefaults(c *check.C) {
+	r := RetryPolicy{MaxAttempts: 3, Backoff: DefaultRetryPolicy.Backoff / 10}
+	c.Assert(r.Delay(1), check.Equals, DefaultBackoff/10)
+	c.Assert(r.Delay(40), check.Equals, DefaultMaxBackoff)
+	c.Assert(RetryPolicy{}.Delay(1), check.Equals, DefaultBackoff)
 }
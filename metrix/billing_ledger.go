@@ -0,0 +1,331 @@
+package metrix
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultMaxBackfill is the longest gap billed after a downtime.
+	DefaultMaxBackfill = 24 * time.Hour
+
+	// GapTolerance is the slack between two audit periods not reported as a gap.
+	GapTolerance = time.Minute
+
+	windowPending = "pending"
+	windowBilled  = "billed"
+	windowAborted = "aborted"
+)
+
+var (
+	ErrBadAuditPeriod   = errors.New("audit period of the sensor isn't valid")
+	ErrBillingInFlight  = errors.New("audit period of the sensor is being billed")
+	ErrWindowNotClaimed = errors.New("billing window wasn't claimed")
+)
+
+// BillingWindow is a period of a sensor deducted at once. Backfill windows
+// cover a gap between two audit periods.
+type BillingWindow struct {
+	Begin    time.Time
+	End      time.Time
+	Backfill bool
+}
+
+func (w BillingWindow) Duration() time.Duration {
+	return w.End.Sub(w.Begin)
+}
+
+// LedgerRecord is a line of the journal of the billing ledger.
+type LedgerRecord struct {
+	AccountId    string    `json:"account_id"`
+	AssemblyId   string    `json:"assembly_id"`
+	AssemblyName string    `json:"assembly_name"`
+	SensorType   string    `json:"sensor_type"`
+	Begin        time.Time `json:"begin"`
+	End          time.Time `json:"end"`
+	Cost         float64   `json:"cost"`
+	Backfill     bool      `json:"backfill"`
+	Status       string    `json:"status"`
+	At           time.Time `json:"at"`
+}
+
+func (r *LedgerRecord) key() string {
+	return ledgerKey(r.AccountId, r.AssemblyId, r.AssemblyName, r.SensorType)
+}
+
+// the snapshots of an assembly share its id, they are told by their name.
+func ledgerKey(account, assembly, name, sensor string) string {
+	return account + "/" + assembly + "/" + name + "/" + sensor
+}
+
+func sensorKey(s *Sensor) string {
+	return ledgerKey(s.AccountId, s.AssemblyId, s.AssemblyName, s.SensorType)
+}
+
+// BillingLedger remembers up to when the sensor of every assembly was billed,
+// so that an audit period is deducted once even when the collectors overlap
+// or metricsd restarts. The gaps between two periods are backfilled up to
+// MaxBackfill.
+//
+// Every window is journaled as pending before it is deducted and as billed
+// (or aborted) after, a pending window found when the journal is replayed is
+// taken as billed and reported, as the deduction may have been written. Once
+// replayed, the journal is compacted to the last billed window of each sensor.
+type BillingLedger struct {
+	MaxBackfill time.Duration
+
+	mu       sync.Mutex
+	until    map[string]time.Time
+	inflight map[string]bool
+	journal  *os.File
+}
+
+// Billed is the billing ledger of the collectors, set by metricsd.
+var Billed = NewBillingLedger(DefaultMaxBackfill)
+
+// NewBillingLedger returns a ledger kept in memory.
+func NewBillingLedger(maxBackfill time.Duration) *BillingLedger {
+	return &BillingLedger{
+		MaxBackfill: maxBackfill,
+		until:       make(map[string]time.Time),
+		inflight:    make(map[string]bool),
+	}
+}
+
+// OpenBillingLedger returns the ledger journaled in the file at path,
+// replaying the windows already billed.
+func OpenBillingLedger(path string, maxBackfill time.Duration) (*BillingLedger, error) {
+	l := NewBillingLedger(maxBackfill)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	last, err := l.replay(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = compact(path, last); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l.journal = f
+	return l, nil
+}
+
+// replay replays the journal at path and returns the last window billed of
+// each sensor.
+func (l *BillingLedger) replay(path string) (map[string]*LedgerRecord, error) {
+	last := make(map[string]*LedgerRecord)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return last, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pending := make(map[string]*LedgerRecord)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		r := &LedgerRecord{}
+		if err := json.Unmarshal(sc.Bytes(), r); err != nil {
+			log.Warnf("  billing ledger, skipping unparsable record %s", sc.Text())
+			continue
+		}
+		switch r.Status {
+		case windowPending:
+			pending[r.key()] = r
+		case windowBilled:
+			delete(pending, r.key())
+			l.keep(last, r)
+		case windowAborted:
+			delete(pending, r.key())
+		}
+	}
+	for k, r := range pending {
+		log.Warnf("  billing ledger, unconfirmed deduction of %s for %s - %s, verify the billed history", k, r.Begin.Format(time.RFC3339), r.End.Format(time.RFC3339))
+		r.Status = windowBilled
+		l.keep(last, r)
+	}
+	return last, sc.Err()
+}
+
+func (l *BillingLedger) keep(last map[string]*LedgerRecord, r *LedgerRecord) {
+	if p, ok := last[r.key()]; !ok || r.End.After(p.End) {
+		last[r.key()] = r
+	}
+	l.advance(r.key(), r.End)
+}
+
+// compact rewrites the journal at path with the records of last, the file is
+// replaced once the records are synced.
+func compact(path string, last map[string]*LedgerRecord) error {
+	keys := make([]string, 0, len(last))
+	for k := range last {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	tmp := path + ".compact"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, k := range keys {
+		b, err := json.Marshal(last[k])
+		if err != nil {
+			f.Close()
+			return err
+		}
+		if _, err = w.Write(append(b, '\n')); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (l *BillingLedger) advance(key string, end time.Time) {
+	if end.After(l.until[key]) {
+		l.until[key] = end
+	}
+}
+
+// Close closes the journal of the ledger.
+func (l *BillingLedger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.journal == nil {
+		return nil
+	}
+	err := l.journal.Close()
+	l.journal = nil
+	return err
+}
+
+// BilledUntil returns the end of the last period billed for the sensor.
+func (l *BillingLedger) BilledUntil(s *Sensor) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t, ok := l.until[sensorKey(s)]
+	return t, ok
+}
+
+// Claim returns the windows of the audit period of the sensor not billed yet,
+// and holds the sensor until it is released. The part of the period already
+// billed is trimmed and a gap since the last period is backfilled.
+func (l *BillingLedger) Claim(s *Sensor) ([]BillingWindow, error) {
+	begin, err := time.Parse(time.RFC3339, s.AuditPeriodBeginning)
+	if err != nil {
+		return nil, ErrBadAuditPeriod
+	}
+	end, err := time.Parse(time.RFC3339, s.AuditPeriodEnding)
+	if err != nil || !end.After(begin) {
+		return nil, ErrBadAuditPeriod
+	}
+
+	key := sensorKey(s)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[key] {
+		return nil, ErrBillingInFlight
+	}
+	until, billed := l.until[key]
+	if billed && !end.After(until) {
+		return nil, nil
+	}
+
+	var windows []BillingWindow
+	switch {
+	case !billed || !begin.After(until):
+		if billed {
+			begin = until
+		}
+	case begin.Sub(until) <= GapTolerance:
+		begin = until
+	case begin.Sub(until) <= l.MaxBackfill:
+		log.Warnf("  billing gap of %s for %s since %s, backfilling", begin.Sub(until), key, until.Format(time.RFC3339))
+		windows = append(windows, BillingWindow{Begin: until, End: begin, Backfill: true})
+	default:
+		log.Errorf("  billing gap of %s for %s since %s is beyond the backfill of %s, not billed", begin.Sub(until), key, until.Format(time.RFC3339), l.MaxBackfill)
+	}
+	windows = append(windows, BillingWindow{Begin: begin, End: end})
+	l.inflight[key] = true
+	return windows, nil
+}
+
+// Begin journals a claimed window as about to be deducted.
+func (l *BillingLedger) Begin(s *Sensor, w BillingWindow, cost float64) error {
+	return l.record(s, w, cost, windowPending)
+}
+
+// Commit marks a claimed window as billed.
+func (l *BillingLedger) Commit(s *Sensor, w BillingWindow, cost float64) error {
+	return l.record(s, w, cost, windowBilled)
+}
+
+// Abort marks a claimed window as not deducted, it is billed again later.
+func (l *BillingLedger) Abort(s *Sensor, w BillingWindow) error {
+	return l.record(s, w, 0, windowAborted)
+}
+
+// Release lets the sensor be claimed again.
+func (l *BillingLedger) Release(s *Sensor) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.inflight, sensorKey(s))
+}
+
+func (l *BillingLedger) record(s *Sensor, w BillingWindow, cost float64, status string) error {
+	r := &LedgerRecord{
+		AccountId:    s.AccountId,
+		AssemblyId:   s.AssemblyId,
+		AssemblyName: s.AssemblyName,
+		SensorType:   s.SensorType,
+		Begin:        w.Begin,
+		End:          w.End,
+		Cost:         cost,
+		Backfill:     w.Backfill,
+		Status:       status,
+		At:           time.Now(),
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.inflight[r.key()] {
+		return ErrWindowNotClaimed
+	}
+	if l.journal != nil {
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if _, err = l.journal.Write(append(b, '\n')); err != nil {
+			return err
+		}
+		if err = l.journal.Sync(); err != nil {
+			return err
+		}
+	}
+	if status == windowBilled {
+		l.advance(r.key(), r.End)
+	}
+	return nil
+}
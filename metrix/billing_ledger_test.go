@@ -0,0 +1,140 @@
+package metrix
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func ledgerSensor(begin, end time.Time) *Sensor {
+	return &Sensor{
+		AccountId:            "info@megam.io",
+		AssemblyId:           "ASM001",
+		SensorType:           ONE_VM_SENSOR,
+		AuditPeriodBeginning: begin.Format(time.RFC3339),
+		AuditPeriodEnding:    end.Format(time.RFC3339),
+	}
+}
+
+func billAll(c *check.C, l *BillingLedger, s *Sensor) []BillingWindow {
+	ws, err := l.Claim(s)
+	c.Assert(err, check.IsNil)
+	for _, w := range ws {
+		c.Assert(l.Begin(s, w, 1), check.IsNil)
+		c.Assert(l.Commit(s, w, 1), check.IsNil)
+	}
+	l.Release(s)
+	return ws
+}
+
+func (s *S) TestBillingLedgerBillsOnce(c *check.C) {
+	l := NewBillingLedger(DefaultMaxBackfill)
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	sen := ledgerSensor(t0, t0.Add(10*time.Minute))
+	c.Assert(billAll(c, l, sen), check.HasLen, 1)
+	c.Assert(billAll(c, l, sen), check.HasLen, 0)
+
+	ws := billAll(c, l, ledgerSensor(t0.Add(5*time.Minute), t0.Add(15*time.Minute)))
+	c.Assert(ws, check.HasLen, 1)
+	c.Assert(ws[0].Begin.Equal(t0.Add(10*time.Minute)), check.Equals, true)
+	c.Assert(ws[0].Duration(), check.Equals, 5*time.Minute)
+}
+
+func (s *S) TestBillingLedgerHoldsClaimedSensor(c *check.C) {
+	l := NewBillingLedger(DefaultMaxBackfill)
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	sen := ledgerSensor(t0, t0.Add(10*time.Minute))
+	_, err := l.Claim(sen)
+	c.Assert(err, check.IsNil)
+	_, err = l.Claim(sen)
+	c.Assert(err, check.Equals, ErrBillingInFlight)
+	l.Release(sen)
+	c.Assert(billAll(c, l, sen), check.HasLen, 1)
+	c.Assert(l.Commit(sen, BillingWindow{}, 1), check.Equals, ErrWindowNotClaimed)
+}
+
+func (s *S) TestBillingLedgerBackfillsGaps(c *check.C) {
+	l := NewBillingLedger(time.Hour)
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	billAll(c, l, ledgerSensor(t0, t0.Add(10*time.Minute)))
+
+	ws := billAll(c, l, ledgerSensor(t0.Add(10*time.Minute+30*time.Second), t0.Add(20*time.Minute)))
+	c.Assert(ws, check.HasLen, 1)
+	c.Assert(ws[0].Begin.Equal(t0.Add(10*time.Minute)), check.Equals, true)
+
+	ws = billAll(c, l, ledgerSensor(t0.Add(50*time.Minute), t0.Add(60*time.Minute)))
+	c.Assert(ws, check.HasLen, 2)
+	c.Assert(ws[0].Backfill, check.Equals, true)
+	c.Assert(ws[0].Duration(), check.Equals, 30*time.Minute)
+
+	ws = billAll(c, l, ledgerSensor(t0.Add(3*time.Hour), t0.Add(3*time.Hour+10*time.Minute)))
+	c.Assert(ws, check.HasLen, 1)
+	c.Assert(ws[0].Backfill, check.Equals, false)
+}
+
+func (s *S) TestBillingLedgerReplaysJournal(c *check.C) {
+	path := filepath.Join(c.MkDir(), "billing.ledger")
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	l, err := OpenBillingLedger(path, DefaultMaxBackfill)
+	c.Assert(err, check.IsNil)
+	billAll(c, l, ledgerSensor(t0, t0.Add(10*time.Minute)))
+
+	sen := ledgerSensor(t0.Add(10*time.Minute), t0.Add(20*time.Minute))
+	ws, err := l.Claim(sen)
+	c.Assert(err, check.IsNil)
+	c.Assert(l.Begin(sen, ws[0], 1), check.IsNil)
+	c.Assert(l.Close(), check.IsNil)
+
+	l, err = OpenBillingLedger(path, DefaultMaxBackfill)
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	until, ok := l.BilledUntil(sen)
+	c.Assert(ok, check.Equals, true)
+	c.Assert(until.Equal(t0.Add(20*time.Minute)), check.Equals, true)
+	c.Assert(billAll(c, l, sen), check.HasLen, 0)
+}
+
+func (s *S) TestBillingLedgerBillsEverySnapshotOfAnAssembly(c *check.C) {
+	l := NewBillingLedger(DefaultMaxBackfill)
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	snap1, snap2 := ledgerSensor(t0, t0.Add(10*time.Minute)), ledgerSensor(t0, t0.Add(10*time.Minute))
+	snap1.SensorType, snap1.AssemblyName = SNAPSHOT_SENSOR, "SNP001"
+	snap2.SensorType, snap2.AssemblyName = SNAPSHOT_SENSOR, "SNP002"
+	c.Assert(billAll(c, l, snap1), check.HasLen, 1)
+	c.Assert(billAll(c, l, snap2), check.HasLen, 1)
+	c.Assert(billAll(c, l, snap1), check.HasLen, 0)
+}
+
+func (s *S) TestBillingLedgerCompactsJournal(c *check.C) {
+	path := filepath.Join(c.MkDir(), "billing.ledger")
+	t0 := time.Date(2016, 12, 1, 10, 0, 0, 0, time.UTC)
+	l, err := OpenBillingLedger(path, DefaultMaxBackfill)
+	c.Assert(err, check.IsNil)
+	for i := 0; i < 5; i++ {
+		begin := t0.Add(time.Duration(i) * 10 * time.Minute)
+		billAll(c, l, ledgerSensor(begin, begin.Add(10*time.Minute)))
+	}
+	other := ledgerSensor(t0, t0.Add(10*time.Minute))
+	other.AssemblyId = "ASM002"
+	billAll(c, l, other)
+	c.Assert(l.Close(), check.IsNil)
+
+	l, err = OpenBillingLedger(path, DefaultMaxBackfill)
+	c.Assert(err, check.IsNil)
+	defer l.Close()
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(bytes.Count(b, []byte("\n")), check.Equals, 2)
+	until, ok := l.BilledUntil(ledgerSensor(t0, t0))
+	c.Assert(ok, check.Equals, true)
+	c.Assert(until.Equal(t0.Add(50*time.Minute)), check.Equals, true)
+}
+
+func (s *S) TestBillingLedgerRejectsBadPeriod(c *check.C) {
+	l := NewBillingLedger(DefaultMaxBackfill)
+	_, err := l.Claim(s.sensor)
+	c.Assert(err, check.Equals, ErrBadAuditPeriod)
+}
@@ -12,6 +12,7 @@ const DOCKER = "docker"
 
 type Swarm struct {
 	Url            string
+	Region         string
 	DefaultUnits map[string]string
 	RawStatus      []interface{}
 }
@@ -65,9 +66,12 @@ func (s *Swarm) Collect(c *MetricsCollection) (e error) {
 }
 
 func (s *Swarm) DeductBill(c *MetricsCollection) (e error) {
+	if c.expired() {
+		return ErrExpired
+	}
 	for _, mc := range c.Sensors {
 		if mc.AccountId != "" && mc.AssemblyId != "" {
-			mkBalance(mc, s.DefaultUnits)
+			mkBalance(mc, s.DefaultUnits, s.Region)
 		}
 	}
 	return
@@ -105,6 +109,7 @@ func (s *Swarm) CollectMetricsFromStats(mc *MetricsCollection, stats []*Stats) {
 			sc.AssemblyName = h.AssemblyName
 			sc.AssembliesId = h.AssembliesId
 			sc.Source = s.Prefix()
+			sc.Region = s.Region
 			sc.Message = "container billing"
 			sc.Status = h.Status
 			sc.AuditPeriodBeginning = time.Now().Add(-MetricsInterval).Format(time.RFC3339)
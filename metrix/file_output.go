@@ -0,0 +1,162 @@
+package metrix
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	FILE_OUTPUT = "file"
+
+	DefaultFileMaxSize    = 100 // MB
+	DefaultFileMaxBackups = 5
+)
+
+// FileConfig writes the sensors as json lines to a file, rotated when it
+// reaches MaxSize MB. MaxBackups rotated files are kept (path.1 is the newest).
+type FileConfig struct {
+	BatchConfig
+	Enabled    bool   `toml:"enabled"`
+	Path       string `toml:"path"`
+	MaxSize    int    `toml:"max_size"`
+	MaxBackups int    `toml:"max_backups"`
+}
+
+func init() {
+	RegisterOutput(FILE_OUTPUT, func(c *OutputsConfig) (Output, error) {
+		if !c.File.Enabled {
+			return nil, nil
+		}
+		return NewFileOutput(c.File)
+	})
+}
+
+type fileOutput struct {
+	c    FileConfig
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func NewFileOutput(c FileConfig) (Output, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = DefaultFileMaxSize
+	}
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = DefaultFileMaxBackups
+	}
+	o := &fileOutput{c: c}
+	if err := o.open(); err != nil {
+		return nil, err
+	}
+	return newBatchedOutput(FILE_OUTPUT, c.BatchConfig, o.append, o.Close), nil
+}
+
+func (o *fileOutput) open() error {
+	if err := os.MkdirAll(filepath.Dir(o.c.Path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.c.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	o.f, o.size = f, fi.Size()
+	return nil
+}
+
+// append writes the batch, on failure the file is truncated back to the end
+// of the last sensor flushed so that the retry doesn't write it twice.
+func (o *fileOutput) append(batch Sensors) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.f == nil {
+		if err := o.open(); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriter(o.f)
+	written, offset := 0, o.size
+	for i, s := range batch {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return o.undo(written, offset, err)
+		}
+		if o.size > 0 && o.size+int64(len(b)+1) > int64(o.c.MaxSize)*1024*1024 {
+			if err = w.Flush(); err != nil {
+				return o.undo(written, offset, err)
+			}
+			written, offset = i, 0
+			if err = o.rotate(); err != nil {
+				return o.undo(written, offset, err)
+			}
+			w.Reset(o.f)
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+		o.size += int64(len(b) + 1)
+	}
+	if err := w.Flush(); err != nil {
+		return o.undo(written, offset, err)
+	}
+	return nil
+}
+
+// undo truncates the file to offset, and says how many sensors of the batch
+// were written before it.
+func (o *fileOutput) undo(written int, offset int64, err error) error {
+	if o.f != nil {
+		if terr := o.f.Truncate(offset); terr != nil {
+			log.Errorf("  metrics output %s truncate : %s", FILE_OUTPUT, terr)
+		}
+		o.size = offset
+	}
+	if written > 0 {
+		return &partialWrite{Written: written, Err: err}
+	}
+	return err
+}
+
+// rotate shifts path.n to path.n+1, dropping the oldest, and reopens path.
+func (o *fileOutput) rotate() error {
+	if err := o.f.Close(); err != nil {
+		return err
+	}
+	o.f = nil
+	os.Remove(backupName(o.c.Path, o.c.MaxBackups))
+	for i := o.c.MaxBackups - 1; i > 0; i-- {
+		os.Rename(backupName(o.c.Path, i), backupName(o.c.Path, i+1))
+	}
+	if err := os.Rename(o.c.Path, backupName(o.c.Path, 1)); err != nil {
+		return err
+	}
+	return o.open()
+}
+
+func backupName(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+func (o *fileOutput) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.f == nil {
+		return nil
+	}
+	err := o.f.Close()
+	o.f = nil
+	return err
+}
@@ -0,0 +1,123 @@
+package metrix
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	INFLUXDB_OUTPUT = "influxdb"
+
+	// InfluxDBMeasurement is the measurement of the sensor metrics.
+	InfluxDBMeasurement = "vertice_sensor"
+)
+
+// InfluxDBConfig writes the sensors in the line protocol to an InfluxDB.
+type InfluxDBConfig struct {
+	BatchConfig
+	Enabled  bool   `toml:"enabled"`
+	Url      string `toml:"url"`
+	Database string `toml:"database"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+func init() {
+	RegisterOutput(INFLUXDB_OUTPUT, func(c *OutputsConfig) (Output, error) {
+		if !c.InfluxDB.Enabled {
+			return nil, nil
+		}
+		return NewInfluxDBOutput(c.InfluxDB)
+	})
+}
+
+type influxDBOutput struct {
+	c        InfluxDBConfig
+	write    string
+	hostname string
+	client   *http.Client
+}
+
+func NewInfluxDBOutput(c InfluxDBConfig) (Output, error) {
+	if c.Url == "" || c.Database == "" {
+		return nil, fmt.Errorf("url and database are required")
+	}
+	q := url.Values{"db": {c.Database}, "precision": {"ns"}}
+	hn, _ := os.Hostname()
+	o := &influxDBOutput{
+		c:        c,
+		write:    strings.TrimRight(c.Url, "/") + "/write?" + q.Encode(),
+		hostname: hn,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	return newBatchedOutput(INFLUXDB_OUTPUT, c.BatchConfig, o.post, nil), nil
+}
+
+func (o *influxDBOutput) post(batch Sensors) error {
+	var b bytes.Buffer
+	for _, s := range batch {
+		writeLines(&b, s, o.hostname)
+	}
+	req, err := http.NewRequest("POST", o.write, &b)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if o.c.Username != "" {
+		req.SetBasicAuth(o.c.Username, o.c.Password)
+	}
+	res, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		return fmt.Errorf("influxdb %s : %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// writeLines writes a line per metric of the sensor, with its usage and rate.
+func writeLines(w *bytes.Buffer, s *Sensor, hostname string) {
+	at := s.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	for _, m := range s.Metrics {
+		var fields []string
+		if v, err := strconv.ParseFloat(m.MetricUnits, 64); err == nil {
+			fields = append(fields, "usage="+strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		if v, err := strconv.ParseFloat(m.MetricValue, 64); err == nil {
+			fields = append(fields, "rate="+strconv.FormatFloat(v, 'f', -1, 64))
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		w.WriteString(InfluxDBMeasurement)
+		for _, t := range [][2]string{
+			{"account_id", s.AccountId},
+			{"assembly_id", s.AssemblyId},
+			{"host", hostname},
+			{"metric", m.MetricName},
+			{"region", s.Region},
+			{"sensor_type", s.SensorType},
+		} {
+			if t[1] != "" {
+				w.WriteString("," + t[0] + "=" + tagEscaper.Replace(t[1]))
+			}
+		}
+		w.WriteString(" " + strings.Join(fields, ",") + " " + strconv.FormatInt(at.UnixNano(), 10) + "\n")
+	}
+}
+
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
@@ -0,0 +1,88 @@
+package metrix
+
+import (
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
+)
+
+// DefaultBillingPeriod is billed when a box was never billed before.
+const DefaultBillingPeriod = 10 * time.Minute
+
+// ComputeMetrics returns the metrics of the compute of a box (cores, memory
+// and hdd in MB) at their costs per hour.
+func ComputeMetrics(b *provision.Box, cpuCost, memoryCost, diskCost string) Metrics {
+	ms := Metrics{
+		&Metric{MetricName: CPU_COST, MetricValue: cpuCost, MetricUnits: strconv.FormatUint(b.GetCpushare(), 10), MetricType: "delta"},
+		&Metric{MetricName: MEMORY_COST, MetricValue: memoryCost, MetricUnits: strconv.FormatUint(b.GetMemory(), 10), MetricType: "delta"},
+	}
+	if diskCost != "" {
+		ms = append(ms, &Metric{MetricName: DISK_COST, MetricValue: diskCost, MetricUnits: strconv.FormatUint(b.GetHDD(), 10), MetricType: "delta"})
+	}
+	return ms
+}
+
+// Costs are the costs per hour of the compute of a box.
+type Costs struct {
+	Cpu    string
+	Memory string
+	Disk   string
+}
+
+// ContainerCosts returns the container costs of the assembly, the ones of the
+// region units when it has none.
+func ContainerCosts(asm *carton.Assembly, units map[string]string) Costs {
+	c := Costs{Cpu: asm.GetContainerCpuCost(), Memory: asm.GetContainerMemoryCost()}
+	if c.Cpu == "" {
+		c.Cpu = units[CPU_COST_PER_HOUR]
+	}
+	if c.Memory == "" {
+		c.Memory = units[RAM_COST_PER_HOUR]
+	}
+	return c
+}
+
+// Deduct bills the compute of the box named name at the costs, for the time
+// elapsed since its assembly was billed last. units are the ones of the region
+// of the box.
+func Deduct(asm *carton.Assembly, name string, b *provision.Box, units map[string]string, costs Costs) error {
+	last, billed := asm.LastBilledAt()
+	end := time.Now()
+	start, elapsed := BillingPeriod(last, billed, end)
+	ms := ComputeMetrics(b, costs.Cpu, costs.Memory, costs.Disk)
+	cost := Prices.CostFor(ms, b.Region, units, elapsed)
+	s := &Sensor{AccountId: asm.AccountId, AssemblyId: asm.Id, AssemblyName: name}
+	if err := deduct(s, BillingWindow{Begin: start, End: end}, cost); err != nil {
+		return err
+	}
+	log.Debugf("  billed %s %.6f for %s (%s)", name, cost, elapsed, b.Compute.String())
+	return asm.SetLastBilledAt(end)
+}
+
+// BillingPeriod returns the period to bill, from the last billing (or the
+// default period before end) till end.
+func BillingPeriod(last time.Time, billed bool, end time.Time) (time.Time, time.Duration) {
+	if !billed || last.After(end) {
+		last = end.Add(-DefaultBillingPeriod)
+	}
+	return last, end.Sub(last)
+}
+
+// ReservationPeriod is the period of the cost estimated before a box is
+// deployed, the metrics interval when metricsd runs.
+func ReservationPeriod() time.Duration {
+	if MetricsInterval > 0 {
+		return MetricsInterval
+	}
+	return DefaultBillingPeriod
+}
+
+// EstimateCost returns the cost of the compute of a box in its region for one
+// reservation period.
+func EstimateCost(b *provision.Box, cpuCost, memoryCost, diskCost string, units map[string]string) float64 {
+	ms := ComputeMetrics(b, cpuCost, memoryCost, diskCost)
+	return Prices.CostFor(ms, b.Region, units, ReservationPeriod())
+}
@@ -0,0 +1,47 @@
+package metrix
+
+import (
+	"time"
+
+	"github.com/megamsys/libgo/pairs"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestComputeMetricsCost(c *check.C) {
+	b := &provision.Box{Compute: provision.BoxCompute{Cpushare: "2", Memory: "2048 MB", HDD: "20 GB"}}
+	ms := ComputeMetrics(b, "0.25", "0.5", "")
+	c.Assert(ms, check.HasLen, 2)
+	c.Assert(ms[0].MetricUnits, check.Equals, "2")
+	c.Assert(ms[1].MetricUnits, check.Equals, "2048")
+	units := map[string]string{CPU_UNIT: "1", MEMORY_UNIT: "1024"}
+	c.Assert(NewPricing(nil).CostFor(ms, "", units, 2*time.Hour), check.Equals, 3.0)
+}
+
+func (s *S) TestBillingPeriod(c *check.C) {
+	end := time.Now()
+	start, d := BillingPeriod(time.Time{}, false, end)
+	c.Assert(d, check.Equals, DefaultBillingPeriod)
+	c.Assert(start.Equal(end.Add(-DefaultBillingPeriod)), check.Equals, true)
+	start, d = BillingPeriod(end.Add(-3*time.Hour), true, end)
+	c.Assert(d, check.Equals, 3*time.Hour)
+}
+
+func (s *S) TestEstimateCost(c *check.C) {
+	defer func(d time.Duration) { MetricsInterval = d }(MetricsInterval)
+	b := &provision.Box{Compute: provision.BoxCompute{Cpushare: "2", Memory: "2048 MB", HDD: "20 GB"}}
+	units := map[string]string{CPU_UNIT: "1", MEMORY_UNIT: "1024"}
+	MetricsInterval = 2 * time.Hour
+	c.Assert(EstimateCost(b, "0.25", "0.5", "", units), check.Equals, 3.0)
+	MetricsInterval = 0
+	c.Assert(ReservationPeriod(), check.Equals, DefaultBillingPeriod)
+}
+
+func (s *S) TestContainerCostsFallBackToTheRegion(c *check.C) {
+	units := map[string]string{CPU_COST_PER_HOUR: "0.1", RAM_COST_PER_HOUR: "0.2"}
+	asm := &carton.Assembly{Inputs: make(pairs.JsonPairs, 0)}
+	c.Assert(ContainerCosts(asm, units), check.Equals, Costs{Cpu: "0.1", Memory: "0.2"})
+	asm.Inputs.NukeAndSet(map[string][]string{carton.CONTAINER_CPU_COST: []string{"0.5"}})
+	c.Assert(ContainerCosts(asm, units), check.Equals, Costs{Cpu: "0.5", Memory: "0.2"})
+}
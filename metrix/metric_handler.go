@@ -8,8 +8,10 @@ type MetricCollector interface {
 	Collect(*MetricsCollection) error
 }
 
-func (h *MetricHandler) Collect(c MetricCollector) (Sensors, error) {
-	sc := &MetricsCollection{Prefix: c.Prefix()}
+// Collect collects and bills the sensors, unless expired is closed before the
+// billing starts. A nil expired never expires.
+func (h *MetricHandler) Collect(c MetricCollector, expired <-chan struct{}) (Sensors, error) {
+	sc := &MetricsCollection{Prefix: c.Prefix(), Expired: expired}
 	e := c.Collect(sc)
 	return sc.Sensors, e
 }
@@ -4,9 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
-	"strconv"
 	"strings"
-	"time"
 )
 
 const (
@@ -23,6 +21,16 @@ const (
 	STORAGE_COST = "storage_cost"
 	STORAGE_UNIT = "storage_unit"
 
+	// utilisation of the VMs
+	CPU_USAGE        = "cpu_usage"  //percentage of a cpu
+	MEMORY_USED      = "memory_used" //in MB
+	NETWORK_RX       = "network_rx_bytes"
+	NETWORK_TX       = "network_tx_bytes"
+	DISK_READ_BYTES  = "disk_read_bytes"
+	DISK_WRITE_BYTES = "disk_write_bytes"
+	DISK_READ_IOPS   = "disk_read_ops"
+	DISK_WRITE_IOPS  = "disk_write_ops"
+
 )
 
 type MetricsMap map[string]int64
@@ -61,34 +69,3 @@ func parseStringToStruct(str string, data interface{}) error {
 	}
 	return nil
 }
-
-func (m *Metrics) Totalcost(units map[string]string) string {
-
-	//have to calculate metrics based on discount when flavour increases
-	var cost, diff_ival float64
-	defaultCpuUnit, _ := strconv.ParseFloat(units[CPU_UNIT], 64)
-	defaultRamUnit, _ := strconv.ParseFloat(units[MEMORY_UNIT], 64)
-	defaultDiskUnit, _ := strconv.ParseFloat(units[DISK_UNIT], 64)
-	defaultStorageUnit, _ := strconv.ParseFloat(units[STORAGE_UNIT], 64)
-
-	//Only For Hourly Billing
-
-  diff_ival = (1*time.Hour).Minutes()/MetricsInterval.Minutes()
-
-	for _, in := range *m {
-		consume, _ := strconv.ParseFloat(in.MetricValue, 64)
-		unit, _ := strconv.ParseFloat(in.MetricUnits, 64)
-		switch in.MetricName {
-		case CPU_COST:
-			cost = cost + (unit/defaultCpuUnit) * consume
-		case MEMORY_COST:
-			cost = cost + (unit/defaultRamUnit) * consume
-		case DISK_COST:
-			cost = cost + (unit/defaultDiskUnit) * consume
-		case STORAGE_COST:
-		  cost = cost + (unit/defaultStorageUnit) * consume
-		}
-	}
-	res := strconv.FormatFloat(cost/float64(diff_ival), 'f', 6, 64)
-	return res   //for 1 hr to 10min It should be customized
-}
@@ -1,6 +1,7 @@
 package metrix
 
 import (
+	"errors"
 	"gopkg.in/yaml.v2"
 	"time"
 	)
@@ -9,9 +10,25 @@ type Sensors []*Sensor
 
 var MetricsInterval time.Duration
 
+// ErrExpired is returned by a collection that expired before it billed, its
+// sensors are dropped.
+var ErrExpired = errors.New("collection expired before billing")
+
 type MetricsCollection struct {
 	Prefix  string
 	Sensors Sensors
+	Expired <-chan struct{}
+}
+
+// expired says whether the collection timed out, an expired collection is
+// not billed anymore.
+func (m *MetricsCollection) expired() bool {
+	select {
+	case <-m.Expired:
+		return true
+	default:
+		return false
+	}
 }
 
 func (m *MetricsCollection) Add(s *Sensor) {
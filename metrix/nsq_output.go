@@ -0,0 +1,70 @@
+package metrix
+
+import (
+	"encoding/json"
+	"fmt"
+
+	nsqp "github.com/crackcomm/nsqueue/producer"
+	"github.com/megamsys/vertice/meta"
+)
+
+const (
+	NSQ_OUTPUT = "nsq"
+
+	DefaultMetricsTopic = "metrics"
+)
+
+// NSQConfig publishes every sensor as json to an nsq topic, on the nsqd of
+// the meta config when Nsqd is empty. A batch retried after a partial
+// failure is published again, the consumers see a sensor at least once.
+type NSQConfig struct {
+	BatchConfig
+	Enabled bool   `toml:"enabled"`
+	Topic   string `toml:"topic"`
+	Nsqd    string `toml:"nsqd"`
+}
+
+func init() {
+	RegisterOutput(NSQ_OUTPUT, func(c *OutputsConfig) (Output, error) {
+		if !c.NSQ.Enabled {
+			return nil, nil
+		}
+		return NewNSQOutput(c.NSQ)
+	})
+}
+
+type nsqOutput struct {
+	c NSQConfig
+}
+
+func NewNSQOutput(c NSQConfig) (Output, error) {
+	if c.Topic == "" {
+		c.Topic = DefaultMetricsTopic
+	}
+	if c.Nsqd == "" {
+		if meta.MC == nil || len(meta.MC.NSQd) == 0 {
+			return nil, fmt.Errorf("nsqd is required")
+		}
+		c.Nsqd = meta.MC.NSQd[0]
+	}
+	o := &nsqOutput{c: c}
+	return newBatchedOutput(NSQ_OUTPUT, c.BatchConfig, o.publish, nil), nil
+}
+
+func (o *nsqOutput) publish(batch Sensors) error {
+	pons := nsqp.New()
+	if err := pons.Connect(o.c.Nsqd); err != nil {
+		return err
+	}
+	defer pons.Stop()
+	for _, s := range batch {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return err
+		}
+		if err = pons.Publish(o.c.Topic, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
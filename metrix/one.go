@@ -2,6 +2,7 @@ package metrix
 
 import (
 	"encoding/xml"
+	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/opennebula-go/metrics"
 	"github.com/megamsys/vertice/carton"
 	"io/ioutil"
@@ -19,6 +20,7 @@ type OpenNebula struct {
 	Region       string
 	DefaultUnits map[string]string
 	RawStatus    []byte
+	monitoring   map[string]*VMMonitoring
 }
 
 func (on *OpenNebula) Prefix() string {
@@ -26,8 +28,12 @@ func (on *OpenNebula) Prefix() string {
 }
 
 func (on *OpenNebula) DeductBill(c *MetricsCollection) (e error) {
+	if c.expired() {
+		return ErrExpired
+	}
+	Polled.Keep(on.Region, on.monitoring)
 	for _, mc := range c.Sensors {
-			mkBalance(mc, on.DefaultUnits)
+			mkBalance(mc, on.DefaultUnits, on.Region)
 	}
 	return
 }
@@ -43,6 +49,11 @@ func (on *OpenNebula) Collect(c *MetricsCollection) (e error) {
 	if e != nil {
 		return
 	}
+	mon, err := ParseMonitoring(b)
+	if err != nil {
+		log.Warnf("  one monitoring of %s unparsable : %s", on.Region, err)
+	}
+	on.monitoring = mon
 	on.CollectMetricsFromStats(c, s)
 	e = on.DeductBill(c)
 	return
@@ -84,6 +95,7 @@ func (on *OpenNebula) CollectMetricsFromStats(mc *MetricsCollection, s *metrics.
 			sc.AssemblyName = h.AssemblyName()
 			sc.AssembliesId = h.AssembliesId()
 			sc.Source = on.Prefix()
+			sc.Region = on.Region
 			sc.Message = "vm billing"
 			sc.Status = h.State()
 			sc.AuditPeriodBeginning = time.Now().Add(-MetricsInterval).Format(time.RFC3339) //time.Unix(h.PStime, 0).String()
@@ -92,6 +104,9 @@ func (on *OpenNebula) CollectMetricsFromStats(mc *MetricsCollection, s *metrics.
 			sc.addMetric(CPU_COST, h.CpuCost(), usage[metrics.CPU], "delta")
 			sc.addMetric(MEMORY_COST, h.MemoryCost(), usage[metrics.MEMORY], "delta")
 			sc.addMetric(DISK_COST, h.DiskCost(),usage[metrics.DISKS] , "delta")
+			if m, ok := on.monitoring[h.AssemblyId()]; ok {
+				m.addTo(sc, Polled.Last(on.Region, h.AssemblyId()))
+			}
 			sc.CreatedAt = time.Now()
 			if sc.isBillable() {
 					mc.Add(sc)
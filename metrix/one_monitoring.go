@@ -0,0 +1,130 @@
+package metrix
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// VMMonitoring is the utilisation of a VM polled by OpenNebula. The network
+// and disk counters are totals since the VM booted.
+type VMMonitoring struct {
+	AssemblyId  string  `xml:"VM>TEMPLATE>CONTEXT>ASSEMBLY_ID"`
+	LastPoll    int64   `xml:"VM>LAST_POLL"`
+	CPU         float64 `xml:"VM>MONITORING>CPU"`    //percentage of a cpu
+	Memory      int64   `xml:"VM>MONITORING>MEMORY"` //in KB
+	NetRx       int64   `xml:"VM>MONITORING>NETRX"`  //in bytes
+	NetTx       int64   `xml:"VM>MONITORING>NETTX"`
+	DiskRdBytes int64   `xml:"VM>MONITORING>DISKRDBYTES"`
+	DiskWrBytes int64   `xml:"VM>MONITORING>DISKWRBYTES"`
+	DiskRdIOps  int64   `xml:"VM>MONITORING>DISKRDIOPS"`
+	DiskWrIOps  int64   `xml:"VM>MONITORING>DISKWRIOPS"`
+}
+
+// ParseMonitoring returns the last monitoring of every VM (by assembly id) of
+// the HISTORY records of a showback.
+func ParseMonitoring(b []byte) (map[string]*VMMonitoring, error) {
+	ms := make(map[string]*VMMonitoring)
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return ms, nil
+		}
+		if err != nil {
+			return ms, err
+		}
+		se, ok := t.(xml.StartElement)
+		if !ok || se.Name.Local != "HISTORY" {
+			continue
+		}
+		m := &VMMonitoring{}
+		if err = d.DecodeElement(m, &se); err != nil {
+			return ms, err
+		}
+		if m.AssemblyId == "" {
+			continue
+		}
+		if last, ok := ms[m.AssemblyId]; !ok || m.LastPoll >= last.LastPoll {
+			ms[m.AssemblyId] = m
+		}
+	}
+}
+
+// addTo adds the utilisation to the metrics of the sensor, they are free
+// unless a rate plan prices them. The network and disk metrics are what was
+// used since prev, the previous poll of the VM, none when there is no prev.
+func (m *VMMonitoring) addTo(s *Sensor, prev *VMMonitoring) {
+	d := &VMMonitoring{}
+	if prev != nil {
+		d = &VMMonitoring{
+			NetRx:       counterDelta(m.NetRx, prev.NetRx),
+			NetTx:       counterDelta(m.NetTx, prev.NetTx),
+			DiskRdBytes: counterDelta(m.DiskRdBytes, prev.DiskRdBytes),
+			DiskWrBytes: counterDelta(m.DiskWrBytes, prev.DiskWrBytes),
+			DiskRdIOps:  counterDelta(m.DiskRdIOps, prev.DiskRdIOps),
+			DiskWrIOps:  counterDelta(m.DiskWrIOps, prev.DiskWrIOps),
+		}
+	}
+	s.addMetric(CPU_USAGE, "0", strconv.FormatFloat(m.CPU, 'f', 2, 64), "gauge")
+	s.addMetric(MEMORY_USED, "0", strconv.FormatInt(m.Memory/1024, 10), "gauge")
+	s.addMetric(NETWORK_RX, "0", strconv.FormatInt(d.NetRx, 10), "delta")
+	s.addMetric(NETWORK_TX, "0", strconv.FormatInt(d.NetTx, 10), "delta")
+	s.addMetric(DISK_READ_BYTES, "0", strconv.FormatInt(d.DiskRdBytes, 10), "delta")
+	s.addMetric(DISK_WRITE_BYTES, "0", strconv.FormatInt(d.DiskWrBytes, 10), "delta")
+	s.addMetric(DISK_READ_IOPS, "0", strconv.FormatInt(d.DiskRdIOps, 10), "delta")
+	s.addMetric(DISK_WRITE_IOPS, "0", strconv.FormatInt(d.DiskWrIOps, 10), "delta")
+}
+
+// counterDelta returns the increase of a counter, a counter lower than
+// before was reset by a reboot of the VM and counts from zero.
+func counterDelta(now, before int64) int64 {
+	if now < before {
+		return now
+	}
+	return now - before
+}
+
+// Polled keeps the last monitoring of every VM, to turn its counters into
+// the usage of a collection.
+var Polled = &vmPolls{last: make(map[string]vmPoll)}
+
+type vmPolls struct {
+	mu   sync.Mutex
+	last map[string]vmPoll
+}
+
+type vmPoll struct {
+	m  *VMMonitoring
+	at time.Time
+}
+
+// Last returns the last monitoring kept of the VM of the assembly in the
+// region, nil when there is none.
+func (p *vmPolls) Last(region, assemblyId string) *VMMonitoring {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if v, ok := p.last[region+"/"+assemblyId]; ok {
+		return v.m
+	}
+	return nil
+}
+
+// Keep keeps the monitoring of the VMs of the region once they are billed,
+// and drops the VMs not polled for two intervals.
+func (p *vmPolls) Keep(region string, ms map[string]*VMMonitoring) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for id, m := range ms {
+		p.last[region+"/"+id] = vmPoll{m: m, at: now}
+	}
+	for k, v := range p.last {
+		if now.Sub(v.at) > 2*MetricsInterval {
+			delete(p.last, k)
+		}
+	}
+}
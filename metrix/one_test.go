@@ -8,7 +8,7 @@ import (
 func (s *S) TestParseOpenNebulaCollector(c *check.C) {
 	mh := &MetricHandler{}
 	on := &OpenNebula{RawStatus: s.testxml}
-	all, _ := mh.Collect(on)
+	all, _ := mh.Collect(on, nil)
 
 	for _, m := range all {
 		c.Assert(len(m.Id) > 0, check.Equals, false)
@@ -35,3 +35,39 @@ func (s *S) TestParseOpenNebulaCollector(c *check.C) {
 
 	}
 }
+
+func (s *S) TestParseOpenNebulaMonitoring(c *check.C) {
+	ms, err := ParseMonitoring(s.testxml)
+	c.Assert(err, check.IsNil)
+	m, ok := ms["ASM1299290465459372032"]
+	c.Assert(ok, check.Equals, true)
+	c.Assert(m.CPU, check.Equals, 1.5)
+	c.Assert(m.Memory, check.Equals, int64(1048576))
+	c.Assert(m.NetRx, check.Equals, int64(121862104))
+	c.Assert(m.NetTx, check.Equals, int64(14814457))
+
+	sc := NewSensor(ONE_VM_SENSOR)
+	m.addTo(sc, nil)
+	c.Assert(sc.Metrics, check.HasLen, 8)
+	c.Assert(sc.Metrics[1].MetricName, check.Equals, MEMORY_USED)
+	c.Assert(sc.Metrics[1].MetricUnits, check.Equals, "1024")
+	c.Assert(sc.Metrics[2].MetricName, check.Equals, NETWORK_RX)
+	c.Assert(sc.Metrics[2].MetricUnits, check.Equals, "0")
+	c.Assert(Prices.CostFor(sc.Metrics, "", nil, MetricsInterval), check.Equals, 0.0)
+}
+
+func (s *S) TestVMMonitoringAddsTheCountersSincePrev(c *check.C) {
+	prev := &VMMonitoring{AssemblyId: "ASM001", NetRx: 1000, NetTx: 500, DiskRdIOps: 40}
+	m := &VMMonitoring{AssemblyId: "ASM001", NetRx: 1600, NetTx: 200, DiskRdIOps: 50}
+	sc := NewSensor(ONE_VM_SENSOR)
+	m.addTo(sc, prev)
+	c.Assert(sc.Metrics[2].MetricUnits, check.Equals, "600")
+	c.Assert(sc.Metrics[3].MetricUnits, check.Equals, "200")
+	c.Assert(sc.Metrics[6].MetricUnits, check.Equals, "10")
+	c.Assert(sc.Metrics[2].MetricType, check.Equals, "delta")
+
+	c.Assert(Polled.Last("paris", "ASM001"), check.IsNil)
+	Polled.Keep("paris", map[string]*VMMonitoring{"ASM001": m})
+	c.Assert(Polled.Last("paris", "ASM001"), check.Equals, m)
+	c.Assert(Polled.Last("chennai", "ASM001"), check.IsNil)
+}
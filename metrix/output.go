@@ -3,31 +3,246 @@ package metrix
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/toml"
+)
+
+const (
+	DefaultBatchSize    = 500
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = time.Second
 )
 
+// Output writes the collected sensors to a sink.
+type Output interface {
+	Name() string
+	Write(Sensors) error
+	Close() error
+}
+
+// OutputFactory returns the output of the config, nil when it isn't enabled.
+type OutputFactory func(*OutputsConfig) (Output, error)
+
+var (
+	outputsMu sync.Mutex
+	outputs   = make(map[string]OutputFactory)
+)
+
+// RegisterOutput registers a sink, the sinks register themselves when loaded.
+func RegisterOutput(name string, f OutputFactory) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	outputs[name] = f
+}
+
+// BatchConfig says how many sensors a sink writes at once, and how a failed
+// batch is retried.
+type BatchConfig struct {
+	BatchSize    int           `toml:"batch_size"`
+	MaxRetries   int           `toml:"max_retries"`
+	RetryBackoff toml.Duration `toml:"retry_backoff"`
+}
+
+// OutputsConfig enables the sinks of the collected sensors, many at once.
+type OutputsConfig struct {
+	Scylla   bool           `toml:"scylla"`
+	Stdout   bool           `toml:"stdout"`
+	InfluxDB InfluxDBConfig `toml:"influxdb"`
+	File     FileConfig     `toml:"file"`
+	NSQ      NSQConfig      `toml:"nsq"`
+}
+
+// Enabled returns the names of the enabled sinks.
+func (c *OutputsConfig) Enabled() []string {
+	var names []string
+	if c.Scylla {
+		names = append(names, SCYLLA_OUTPUT)
+	}
+	if c.Stdout {
+		names = append(names, STDOUT_OUTPUT)
+	}
+	if c.InfluxDB.Enabled {
+		names = append(names, INFLUXDB_OUTPUT)
+	}
+	if c.File.Enabled {
+		names = append(names, FILE_OUTPUT)
+	}
+	if c.NSQ.Enabled {
+		names = append(names, NSQ_OUTPUT)
+	}
+	return names
+}
+
+// OutputHandler writes the sensors to the enabled sinks, and keeps them for
+// the /metrics endpoint.
 type OutputHandler struct {
-	ScyllaAddress string
-	Hostname      string
+	Outputs []Output
+}
+
+// NewOutputHandler returns the handler of the sinks enabled in the config,
+// stdout when none is.
+func NewOutputHandler(c *OutputsConfig) (*OutputHandler, error) {
+	outputsMu.Lock()
+	defer outputsMu.Unlock()
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	o := &OutputHandler{}
+	for _, name := range names {
+		out, err := outputs[name](c)
+		if err != nil {
+			o.Close()
+			return nil, fmt.Errorf("metrics output %s : %s", name, err)
+		}
+		if out != nil {
+			o.Outputs = append(o.Outputs, out)
+		}
+	}
+	if len(o.Outputs) == 0 {
+		o.Outputs = append(o.Outputs, &stdoutOutput{})
+	}
+	return o, nil
+}
+
+// WriteMetrics writes the sensors to all the sinks, a failing sink doesn't
+// stop the others.
+func (o *OutputHandler) WriteMetrics(all Sensors) error {
+	Exposed.Write(all)
+	var failed []string
+	for _, out := range o.Outputs {
+		if err := out.Write(all); err != nil {
+			log.Errorf("  metrics output %s failed : %s", out.Name(), err)
+			failed = append(failed, out.Name())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("metrics outputs failed : %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func (o *OutputHandler) Close() error {
+	for _, out := range o.Outputs {
+		if err := out.Close(); err != nil {
+			log.Errorf("  metrics output %s close : %s", out.Name(), err)
+		}
+	}
+	return nil
+}
+
+// batchedOutput writes the sensors of a sink by batches, retrying a failed
+// batch with an exponential backoff.
+type batchedOutput struct {
+	name    string
+	c       BatchConfig
+	write   func(Sensors) error
+	close   func() error
+	backoff func(time.Duration)
 }
 
-func (o *OutputHandler) WriteMetrics(all Sensors) (e error) {
-	if o.Hostname == "" {
-		hn, e := os.Hostname()
-		if e != nil {
-			return e
+func newBatchedOutput(name string, c BatchConfig, write func(Sensors) error, close func() error) *batchedOutput {
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultMaxRetries
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = toml.Duration(DefaultRetryBackoff)
+	}
+	return &batchedOutput{name: name, c: c, write: write, close: close, backoff: time.Sleep}
+}
+
+func (b *batchedOutput) Name() string {
+	return b.name
+}
+
+func (b *batchedOutput) Write(all Sensors) error {
+	for i := 0; i < len(all); i += b.c.BatchSize {
+		end := i + b.c.BatchSize
+		if end > len(all) {
+			end = len(all)
+		}
+		if err := b.writeBatch(all[i:end]); err != nil {
+			return err
 		}
-		o.Hostname = hn
 	}
-	sent := false
-	if len(o.ScyllaAddress) > 0 {
-		e = SendMetricsToScylla(all, o.Hostname)
-		sent = true
+	return nil
+}
+
+// writeBatch retries the sensors of the batch not written yet, a sink that
+// wrote some of them says so with a partialWrite.
+func (b *batchedOutput) writeBatch(batch Sensors) error {
+	delay := time.Duration(b.c.RetryBackoff)
+	var err error
+	for attempt := 0; attempt <= b.c.MaxRetries; attempt++ {
+		if attempt > 0 {
+			log.Warnf("  metrics output %s retry %d of %d sensors : %s", b.name, attempt, len(batch), err)
+			b.backoff(delay)
+			delay *= 2
+		}
+		if err = b.write(batch); err == nil {
+			return nil
+		}
+		if pw, ok := err.(*partialWrite); ok {
+			batch, err = batch[pw.Written:], pw.Err
+		}
 	}
+	return err
+}
 
-	if !sent {
-		SendMetricsToStdout(all, o.Hostname)
+// partialWrite is the error of a sink that wrote the first Written sensors
+// of the batch before failing, they aren't written again on retry.
+type partialWrite struct {
+	Written int
+	Err     error
+}
+
+func (e *partialWrite) Error() string {
+	return e.Err.Error()
+}
+
+func (b *batchedOutput) Close() error {
+	if b.close == nil {
+		return nil
 	}
-	return
+	return b.close()
+}
+
+const STDOUT_OUTPUT = "stdout"
+
+func init() {
+	RegisterOutput(STDOUT_OUTPUT, func(c *OutputsConfig) (Output, error) {
+		if !c.Stdout {
+			return nil, nil
+		}
+		return &stdoutOutput{}, nil
+	})
+}
+
+type stdoutOutput struct{}
+
+func (s *stdoutOutput) Name() string {
+	return STDOUT_OUTPUT
+}
+
+func (s *stdoutOutput) Write(all Sensors) error {
+	hn, _ := os.Hostname()
+	SendMetricsToStdout(all, hn)
+	return nil
+}
+
+func (s *stdoutOutput) Close() error {
+	return nil
 }
 
 func SendMetricsToStdout(metrics Sensors, hostname string) {
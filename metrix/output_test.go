@@ -0,0 +1,135 @@
+package metrix
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func outputSensors(n int) Sensors {
+	var all Sensors
+	for i := 0; i < n; i++ {
+		sc := NewSensor(ONE_VM_SENSOR)
+		sc.AccountId = "info@megam.io"
+		sc.AssemblyId = "ASM001"
+		sc.Region = "chennai"
+		sc.CreatedAt = time.Unix(1480000000, 0)
+		sc.addMetric(CPU_COST, "0.5", "2", "delta")
+		all = append(all, sc)
+	}
+	return all
+}
+
+func (s *S) TestBatchedOutputRetries(c *check.C) {
+	var batches []int
+	fails := 1
+	b := newBatchedOutput("test", BatchConfig{BatchSize: 2}, func(all Sensors) error {
+		if fails > 0 {
+			fails--
+			return errors.New("connection refused")
+		}
+		batches = append(batches, len(all))
+		return nil
+	}, nil)
+	var slept []time.Duration
+	b.backoff = func(d time.Duration) { slept = append(slept, d) }
+	c.Assert(b.Write(outputSensors(5)), check.IsNil)
+	c.Assert(batches, check.DeepEquals, []int{2, 2, 1})
+	c.Assert(slept, check.DeepEquals, []time.Duration{DefaultRetryBackoff})
+
+	b.write = func(Sensors) error { return errors.New("down") }
+	slept = nil
+	c.Assert(b.Write(outputSensors(1)), check.ErrorMatches, "down")
+	c.Assert(slept, check.HasLen, DefaultMaxRetries)
+	c.Assert(slept[2], check.Equals, 4*DefaultRetryBackoff)
+}
+
+func (s *S) TestBatchedOutputRetriesTheUnwritten(c *check.C) {
+	var written []int
+	fails := 1
+	b := newBatchedOutput("test", BatchConfig{BatchSize: 5}, func(all Sensors) error {
+		written = append(written, len(all))
+		if fails > 0 {
+			fails--
+			return &partialWrite{Written: 3, Err: errors.New("connection reset")}
+		}
+		return nil
+	}, nil)
+	b.backoff = func(time.Duration) {}
+	c.Assert(b.Write(outputSensors(5)), check.IsNil)
+	c.Assert(written, check.DeepEquals, []int{5, 2})
+}
+
+func (s *S) TestNewOutputHandlerDefaultsToStdout(c *check.C) {
+	o, err := NewOutputHandler(&OutputsConfig{})
+	c.Assert(err, check.IsNil)
+	c.Assert(o.Outputs, check.HasLen, 1)
+	c.Assert(o.Outputs[0].Name(), check.Equals, STDOUT_OUTPUT)
+
+	_, err = NewOutputHandler(&OutputsConfig{InfluxDB: InfluxDBConfig{Enabled: true}})
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestInfluxDBOutputWritesLines(c *check.C) {
+	var body, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, query = string(b), r.URL.RawQuery
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	o, err := NewInfluxDBOutput(InfluxDBConfig{Url: srv.URL, Database: "vertice"})
+	c.Assert(err, check.IsNil)
+	c.Assert(o.Write(outputSensors(1)), check.IsNil)
+	c.Assert(query, check.Equals, "db=vertice&precision=ns")
+	hn, _ := os.Hostname()
+	c.Assert(body, check.Equals, "vertice_sensor,account_id=info@megam.io,assembly_id=ASM001,host="+tagEscaper.Replace(hn)+
+		",metric=cpu_cost,region=chennai,sensor_type=compute.instance.exists usage=2,rate=0.5 1480000000000000000\n")
+}
+
+func (s *S) TestFileOutputRotates(c *check.C) {
+	path := filepath.Join(c.MkDir(), "metrics.json")
+	o, err := NewFileOutput(FileConfig{Path: path, MaxSize: 1, MaxBackups: 2})
+	c.Assert(err, check.IsNil)
+	defer o.Close()
+	fo := o.(*batchedOutput)
+	c.Assert(fo.Write(outputSensors(1)), check.IsNil)
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(b), "\n"), check.Equals, 1)
+
+	for i := 0; i < 3; i++ {
+		c.Assert(ioutil.WriteFile(path, make([]byte, 1024*1024), 0644), check.IsNil)
+		c.Assert(o.Close(), check.IsNil)
+		c.Assert(fo.Write(outputSensors(1)), check.IsNil)
+	}
+	_, err = os.Stat(path + ".2")
+	c.Assert(err, check.IsNil)
+	_, err = os.Stat(path + ".3")
+	c.Assert(os.IsNotExist(err), check.Equals, true)
+}
+
+func (s *S) TestFileOutputUndoTruncates(c *check.C) {
+	path := filepath.Join(c.MkDir(), "metrics.json")
+	f := &fileOutput{c: FileConfig{Path: path, MaxSize: DefaultFileMaxSize}}
+	c.Assert(f.open(), check.IsNil)
+	defer f.Close()
+	c.Assert(f.append(outputSensors(1)), check.IsNil)
+	offset := f.size
+	c.Assert(f.append(outputSensors(2)), check.IsNil)
+	err := f.undo(1, offset, errors.New("no space left on device"))
+	c.Assert(err, check.FitsTypeOf, &partialWrite{})
+	c.Assert(err.(*partialWrite).Written, check.Equals, 1)
+	c.Assert(f.size, check.Equals, offset)
+	c.Assert(f.append(outputSensors(1)), check.IsNil)
+	b, err := ioutil.ReadFile(path)
+	c.Assert(err, check.IsNil)
+	c.Assert(strings.Count(string(b), "\n"), check.Equals, 2)
+}
@@ -0,0 +1,175 @@
+package metrix
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	FLAT_PLAN   = "flat"
+	TIERED_PLAN = "tiered"
+	VOLUME_PLAN = "volume"
+)
+
+// Tier prices the units up to UpTo (0 is unbounded) at Rate per hour.
+type Tier struct {
+	UpTo float64 `json:"up_to" toml:"up_to"`
+	Rate float64 `json:"rate" toml:"rate"`
+}
+
+// RatePlan prices a metric (cpu_cost, memory_cost, disk_cost, storage_cost)
+// of a region, an empty region applies to all of them. The units are the
+// quantity consumed divided by the default unit of the region.
+//
+//	flat:   Rate per unit, the rate of the sensor when Rate is 0.
+//	tiered: each tier prices its own slice of the units.
+//	volume: all the units are priced at the rate of the tier they reach.
+//
+// FreeUnits are deducted before pricing and Minimum is the least charged
+// per hour.
+type RatePlan struct {
+	Name      string  `json:"name" toml:"name"`
+	Metric    string  `json:"metric" toml:"metric"`
+	Region    string  `json:"region" toml:"region"`
+	Type      string  `json:"type" toml:"type"`
+	Rate      float64 `json:"rate" toml:"rate"`
+	Tiers     []Tier  `json:"tier" toml:"tier"`
+	FreeUnits float64 `json:"free_units" toml:"free_units"`
+	Minimum   float64 `json:"minimum" toml:"minimum"`
+}
+
+// Hourly returns the cost per hour of units, rate is the one of the sensor.
+func (p *RatePlan) Hourly(units, rate float64) float64 {
+	if units -= p.FreeUnits; units < 0 {
+		units = 0
+	}
+	var cost float64
+	switch p.Type {
+	case TIERED_PLAN:
+		cost = p.tiered(units)
+	case VOLUME_PLAN:
+		cost = p.volume(units)
+	default:
+		if p.Rate > 0 {
+			rate = p.Rate
+		}
+		cost = units * rate
+	}
+	if cost < p.Minimum {
+		cost = p.Minimum
+	}
+	return cost
+}
+
+func (p *RatePlan) tiered(units float64) float64 {
+	var cost, from float64
+	for _, t := range p.Tiers {
+		if units <= from {
+			break
+		}
+		slice := units - from
+		if t.UpTo > 0 && units > t.UpTo {
+			slice = t.UpTo - from
+		}
+		cost += slice * t.Rate
+		from = t.UpTo
+		if t.UpTo <= 0 {
+			break
+		}
+	}
+	return cost
+}
+
+func (p *RatePlan) volume(units float64) float64 {
+	for _, t := range p.Tiers {
+		if t.UpTo <= 0 || units <= t.UpTo {
+			return units * t.Rate
+		}
+	}
+	if len(p.Tiers) > 0 {
+		return units * p.Tiers[len(p.Tiers)-1].Rate
+	}
+	return 0
+}
+
+// Pricing prices the sensors with the rate plans. The metrics without a plan
+// are priced linearly at the rate of the sensor.
+type Pricing struct {
+	mu    sync.RWMutex
+	plans []RatePlan
+}
+
+// Prices is the pricing engine of the collectors, set by metricsd.
+var Prices = NewPricing(nil)
+
+func NewPricing(plans []RatePlan) *Pricing {
+	return &Pricing{plans: plans}
+}
+
+func (p *Pricing) SetPlans(plans []RatePlan) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.plans = plans
+}
+
+// Plan returns the plan of a metric, the one of the region wins.
+func (p *Pricing) Plan(metric, region string) (*RatePlan, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var found *RatePlan
+	for i := range p.plans {
+		rp := &p.plans[i]
+		if rp.Metric != metric {
+			continue
+		}
+		if rp.Region == region && region != "" {
+			return rp, true
+		}
+		if rp.Region == "" && found == nil {
+			found = rp
+		}
+	}
+	return found, found != nil
+}
+
+// Cost returns the cost of the sensor metrics for the metrics interval.
+// units has the default units (cpu_unit, memory_unit ..) of the region. The
+// value of a metric is its cost per unit and its units the quantity consumed.
+func (p *Pricing) Cost(ms Metrics, region string, units map[string]string) float64 {
+	return p.CostFor(ms, region, units, MetricsInterval)
+}
+
+// CostFor returns the cost of the metrics consumed for the duration d.
+func (p *Pricing) CostFor(ms Metrics, region string, units map[string]string, d time.Duration) float64 {
+	var hourly float64
+	for _, m := range ms {
+		rate, _ := strconv.ParseFloat(m.MetricValue, 64)
+		consume, _ := strconv.ParseFloat(m.MetricUnits, 64)
+		qty := consume / defaultUnit(m.MetricName, units)
+		if rp, ok := p.Plan(m.MetricName, region); ok {
+			hourly += rp.Hourly(qty, rate)
+		} else {
+			hourly += qty * rate
+		}
+	}
+	return hourly * d.Hours()
+}
+
+func defaultUnit(metric string, units map[string]string) float64 {
+	var key string
+	switch metric {
+	case CPU_COST:
+		key = CPU_UNIT
+	case MEMORY_COST:
+		key = MEMORY_UNIT
+	case DISK_COST:
+		key = DISK_UNIT
+	case STORAGE_COST:
+		key = STORAGE_UNIT
+	}
+	if u, err := strconv.ParseFloat(units[key], 64); err == nil && u > 0 {
+		return u
+	}
+	return 1
+}
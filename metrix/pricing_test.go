@@ -0,0 +1,47 @@
+package metrix
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestRatePlanFlat(c *check.C) {
+	p := &RatePlan{Type: FLAT_PLAN}
+	c.Assert(p.Hourly(2, 0.5), check.Equals, 1.0)
+	p.Rate = 0.25
+	c.Assert(p.Hourly(2, 0.5), check.Equals, 0.5)
+	p.FreeUnits = 1
+	c.Assert(p.Hourly(2, 0.5), check.Equals, 0.25)
+	p.Minimum = 0.3
+	c.Assert(p.Hourly(2, 0.5), check.Equals, 0.3)
+}
+
+func (s *S) TestRatePlanTieredAndVolume(c *check.C) {
+	tiers := []Tier{{UpTo: 4, Rate: 2}, {UpTo: 8, Rate: 1}, {Rate: 0.5}}
+	p := &RatePlan{Type: TIERED_PLAN, Tiers: tiers}
+	c.Assert(p.Hourly(3, 0), check.Equals, 6.0)
+	c.Assert(p.Hourly(10, 0), check.Equals, 4*2+4*1+2*0.5)
+	p = &RatePlan{Type: VOLUME_PLAN, Tiers: tiers}
+	c.Assert(p.Hourly(3, 0), check.Equals, 6.0)
+	c.Assert(p.Hourly(6, 0), check.Equals, 6.0)
+	c.Assert(p.Hourly(10, 0), check.Equals, 5.0)
+}
+
+func (s *S) TestPricingCost(c *check.C) {
+	defer func(d time.Duration) { MetricsInterval = d }(MetricsInterval)
+	MetricsInterval = 30 * time.Minute
+	ms := Metrics{
+		&Metric{MetricName: CPU_COST, MetricValue: "0.25", MetricUnits: "2"},
+		&Metric{MetricName: MEMORY_COST, MetricValue: "0.5", MetricUnits: "2048"},
+	}
+	units := map[string]string{CPU_UNIT: "1", MEMORY_UNIT: "1024"}
+	p := NewPricing(nil)
+	c.Assert(p.Cost(ms, "chennai", units), check.Equals, 0.75)
+	p.SetPlans([]RatePlan{
+		{Metric: MEMORY_COST, Rate: 1},
+		{Metric: MEMORY_COST, Region: "chennai", FreeUnits: 2},
+	})
+	c.Assert(p.Cost(ms, "chennai", units), check.Equals, 0.25)
+	c.Assert(p.Cost(ms, "paris", units), check.Equals, 1.25)
+}
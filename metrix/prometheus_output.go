@@ -0,0 +1,69 @@
+package metrix
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/megamsys/vertice/stats"
+)
+
+// Exposed keeps the last sensors collected, exposed on the /metrics endpoint
+// of httpd.
+var Exposed = newSensorExposition()
+
+// SensorExposition exposes the metrics of the sensors per account, assembly,
+// region and sensor type. A sensor not collected again for two intervals is
+// dropped.
+type SensorExposition struct {
+	mu      sync.Mutex
+	sensors map[string]exposedSensor
+}
+
+type exposedSensor struct {
+	sensor *Sensor
+	at     time.Time
+}
+
+func newSensorExposition() *SensorExposition {
+	e := &SensorExposition{sensors: make(map[string]exposedSensor)}
+	stats.Register(e)
+	return e
+}
+
+// Write keeps the sensors, replacing the ones collected before.
+func (e *SensorExposition) Write(all Sensors) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for _, s := range all {
+		k := s.AccountId + "/" + s.AssemblyId + "/" + s.SensorType + "/" + s.Region
+		e.sensors[k] = exposedSensor{sensor: s, at: now}
+	}
+}
+
+func (e *SensorExposition) Collect() []*stats.Family {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	usage := &stats.Family{Name: "vertice_sensor_usage", Help: "Quantity consumed of a metric of a sensor during its last collection.", Type: stats.GAUGE}
+	rate := &stats.Family{Name: "vertice_sensor_rate", Help: "Cost per unit per hour of a metric of a sensor.", Type: stats.GAUGE}
+	for k, es := range e.sensors {
+		if time.Since(es.at) > 2*MetricsInterval {
+			delete(e.sensors, k)
+			continue
+		}
+		s := es.sensor
+		for _, m := range s.Metrics {
+			ls := stats.Labels(
+				[]string{"account_id", "assembly_id", "region", "sensor_type", "metric"},
+				[]string{s.AccountId, s.AssemblyId, s.Region, s.SensorType, m.MetricName})
+			if v, err := strconv.ParseFloat(m.MetricUnits, 64); err == nil {
+				usage.Samples = append(usage.Samples, stats.Sample{Labels: ls, Value: v})
+			}
+			if v, err := strconv.ParseFloat(m.MetricValue, 64); err == nil {
+				rate.Samples = append(rate.Samples, stats.Sample{Labels: ls, Value: v})
+			}
+		}
+	}
+	return []*stats.Family{usage, rate}
+}
@@ -7,33 +7,69 @@ import (
 	"github.com/megamsys/libgo/events/alerts"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
+	"strconv"
 	"time"
 
 )
 
-func SendMetricsToScylla(metrics Sensors, hostname string) (err error) {
-	started := time.Now()
-	for _, m := range metrics {
-			cl := api.NewClient(carton.NewArgs(m.AccountId, ""), "/sensors/content")
-			if _, err := cl.Post(m); err != nil {
-				log.Debugf(err.Error())
-				continue
-			}
+const SCYLLA_OUTPUT = "scylla"
+
+func init() {
+	RegisterOutput(SCYLLA_OUTPUT, func(c *OutputsConfig) (Output, error) {
+		if !c.Scylla {
+			return nil, nil
+		}
+		return newBatchedOutput(SCYLLA_OUTPUT, BatchConfig{}, postSensors, nil), nil
+	})
+}
+
+// postSensors posts the sensors to the gateway, stopping at the first that
+// fails so that the batch is retried from it.
+func postSensors(batch Sensors) error {
+	for i, m := range batch {
+		cl := api.NewClient(carton.NewArgs(m.AccountId, ""), "/sensors/content")
+		if _, err := cl.Post(m); err != nil {
+			return &partialWrite{Written: i, Err: err}
+		}
 	}
-	log.Debugf("sent %d metrics in %.06f\n", len(metrics), time.Since(started).Seconds())
 	return nil
 }
 
+// mkBalance deducts the cost of the sensor priced by the rate plans of the
+// region, once for every window of its audit period not billed yet.
+func mkBalance(s *Sensor, du map[string]string, region string) error {
+	windows, err := Billed.Claim(s)
+	if err != nil {
+		log.Errorf("  billing %s of %s skipped : %s", s.AssemblyId, s.AccountId, err)
+		return err
+	}
+	defer Billed.Release(s)
+	for _, w := range windows {
+		cost := Prices.CostFor(s.Metrics, region, du, w.Duration())
+		if err = Billed.Begin(s, w, cost); err != nil {
+			return err
+		}
+		if err = deduct(s, w, cost); err != nil {
+			log.Errorf("  billing %s of %s failed : %s", s.AssemblyId, s.AccountId, err)
+			Billed.Abort(s, w)
+			return err
+		}
+		if err = Billed.Commit(s, w, cost); err != nil {
+			return err
+		}
+	}
+	carton.Suspensions.Watch(s.AccountId, s.AssembliesId, s.AssemblyId)
+	return nil
+}
 
-func mkBalance(s *Sensor, du map[string]string) error {
+func deduct(s *Sensor, w BillingWindow, cost float64) error {
 	mi := make(map[string]string)
-	m := s.Metrics.Totalcost(du)
 	mi[constants.ACCOUNTID] = s.AccountId
 	mi[constants.ASSEMBLYID] = s.AssemblyId
 	mi[constants.ASSEMBLYNAME] = s.AssemblyName
-	mi[constants.CONSUMED] = m
-	mi[constants.START_TIME] = s.AuditPeriodBeginning
-	mi[constants.END_TIME] = s.AuditPeriodEnding
+	mi[constants.CONSUMED] = strconv.FormatFloat(cost, 'f', 6, 64)
+	mi[constants.START_TIME] = w.Begin.Format(time.RFC3339)
+	mi[constants.END_TIME] = w.End.Format(time.RFC3339)
 
 	newEvent := events.NewMulti(
 		[]*events.Event{
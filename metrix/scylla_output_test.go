@@ -10,7 +10,7 @@ func (s *S) TestStoreCollectedr(c *check.C) {
 
 	mh := &MetricHandler{}
 	on := &OpenNebula{RawStatus: s.testxml}
-	all, _ := mh.Collect(on)
+	all, _ := mh.Collect(on, nil)
 	c.Assert(all, check.NotNil)
 
 	o := OutputHandler{
@@ -24,7 +24,7 @@ func (s *S) TestStoreCollectedr(c *check.C) {
 
 func (s *S) TestBalanceEvents(c *check.C) {
 
-  err := mkBalance(s.sensor, map[string]string{"memory_unit": "1024", "cpu_unit": "1", "disk_unit": "10240"})
+  err := mkBalance(s.sensor, map[string]string{"memory_unit": "1024", "cpu_unit": "1", "disk_unit": "10240"}, "")
   fmt.Println(err)
   c.Assert(nil, check.NotNil)
 }
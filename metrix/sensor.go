@@ -45,6 +45,7 @@ type Sensor struct {
 	AuditPeriodDelta     string  `json:"audit_period_delta" cql:"audit_period_delta"`
 	Metrics              Metrics `json:"metrics" cql:"metrics"`
 	CreatedAt            time.Time  `json:"created_at" cql:"created_at"`
+	Region               string     `json:"-" cql:"-"`
 }
 
 
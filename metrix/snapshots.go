@@ -29,8 +29,11 @@ func (r *Snapshots) Prefix() string {
 }
 
 func (r *Snapshots) DeductBill(c *MetricsCollection) (e error) {
+	if c.expired() {
+		return ErrExpired
+	}
 	for _, mc := range c.Sensors {
-		mkBalance(mc, r.DefaultUnits)
+		mkBalance(mc, r.DefaultUnits, "")
 	}
 	return
 }
@@ -16,6 +16,7 @@ type CephStorage struct {
 
 type CephRGWStats struct {
 	Url          string
+	Region       string
 	AdminUser    string
 	MasterKey    string
   AccessKey    string
@@ -31,8 +32,11 @@ func (rgw *CephRGWStats) Prefix() string {
 }
 
 func (rgw *CephRGWStats) DeductBill(c *MetricsCollection) (e error) {
+	if c.expired() {
+		return ErrExpired
+	}
 	for _, mc := range c.Sensors {
-			mkBalance(mc, rgw.DefaultUnits)
+			mkBalance(mc, rgw.DefaultUnits, rgw.Region)
 	}
 	return
 }
@@ -71,6 +75,7 @@ func (c *CephRGWStats) CollectMetricsFromStats(mc *MetricsCollection, acts []car
 			sc.Node = c.Url
 			sc.AssemblyName = ""
 			sc.Source = c.Prefix()
+			sc.Region = c.Region
 			sc.Message = "storage billing"
 			sc.Status = "health-ok"
 			sc.AuditPeriodBeginning = time.Now().Add(-MetricsInterval).Format(time.RFC3339)
@@ -0,0 +1,150 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+// Package boltstore keeps the storage of the one, docker and rancher
+// clusters in a bolt file shared by the vertice processes.
+//
+// The file is opened for a transaction and closed right after it, the lock
+// bolt takes on the file serializes the transactions of all the processes:
+// an update holds it alone, the views share it. The vertice on other hosts
+// share the file through a filesystem honouring the lock, as nfs does.
+package boltstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// DefaultTimeout is how long a transaction waits for the lock of the file.
+const DefaultTimeout = 10 * time.Second
+
+// ErrLeaseLost is returned extending or releasing a lease held by another.
+var ErrLeaseLost = errors.New("lease held by another vertice")
+
+type Store struct {
+	path    string
+	timeout time.Duration
+}
+
+// Open creates the file at path and its buckets when missing.
+func Open(path string, timeout time.Duration, buckets ...[]byte) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, timeout: timeout}
+	db, err := s.open(false)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close holds nothing to release, the file is closed after every
+// transaction.
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) open(readOnly bool) (*bolt.DB, error) {
+	return bolt.Open(s.path, 0600, &bolt.Options{Timeout: s.timeout, ReadOnly: readOnly})
+}
+
+func (s *Store) Update(bucket []byte, fn func(*bolt.Bucket) error) error {
+	db, err := s.open(false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(bucket))
+	})
+}
+
+func (s *Store) View(bucket []byte, fn func(*bolt.Bucket) error) error {
+	db, err := s.open(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(bucket))
+	})
+}
+
+// Holder names this process in the leases it takes on the stored records.
+func Holder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// Get decodes the json of key into v, notFound when there is none.
+func Get(b *bolt.Bucket, key string, v interface{}, notFound error) error {
+	data := b.Get([]byte(key))
+	if data == nil {
+		return notFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Put stores v as json under key.
+func Put(b *bolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), data)
+}
+
+// Has tells if key is stored.
+func Has(b *bolt.Bucket, key string) bool {
+	return b.Get([]byte(key)) != nil
+}
+
+// Remove deletes the keys stored, notFound when there was none.
+func Remove(b *bolt.Bucket, keys []string, notFound error) error {
+	removed := 0
+	for _, key := range keys {
+		if !Has(b, key) {
+			continue
+		}
+		if err := b.Delete([]byte(key)); err != nil {
+			return err
+		}
+		removed++
+	}
+	if removed == 0 {
+		return notFound
+	}
+	return nil
+}
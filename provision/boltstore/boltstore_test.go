@@ -0,0 +1,150 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package boltstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"gopkg.in/check.v1"
+)
+
+var (
+	things     = []byte("things")
+	errNoThing = errors.New("no thing")
+)
+
+type thing struct {
+	Name string
+}
+
+// increments the counter of the file at path n times, one update each.
+func increment(path string, n int) error {
+	st, err := Open(path, 10*time.Second, things)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		err = st.Update(things, func(b *bolt.Bucket) error {
+			var count int
+			if err := Get(b, "count", &count, nil); err != nil {
+				return err
+			}
+			return Put(b, "count", count+1)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func count(c *check.C, path string) int {
+	st, err := Open(path, time.Second, things)
+	c.Assert(err, check.IsNil)
+	var count int
+	err = st.View(things, func(b *bolt.Bucket) error {
+		return Get(b, "count", &count, nil)
+	})
+	c.Assert(err, check.IsNil)
+	return count
+}
+
+func (s *S) TestStoresShareTheFile(c *check.C) {
+	path := filepath.Join(c.MkDir(), "sub", "things.db")
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- increment(path, 25)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		c.Assert(err, check.IsNil)
+	}
+	c.Assert(count(c, path), check.Equals, 100)
+}
+
+// TestHelperIncrements is the other process of TestProcessesShareTheFile.
+func (s *S) TestHelperIncrements(c *check.C) {
+	path := os.Getenv("BOLTSTORE_HELPER_PATH")
+	if path == "" {
+		c.Skip("run by TestProcessesShareTheFile")
+	}
+	c.Assert(increment(path, 50), check.IsNil)
+}
+
+func (s *S) TestProcessesShareTheFile(c *check.C) {
+	path := filepath.Join(c.MkDir(), "things.db")
+	cmd := exec.Command(os.Args[0], "-check.f", "TestHelperIncrements")
+	cmd.Env = append(os.Environ(), "BOLTSTORE_HELPER_PATH="+path)
+	out := make(chan error, 1)
+	go func() {
+		b, err := cmd.CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("%s: %s", err, b)
+		}
+		out <- err
+	}()
+	c.Assert(increment(path, 50), check.IsNil)
+	c.Assert(<-out, check.IsNil)
+	c.Assert(count(c, path), check.Equals, 100)
+}
+
+func (s *S) TestGetAndView(c *check.C) {
+	st, err := Open(filepath.Join(c.MkDir(), "things.db"), time.Second, things)
+	c.Assert(err, check.IsNil)
+	err = st.Update(things, func(b *bolt.Bucket) error {
+		return Put(b, "a", thing{Name: "a"})
+	})
+	c.Assert(err, check.IsNil)
+	err = st.View(things, func(b *bolt.Bucket) error {
+		var t thing
+		if err := Get(b, "a", &t, errNoThing); err != nil {
+			return err
+		}
+		c.Assert(t.Name, check.Equals, "a")
+		return Get(b, "b", &t, errNoThing)
+	})
+	c.Assert(err, check.Equals, errNoThing)
+}
+
+func (s *S) TestRemove(c *check.C) {
+	st, err := Open(filepath.Join(c.MkDir(), "things.db"), time.Second, things)
+	c.Assert(err, check.IsNil)
+	defer st.Close()
+	err = st.Update(things, func(b *bolt.Bucket) error {
+		Put(b, "a", thing{Name: "a"})
+		Put(b, "b", thing{Name: "b"})
+		if err := Remove(b, []string{"a", "c"}, errNoThing); err != nil {
+			return err
+		}
+		c.Assert(Has(b, "a"), check.Equals, false)
+		c.Assert(Has(b, "b"), check.Equals, true)
+		return Remove(b, []string{"a", "c"}, errNoThing)
+	})
+	c.Assert(err, check.Equals, errNoThing)
+}
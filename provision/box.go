@@ -60,8 +60,9 @@ type Boxlog struct {
 }
 
 type BoxSSH struct {
-	User   string
-	Prefix string
+	User    string
+	Prefix  string
+	HostKey string //authorized_keys line of the host key pinned on the first ssh.
 }
 
 func (bs *BoxSSH) Pub() string {
@@ -137,6 +138,7 @@ type Box struct {
 	InstanceId   string
 	Region       string
 	Vnets        map[string]string
+	Constraints  map[string]string
 	SSH          BoxSSH
 	Commit       string
 	Envs         []bind.EnvVar
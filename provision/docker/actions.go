@@ -12,6 +12,7 @@ import (
 	"github.com/megamsys/libgo/action"
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/carton"
 	lb "github.com/megamsys/vertice/logbox"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/docker/container"
@@ -130,6 +131,53 @@ var updateStatusInScylla = action.Action{
 	},
 }
 
+var checkBalances = action.Action{
+	Name: "balance-check",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		cont := ctx.Previous.(container.Container)
+		args := ctx.Params[0].(runContainerActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check balance for user (%s) container (%s)", args.box.AccountId, args.box.GetFullName())))
+		if err := cont.CheckCredits(args.box, args.provisioner.units[args.box.Region], writer); err != nil {
+			_ = cont.SetStatus(constants.StatusInsufficientFund)
+			return nil, err
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check balance for user (%s) container (%s) OK", args.box.AccountId, args.box.GetFullName())))
+		return cont, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(runContainerActionsArgs)
+		carton.Reservations.Release(args.box.AccountId, args.box.CartonId)
+	},
+}
+
+var checkQuota = action.Action{
+	Name: "quota-check",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		cont := ctx.Previous.(container.Container)
+		args := ctx.Params[0].(runContainerActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check quota (%s) for container (%s)", args.box.QuotaId, args.box.GetFullName())))
+		if err := carton.EnforceQuota(args.box, writer); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check quota (%s) for container (%s) OK", args.box.QuotaId, args.box.GetFullName())))
+		return cont, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(runContainerActionsArgs)
+		if err := carton.ReleaseQuota(args.box); err != nil {
+			log.Errorf("  release quota (%s) of box (%s) : %s", args.box.QuotaId, args.box.GetFullName(), err)
+		}
+	},
+}
+
 var createContainer = action.Action{
 	Name: "create-container",
 	Forward: func(ctx action.FWContext) (action.Result, error) {
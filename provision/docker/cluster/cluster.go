@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/fsouza/go-dockerclient"
+	"github.com/megamsys/vertice/provision/healer"
 )
 
 const (
@@ -91,13 +92,15 @@ type Storage interface {
 // provide methods for interaction with those nodes, like CreateContainer,
 // which creates a container in one node of the cluster.
 type Cluster struct {
-	Healer         Healer
-	stor           Storage
-	bridges        Bridges
-	gulp           Gulp
-	VNets          map[string]string
-	monitoringDone chan bool
-	Region         string
+	Healer    Healer
+	Scheduler Scheduler
+	stor      Storage
+	bridges   Bridges
+	gulp      Gulp
+	VNets     map[string]string
+	monitor   *healer.Monitor
+	reserved  reservations
+	Region    string
 }
 
 type DockerNodeError struct {
@@ -133,8 +136,8 @@ func wrapErrorWithCmd(n node, err error, cmd string) error {
 
 // New creates a new Cluster, initially composed by the given nodes.
 //
-// The scheduler parameter defines the scheduling strategy. It defaults
-// to round robin if nil.
+// The containers go to the nodes running the fewest, until the Scheduler
+// is set.
 // The storage parameter is the storage the cluster instance will use.
 func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	var (
@@ -148,10 +151,14 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	//	c.bridges = bridges
 	//	c.gulp = gulp
 	c.Healer = DefaultHealer{}
+	c.Scheduler, _ = GetScheduler("")
 
 	if len(nodes) > 0 {
 		for _, n := range nodes {
 			err = c.Register(n)
+			if err == ErrDuplicatedNodeAddress {
+				err = c.refresh(n)
+			}
 			if err != nil {
 				return &c, err
 			}
@@ -160,6 +167,16 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	return &c, err
 }
 
+// refresh replaces a node stored before, by an earlier run or another
+// vertice, keeping its health.
+func (c *Cluster) refresh(node Node) error {
+	stored, err := c.storage().RetrieveNode(node.Address)
+	if err != nil {
+		return err
+	}
+	return c.storage().UpdateNode(node.withHealthOf(stored))
+}
+
 // Register adds new nodes to the cluster.
 func (c *Cluster) Register(node Node) error {
 	if node.Address == "" {
@@ -261,7 +278,9 @@ func (c *Cluster) handleNodeError(addr string, lastErr error, incrementFailures
 		if duration > 0 {
 			node.updateDisabled(time.Now().Add(duration))
 		}
-		c.storage().UpdateNode(node)
+		if err = c.storage().UpdateNode(node); err == nil && duration > 0 {
+			nodeDisabled(node, duration)
+		}
 	}()
 	return nil
 }
@@ -276,8 +295,35 @@ func (c *Cluster) handleNodeSuccess(addr string) error {
 	if err != nil {
 		return err
 	}
+	disabled := node.wasDisabled()
+	recovered := node
 	node.updateSuccess()
-	return c.storage().UpdateNode(node)
+	if err = c.storage().UpdateNode(node); err == nil && disabled {
+		nodeRecovered(recovered)
+	}
+	return err
+}
+
+// handleNodeProbed enables a disabled node answering its probe.
+func (c *Cluster) handleNodeProbed(addr string) error {
+	unlock, err := c.lockWithTimeout(addr, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	node, err := c.storage().RetrieveNode(addr)
+	if err != nil {
+		return err
+	}
+	if !node.wasDisabled() {
+		return nil
+	}
+	recovered := node
+	node.updateEnabled()
+	if err = c.storage().UpdateNode(node); err == nil {
+		nodeRecovered(recovered)
+	}
+	return err
 }
 
 func (c *Cluster) storage() Storage {
@@ -10,6 +10,7 @@ import (
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/metrix"
+	"io"
 	"net"
 	"net/url"
 	"sync"
@@ -21,63 +22,130 @@ type Container struct {
 	Host string
 }
 
-// CreateContainer creates a container in the specified node. If no node is
-// specified, it will create the container in a node selected by the scheduler.
+// CreateContainerTries is the number of nodes a container is tried in.
+var CreateContainerTries = 5
+
+// CreateContainer creates a container in a node of the region of the cluster
+// selected by the scheduler.
 //
 // It returns the container, or an error, in case of failures.
 func (c *Cluster) CreateContainer(opts docker.CreateContainerOptions) (string, *docker.Container, error) {
-	return c.CreateContainerSchedulerOpts(opts)
+	return c.CreateContainerSchedulerOpts(opts, &SchedulerOptions{Region: c.Region})
 }
 
 // Similar to CreateContainer but allows arbritary options to be passed to
-// the scheduler.
-func (c *Cluster) CreateContainerSchedulerOpts(opts docker.CreateContainerOptions) (string, *docker.Container, error) {
+// the scheduler. A node failing to answer is tried again in another node of
+// the region, up to CreateContainerTries, while a container refused by the
+// node, as for a bad image, fails at once. As the create may have gone
+// through before the node stopped answering, the container is looked up by
+// its name in the nodes that failed before it is created again.
+func (c *Cluster) CreateContainerSchedulerOpts(opts docker.CreateContainerOptions, schedulerOpts *SchedulerOptions) (string, *docker.Container, error) {
 	var (
 		addr      string
 		container *docker.Container
 		err       error
+		unsure    []string
 	)
-
-	maxTries := 5
-	for ; maxTries > 0; maxTries-- {
-		nodes, err := c.Nodes()
-		for _, v := range nodes {
-			if v.Metadata[DOCKER_ZONE] == c.Region {
-				addr = v.Address
+	why := "maximum number of tries exceeded"
+	scheduler := c.Scheduler
+	if scheduler == nil {
+		scheduler, _ = GetScheduler("")
+	}
+	tried := make(map[string]bool)
+	for maxTries := CreateContainerTries; maxTries > 0; maxTries-- {
+		if len(unsure) > 0 {
+			var found *docker.Container
+			var foundAddr string
+			if foundAddr, found, unsure = c.createdContainer(unsure, opts.Name); found != nil {
+				addr, container, err = foundAddr, found, nil
+				break
 			}
 		}
-		if addr == "" {
-			return addr, nil, errors.New("CreateContainer needs a non empty node addr")
+		nodes, nodesErr := c.candidates(schedulerOpts, tried)
+		if nodesErr == nil && len(nodes) == 0 {
+			nodesErr = fmt.Errorf("CreateContainer needs a node in region %s matching %v", schedulerOpts.Region, schedulerOpts.Constraints)
 		}
+		if nodesErr != nil {
+			if err == nil {
+				return addr, nil, nodesErr
+			}
+			why = "no other node to try"
+			break
+		}
+		node, scheduleErr := scheduler.Schedule(c, nodes, opts, schedulerOpts)
+		if scheduleErr != nil {
+			return addr, nil, scheduleErr
+		}
+		addr = node.Address
 		container, err = c.createContainerInNode(opts, addr)
 		if err == nil {
 			c.handleNodeSuccess(addr)
 			break
-		} else {
-			log.Errorf("Error trying to create container in node %q: %s. Trying again in another node...", addr, err.Error())
-			shouldIncrementFailures := false
-			if nodeErr, ok := err.(DockerNodeError); ok {
-				baseErr := nodeErr.BaseError()
-				if urlErr, ok := baseErr.(*url.Error); ok {
-					baseErr = urlErr.Err
-				}
-				_, isNetErr := baseErr.(*net.OpError)
-				if isNetErr || baseErr == docker.ErrConnectionRefused || nodeErr.cmd == "createContainer" {
-					shouldIncrementFailures = true
-				}
-			}
-			c.handleNodeError(addr, err, shouldIncrementFailures)
+		}
+		if !isNodeFailure(err) {
+			log.Errorf("Error trying to create container in node %q: %s", addr, err.Error())
 			return addr, nil, err
 		}
+		log.Errorf("Error trying to create container in node %q: %s. Trying again in another node...", addr, err.Error())
+		tried[addr] = true
+		c.handleNodeError(addr, err, true)
+		if opts.Name != "" {
+			unsure = append(unsure, addr)
+		}
+	}
+	if err != nil && len(unsure) > 0 {
+		if foundAddr, found, _ := c.createdContainer(unsure, opts.Name); found != nil {
+			addr, container, err = foundAddr, found, nil
+		}
 	}
 	if err != nil {
-		return addr, nil, fmt.Errorf("CreateContainer: maximum number of tries exceeded, last error: %s", err.Error())
+		return addr, nil, fmt.Errorf("CreateContainer: %s, last error: %s", why, err.Error())
+	}
+	if err = c.storage().StoreContainer(container.ID, addr); err != nil {
+		return addr, container, err
 	}
-	err = c.storage().StoreContainer(container.ID, addr)
 	err = c.storage().StoreContainerByName(container.ID, container.Name)
 	return addr, container, err
 }
 
+// createdContainer looks up the container named name in the nodes that
+// failed to answer its create. It returns the node holding the container and
+// the container, else the nodes still unsure: the ones not answering.
+func (c *Cluster) createdContainer(unsure []string, name string) (string, *docker.Container, []string) {
+	var still []string
+	for _, addr := range unsure {
+		node, err := c.getNodeByAddr(addr)
+		if err != nil {
+			still = append(still, addr)
+			continue
+		}
+		cont, err := node.InspectContainer(name)
+		if err == nil {
+			log.Warnf("  > CreateContainer %s went through in %s as %s", name, addr, cont.ID)
+			c.handleNodeSuccess(addr)
+			return addr, cont, nil
+		}
+		if _, ok := err.(*docker.NoSuchContainer); !ok {
+			log.Warnf("  > CreateContainer %s lookup in %s : %s", name, addr, err)
+			still = append(still, addr)
+		}
+	}
+	return "", nil, still
+}
+
+// the node didn't answer, as opposed to refusing the container.
+func isNodeFailure(err error) bool {
+	baseErr := err
+	if nodeErr, ok := baseErr.(DockerNodeError); ok {
+		baseErr = nodeErr.BaseError()
+	}
+	if urlErr, ok := baseErr.(*url.Error); ok {
+		baseErr = urlErr.Err
+	}
+	_, isNetErr := baseErr.(net.Error)
+	return isNetErr || baseErr == docker.ErrConnectionRefused || baseErr == io.EOF || baseErr == io.ErrUnexpectedEOF
+}
+
 func (c *Cluster) createContainerInNode(opts docker.CreateContainerOptions, nodeAddress string) (*docker.Container, error) {
 	registryServer, _ := parseImageRegistry(opts.Config.Image)
 	if registryServer != "" {
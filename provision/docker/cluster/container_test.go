@@ -1,5 +1,17 @@
 package cluster
 
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+)
+
 /*
 func TestCreateContainer(t *testing.T) {
 	body := `{"Id":"e90302"}`
@@ -2049,3 +2061,98 @@ func TestInspectExec(t *testing.T) {
 	}
 }
 */
+
+// pickFirst schedules the containers in the first node.
+type pickFirst struct{}
+
+func (pickFirst) Schedule(c *Cluster, nodes []Node, opts docker.CreateContainerOptions, schedulerOpts *SchedulerOptions) (Node, error) {
+	return nodes[0], nil
+}
+
+func TestIsNodeFailure(t *testing.T) {
+	n := node{addr: "http://192.0.2.10:2375"}
+	netErr := &url.Error{Op: "Post", URL: n.addr, Err: &net.OpError{Op: "dial", Err: errors.New("i/o timeout")}}
+	if !isNodeFailure(wrapErrorWithCmd(n, netErr, "createContainer")) {
+		t.Error("Expected a dial error to be a node failure")
+	}
+	if !isNodeFailure(docker.ErrConnectionRefused) {
+		t.Error("Expected a refused connection to be a node failure")
+	}
+	if isNodeFailure(wrapErrorWithCmd(n, errors.New("No such image: myimg"), "createContainer")) {
+		t.Error("Expected a bad image not to be a node failure")
+	}
+}
+
+func TestCreateContainerDoesNotTryAnotherNodeForABadImage(t *testing.T) {
+	var called1, called2 int32
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&called1, 1)
+		http.Error(w, "No such image: myimg", http.StatusNotFound)
+	}))
+	defer server1.Close()
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&called2, 1)
+		w.Write([]byte(`{"Id":"e90302"}`))
+	}))
+	defer server2.Close()
+	zone := map[string]string{DOCKER_ZONE: "chennai"}
+	cluster, err := New(&MapStorage{}, Node{Address: server1.URL, Metadata: zone}, Node{Address: server2.URL, Metadata: zone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.Region = "chennai"
+	cluster.Scheduler = pickFirst{}
+	config := docker.Config{Image: "myimg"}
+	_, _, err = cluster.CreateContainer(docker.CreateContainerOptions{Name: "mybox", Config: &config})
+	if err == nil {
+		t.Fatal("Expected the bad image to fail")
+	}
+	if atomic.LoadInt32(&called1) != 1 || atomic.LoadInt32(&called2) != 0 {
+		t.Errorf("Expected a single create in server1, got %d and %d in server2", called1, called2)
+	}
+	node, err := cluster.storage().RetrieveNode(server1.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.FailureCount() != 0 {
+		t.Errorf("Expected FailureCount to be 0, got: %d", node.FailureCount())
+	}
+}
+
+func TestCreateContainerFindsTheContainerInTheFailedNode(t *testing.T) {
+	var called2 int32
+	server1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			// the container is created but the node drops the answer.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"Id":"e90302","Name":"mybox"}`))
+	}))
+	defer server1.Close()
+	server2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&called2, 1)
+		w.Write([]byte(`{"Id":"f10403"}`))
+	}))
+	defer server2.Close()
+	zone := map[string]string{DOCKER_ZONE: "chennai"}
+	cluster, err := New(&MapStorage{}, Node{Address: server1.URL, Metadata: zone}, Node{Address: server2.URL, Metadata: zone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster.Region = "chennai"
+	cluster.Scheduler = pickFirst{}
+	config := docker.Config{Image: "myimg"}
+	addr, container, err := cluster.CreateContainer(docker.CreateContainerOptions{Name: "mybox", Config: &config})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if addr != server1.URL || container.ID != "e90302" {
+		t.Errorf("Expected container e90302 in %s, got %s in %s", server1.URL, container.ID, addr)
+	}
+	if atomic.LoadInt32(&called2) != 0 {
+		t.Error("Expected no create in server2")
+	}
+}
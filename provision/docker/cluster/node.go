@@ -21,6 +21,7 @@ type Node struct {
 type HealingData struct {
 	LockedUntil time.Time
 	IsFailure   bool
+	Holder      string // the vertice leasing the node, in a shared storage.
 }
 
 type NodeList []Node
@@ -86,6 +87,28 @@ func (n *Node) FailureCount() int {
 	return failures
 }
 
+// withHealthOf returns the node with the failures and healing of stored.
+func (n Node) withHealthOf(stored Node) Node {
+	metadata := make(map[string]string)
+	for k, v := range n.Metadata {
+		metadata[k] = v
+	}
+	for _, k := range []string{"Failures", "DisabledUntil", "LastSuccess", "LastError"} {
+		if v, ok := stored.Metadata[k]; ok {
+			metadata[k] = v
+		}
+	}
+	n.Metadata = metadata
+	n.Healing = stored.Healing
+	return n
+}
+
+// wasDisabled tells if the healer disabled the node since its last success.
+func (n *Node) wasDisabled() bool {
+	_, isDisabled := n.Metadata["DisabledUntil"]
+	return isDisabled
+}
+
 func (n *Node) ResetFailures() {
 	if n.Metadata == nil {
 		n.Metadata = make(map[string]string)
@@ -127,6 +150,12 @@ func (n *Node) updateSuccess() {
 	n.Metadata["LastSuccess"] = time.Now().Format(time.RFC3339)
 }
 
+// updateEnabled enables a disabled node answering its probe, its failures
+// are kept till an operation succeeds on it.
+func (n *Node) updateEnabled() {
+	delete(n.Metadata, "DisabledUntil")
+}
+
 func (n *Node) CleanMetadata() map[string]string {
 	paramsCopy := make(map[string]string)
 	for k, v := range n.Metadata {
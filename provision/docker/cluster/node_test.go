@@ -262,3 +262,32 @@ func TestNodeClient(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestClusterHandleNodeProbedKeepsTheFailures(t *testing.T) {
+	c, err := New(&MapStorage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	disabledUntil := time.Now().Add(time.Hour).Format(time.RFC3339)
+	err = c.Register(Node{
+		Address:  "addr-1",
+		Metadata: map[string]string{"Failures": "3", "DisabledUntil": disabledUntil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.handleNodeProbed("addr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := c.storage().RetrieveNode("addr-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.isEnabled() {
+		t.Error("Expected the probed node to be enabled")
+	}
+	if node.FailureCount() != 3 {
+		t.Errorf("Expected FailureCount to be 3, got: %d", node.FailureCount())
+	}
+}
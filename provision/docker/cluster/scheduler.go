@@ -0,0 +1,245 @@
+// Copyright 2014 docker-cluster authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cluster provides types and functions for management of Docker
+// clusters, scheduling container operations among hosts running Docker
+// (nodes).
+
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsouza/go-dockerclient"
+)
+
+const (
+	MEMORY_SCHEDULER     = "memory"
+	CPU_SCHEDULER        = "cpu"
+	CONTAINERS_SCHEDULER = "containers"
+
+	// LABEL_PREFIX prefixes the labels of a node in its metadata, they are
+	// matched against the constraints of the containers.
+	LABEL_PREFIX = "label."
+)
+
+// SchedulerOptions are the placement needs of a container.
+type SchedulerOptions struct {
+	Region      string
+	Constraints map[string]string
+}
+
+// Scheduler picks the node of a container among the candidates, the enabled
+// nodes of its region matching its constraints.
+type Scheduler interface {
+	Schedule(c *Cluster, nodes []Node, opts docker.CreateContainerOptions, schedulerOpts *SchedulerOptions) (Node, error)
+}
+
+var schedulers = map[string]Scheduler{
+	MEMORY_SCHEDULER:     &usageScheduler{of: memory, reservations: true},
+	CPU_SCHEDULER:        &usageScheduler{of: cpuShares, reservations: true},
+	CONTAINERS_SCHEDULER: &usageScheduler{of: containers},
+}
+
+// GetScheduler returns the scheduler named, the one placing the containers
+// on the node running the fewest when name is empty.
+func GetScheduler(name string) (Scheduler, error) {
+	if name == "" {
+		name = CONTAINERS_SCHEDULER
+	}
+	s, ok := schedulers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler %q", name)
+	}
+	return s, nil
+}
+
+// nodeUsage is what the running containers of a node reserved, against
+// what docker info reports the node has.
+type nodeUsage struct {
+	containers int64
+	memory     int64
+	memTotal   int64
+	cpuShares  int64
+	cpuTotal   int64
+}
+
+func containers(u nodeUsage) float64 { return float64(u.containers) }
+func memory(u nodeUsage) float64     { return float64(u.memory) / float64(u.memTotal) }
+func cpuShares(u nodeUsage) float64  { return float64(u.cpuShares) / float64(u.cpuTotal) }
+
+// sharesPerCpu are the cpu shares a container reserving a whole cpu has.
+const sharesPerCpu = 1024
+
+// usageScheduler picks the node with the least of a usage, a node whose
+// usage is unknown last. The memory and cpu are ranked by the fraction
+// reserved, so a bigger node takes more.
+type usageScheduler struct {
+	of           func(nodeUsage) float64
+	reservations bool
+}
+
+func (s *usageScheduler) Schedule(c *Cluster, nodes []Node, opts docker.CreateContainerOptions, schedulerOpts *SchedulerOptions) (Node, error) {
+	if len(nodes) == 0 {
+		return Node{}, fmt.Errorf("no docker node in region %s", schedulerOpts.Region)
+	}
+	ranked := make([]rankedNode, len(nodes))
+	var wg sync.WaitGroup
+	for i := range nodes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ranked[i].Node = nodes[i]
+			usage, err := c.nodeUsage(nodes[i].Address, s.reservations)
+			if err != nil {
+				log.Warnf("  > scheduler: usage of node %s unknown : %s", nodes[i].Address, err)
+				ranked[i].unknown = true
+				return
+			}
+			ranked[i].usage = s.of(usage)
+		}(i)
+	}
+	wg.Wait()
+	sort.Sort(byUsage(ranked))
+	return ranked[0].Node, nil
+}
+
+type rankedNode struct {
+	Node
+	usage   float64
+	unknown bool
+}
+
+type byUsage []rankedNode
+
+func (a byUsage) Len() int      { return len(a) }
+func (a byUsage) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byUsage) Less(i, j int) bool {
+	if a[i].unknown != a[j].unknown {
+		return a[j].unknown
+	}
+	if a[i].usage != a[j].usage {
+		return a[i].usage < a[j].usage
+	}
+	return a[i].Address < a[j].Address
+}
+
+// reservation is what a container reserved, it doesn't change while the
+// container lives.
+type reservation struct {
+	memory    int64
+	cpuShares int64
+}
+
+// reservations keeps the reservations of the containers inspected, by node,
+// so a container is inspected once.
+type reservations struct {
+	mu    sync.Mutex
+	nodes map[string]map[string]reservation
+}
+
+func (r *reservations) get(address, id string) (reservation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.nodes[address][id]
+	return res, ok
+}
+
+// keep replaces the reservations of a node by the ones of its running
+// containers, forgetting the gone.
+func (r *reservations) keep(address string, running map[string]reservation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.nodes == nil {
+		r.nodes = make(map[string]map[string]reservation)
+	}
+	r.nodes[address] = running
+}
+
+// nodeUsage sums the reservations of the running containers of a node, when
+// asked for, against the memory and cpus of the node. Only the containers
+// not seen before are inspected.
+func (c *Cluster) nodeUsage(address string, reserved bool) (nodeUsage, error) {
+	var usage nodeUsage
+	n, err := c.getNodeByAddr(address)
+	if err != nil {
+		return usage, err
+	}
+	running, err := n.ListContainers(docker.ListContainersOptions{})
+	if err != nil {
+		return usage, wrapError(n, err)
+	}
+	usage.containers = int64(len(running))
+	if !reserved {
+		return usage, nil
+	}
+	info, err := n.Info()
+	if err != nil {
+		return usage, wrapError(n, err)
+	}
+	if info.MemTotal <= 0 || info.NCPU <= 0 {
+		return usage, fmt.Errorf("node %s reports no memory or cpus", address)
+	}
+	usage.memTotal = info.MemTotal
+	usage.cpuTotal = int64(info.NCPU) * sharesPerCpu
+	kept := make(map[string]reservation, len(running))
+	for _, r := range running {
+		res, ok := c.reserved.get(address, r.ID)
+		if !ok {
+			cont, err := n.InspectContainer(r.ID)
+			if err != nil {
+				return usage, wrapError(n, err)
+			}
+			if cont.Config != nil {
+				res = reservation{memory: cont.Config.Memory, cpuShares: cont.Config.CPUShares}
+			}
+		}
+		kept[r.ID] = res
+		usage.memory += res.memory
+		usage.cpuShares += res.cpuShares
+	}
+	c.reserved.keep(address, kept)
+	return usage, nil
+}
+
+// candidates returns the enabled nodes of the region matching the
+// constraints and not tried yet, all of them when none is enabled.
+func (c *Cluster) candidates(so *SchedulerOptions, tried map[string]bool) ([]Node, error) {
+	nodes, err := c.Nodes()
+	if err != nil {
+		return nil, err
+	}
+	if res := matching(nodes, so, tried); len(res) > 0 {
+		return res, nil
+	}
+	// all disabled, the nodes of the region are tried anyway.
+	if nodes, err = c.UnfilteredNodes(); err != nil {
+		return nil, err
+	}
+	return matching(nodes, so, tried), nil
+}
+
+func matching(nodes []Node, so *SchedulerOptions, tried map[string]bool) []Node {
+	var res []Node
+	for _, n := range nodes {
+		if n.Metadata[DOCKER_ZONE] == so.Region && !tried[n.Address] && n.satisfies(so.Constraints) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+// satisfies tells if the labels of the node match the constraints.
+func (n *Node) satisfies(constraints map[string]string) bool {
+	for k, v := range constraints {
+		if !strings.EqualFold(n.Metadata[LABEL_PREFIX+k], v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package cluster
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestByUsage(t *testing.T) {
+	ranked := []rankedNode{
+		{Node: Node{Address: "http://d:2375"}, unknown: true},
+		{Node: Node{Address: "http://c:2375"}, usage: 0.5},
+		{Node: Node{Address: "http://b:2375"}, usage: 0.25},
+		{Node: Node{Address: "http://a:2375"}, usage: 0.5},
+	}
+	sort.Sort(byUsage(ranked))
+	expected := []string{"http://b:2375", "http://a:2375", "http://c:2375", "http://d:2375"}
+	for i, r := range ranked {
+		if r.Address != expected[i] {
+			t.Errorf("node %d: expected %s, got %s", i, expected[i], r.Address)
+		}
+	}
+}
+
+func TestUsageFractions(t *testing.T) {
+	gb := int64(1 << 30)
+	small := nodeUsage{containers: 1, memory: gb, memTotal: 2 * gb, cpuShares: 512, cpuTotal: 2 * sharesPerCpu}
+	big := nodeUsage{containers: 4, memory: 2 * gb, memTotal: 8 * gb, cpuShares: 2048, cpuTotal: 16 * sharesPerCpu}
+	if memory(small) != 0.5 || memory(big) != 0.25 {
+		t.Errorf("expected the memory fractions 0.5 and 0.25, got %v and %v", memory(small), memory(big))
+	}
+	if cpuShares(small) != 0.25 || cpuShares(big) != 0.125 {
+		t.Errorf("expected the cpu fractions 0.25 and 0.125, got %v and %v", cpuShares(small), cpuShares(big))
+	}
+	if containers(small) != 1 || containers(big) != 4 {
+		t.Errorf("expected 1 and 4 containers, got %v and %v", containers(small), containers(big))
+	}
+}
+
+func TestReservationsKeepTheRunning(t *testing.T) {
+	var r reservations
+	if _, ok := r.get("http://a:2375", "c1"); ok {
+		t.Fatal("expected no reservation before any was kept")
+	}
+	r.keep("http://a:2375", map[string]reservation{"c1": {memory: 512, cpuShares: 256}, "c2": {}})
+	r.keep("http://a:2375", map[string]reservation{"c1": {memory: 512, cpuShares: 256}})
+	if res, ok := r.get("http://a:2375", "c1"); !ok || res.memory != 512 || res.cpuShares != 256 {
+		t.Errorf("expected the reservation of c1, got %+v", res)
+	}
+	if _, ok := r.get("http://a:2375", "c2"); ok {
+		t.Error("expected the reservation of the gone c2 forgotten")
+	}
+	if _, ok := r.get("http://b:2375", "c1"); ok {
+		t.Error("expected the reservations kept by node")
+	}
+}
+
+func TestMatching(t *testing.T) {
+	nodes := []Node{
+		{Address: "http://a:2375", Metadata: map[string]string{DOCKER_ZONE: "chennai", LABEL_PREFIX + "disk": "ssd"}},
+		{Address: "http://b:2375", Metadata: map[string]string{DOCKER_ZONE: "chennai", LABEL_PREFIX + "disk": "hdd"}},
+		{Address: "http://c:2375", Metadata: map[string]string{DOCKER_ZONE: "paris", LABEL_PREFIX + "disk": "ssd"}},
+		{Address: "http://d:2375", Metadata: map[string]string{DOCKER_ZONE: "chennai", LABEL_PREFIX + "disk": "SSD"}},
+	}
+	so := &SchedulerOptions{Region: "chennai", Constraints: map[string]string{"disk": "ssd"}}
+	res := matching(nodes, so, map[string]bool{})
+	if len(res) != 2 || res[0].Address != "http://a:2375" || res[1].Address != "http://d:2375" {
+		t.Errorf("expected the ssd nodes of chennai, got %+v", res)
+	}
+	res = matching(nodes, so, map[string]bool{"http://a:2375": true})
+	if len(res) != 1 || res[0].Address != "http://d:2375" {
+		t.Errorf("expected the ssd node of chennai not tried, got %+v", res)
+	}
+	res = matching(nodes, &SchedulerOptions{Region: "chennai"}, nil)
+	if len(res) != 3 {
+		t.Errorf("expected the 3 nodes of chennai, got %+v", res)
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	n := Node{Metadata: map[string]string{LABEL_PREFIX + "disk": "ssd", LABEL_PREFIX + "gpu": "true", "disk": "hdd"}}
+	var tests = []struct {
+		constraints map[string]string
+		expected    bool
+	}{
+		{nil, true},
+		{map[string]string{"disk": "ssd"}, true},
+		{map[string]string{"disk": "SSD", "gpu": "true"}, true},
+		{map[string]string{"disk": "hdd"}, false},
+		{map[string]string{"rack": "r1"}, false},
+	}
+	for _, tt := range tests {
+		if got := n.satisfies(tt.constraints); got != tt.expected {
+			t.Errorf("satisfies(%v): expected %v, got %v", tt.constraints, tt.expected, got)
+		}
+	}
+}
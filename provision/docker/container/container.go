@@ -12,10 +12,9 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/megamsys/libgo/utils"
-	"github.com/megamsys/libgo/events"
-	"github.com/megamsys/libgo/events/alerts"
 	constants "github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/docker/cluster"
 )
@@ -103,7 +102,7 @@ func (c *Container) Create(args *CreateArgs) error {
 	cl := args.Provisioner.Cluster()
 	cl.Region = args.Box.Region
 	cl.VNets = args.Box.Vnets
-	addr, cont, err := cl.CreateContainerSchedulerOpts(opts)
+	addr, cont, err := cl.CreateContainerSchedulerOpts(opts, &cluster.SchedulerOptions{Region: args.Box.Region, Constraints: args.Box.Constraints})
 	if err != nil {
 		log.Errorf("Error on creating container in docker %s - %s", c.BoxName, err)
 		return err
@@ -334,34 +333,28 @@ func (c *Container) SetStatus(status utils.Status) error {
 	return nil
 }
 
-//trigger multi event in the order
-func (c *Container) Deduct() error {
-	mi := make(map[string]string)
-	mi[constants.ACCOUNTID] = c.AccountId
-	mi[constants.ASSEMBLYID] = c.CartonId
-	mi[constants.ASSEMBLYNAME] = c.Name
-	mi[constants.CONSUMED] = "0.1"
-	mi[constants.START_TIME] = time.Now().String()
-	mi[constants.END_TIME] = time.Now().String()
-
-	newEvent := events.NewMulti(
-		[]*events.Event{
-			&events.Event{
-				AccountsId:  c.AccountId,
-				EventAction: alerts.DEDUCT,
-				EventType:   constants.EventBill,
-				EventData:   alerts.EventData{M: mi},
-				Timestamp:   time.Now().Local(),
-			},
-			&events.Event{
-				AccountsId:  c.AccountId,
-				EventAction: alerts.BILLEDHISTORY, //Change type to transaction
-				EventType:   constants.EventBill,
-				EventData:   alerts.EventData{M: mi},
-				Timestamp:   time.Now().Local(),
-			},
-		})
-	return newEvent.Write()
+// CheckCredits reserves the estimated cost of the box for a billing period
+// against the credit balance of the user. units has the default units of the
+// region and its costs per hour, used when the assembly has none.
+func (c *Container) CheckCredits(b *provision.Box, units map[string]string, w io.Writer) error {
+	asm, err := carton.NewAssembly(c.CartonId, c.AccountId, "")
+	if err != nil {
+		return err
+	}
+	costs := metrix.ContainerCosts(asm, units)
+	estimate := metrix.EstimateCost(b, costs.Cpu, costs.Memory, "", units)
+	return carton.ReserveCredits(b, estimate, w)
+}
+
+// Deduct bills the compute of the box for the time elapsed since the
+// assembly was billed last. units has the default units of the region and
+// its costs per hour, used when the assembly has none.
+func (c *Container) Deduct(b *provision.Box, units map[string]string) error {
+	asm, err := carton.NewAssembly(c.CartonId, c.AccountId, "")
+	if err != nil {
+		return err
+	}
+	return metrix.Deduct(asm, c.Name, b, units, metrix.ContainerCosts(asm, units))
 }
 
 type NetworkInfo struct {
@@ -7,16 +7,22 @@ import (
 	"io"
 	"io/ioutil"
 	"net/url"
+	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/megamsys/libgo/action"
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/libgo/events"
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
+	"github.com/megamsys/vertice/carton"
 	lb "github.com/megamsys/vertice/logbox"
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/docker/cluster"
 	"github.com/megamsys/vertice/provision/docker/container"
@@ -37,24 +43,33 @@ type dockerProvisioner struct {
 	cluster        *cluster.Cluster
 	collectionName string
 	storage        cluster.Storage
+	units          map[string]map[string]string
 }
 type Docker struct {
-	Enabled bool     `json:"enabled" toml:"enabled"`
-	Regions []Region `json:"region" toml:"region"`
+	Enabled            bool          `json:"enabled" toml:"enabled"`
+	Regions            []Region      `json:"region" toml:"region"`
+	HealerThreshold    int           `json:"healer_threshold" toml:"healer_threshold"`
+	HealerBackoff      toml.Duration `json:"healer_backoff" toml:"healer_backoff"`
+	HealerMaxBackoff   toml.Duration `json:"healer_max_backoff" toml:"healer_max_backoff"`
+	HealerProbe        toml.Duration `json:"healer_probe" toml:"healer_probe"`
+	ClusterStorage     string        `json:"cluster_storage" toml:"cluster_storage"`
+	ClusterStoragePath string        `json:"cluster_storage_path" toml:"cluster_storage_path"`
+	Scheduler          string        `json:"scheduler" toml:"scheduler"`
 }
 
 type Region struct {
-	DockerZone     string        `json:"docker_zone" toml:"docker_zone"`
-	SwarmEndPoint  string        `json:"swarm" toml:"swarm"`
-	DockerGulpPort string        `json:"gulp_port" toml:"gulp_port"`
-	Registry       string        `json:"registry" toml:"registry"`
-	CPUPeriod      toml.Duration `json:"cpu_period" toml:"cpu_period"`
-	CPUQuota       toml.Duration `json:"cpu_quota" toml:"cpu_quota"`
+	DockerZone     string            `json:"docker_zone" toml:"docker_zone"`
+	SwarmEndPoint  string            `json:"swarm" toml:"swarm"`
+	DockerGulpPort string            `json:"gulp_port" toml:"gulp_port"`
+	Registry       string            `json:"registry" toml:"registry"`
+	CPUPeriod      toml.Duration     `json:"cpu_period" toml:"cpu_period"`
+	CPUQuota       toml.Duration     `json:"cpu_quota" toml:"cpu_quota"`
 	CpuCostPerHour string 			 `json:"cpu_cost_per_hour" toml:"cpu_cost_per_hour"`
 	RamCostPerHour string 			 `json:"ram_cost_per_hour" toml:"ram_cost_per_hour"`
-	CpuUnit        string        `json:"cpu_unit" toml:"cpu_unit"`
-	MemoryUnit     string        `json:"memory_unit" toml:"memory_unit"`
-	DiskUnit       string        `json:"disk_unit" toml:"disk_unit"`
+	CpuUnit        string            `json:"cpu_unit" toml:"cpu_unit"`
+	MemoryUnit     string            `json:"memory_unit" toml:"memory_unit"`
+	DiskUnit       string            `json:"disk_unit" toml:"disk_unit"`
+	Labels         map[string]string `json:"labels" toml:"labels"`
 }
 
 func (p *dockerProvisioner) Cluster() *cluster.Cluster {
@@ -78,14 +93,17 @@ func (p *dockerProvisioner) Initialize(m interface{}) error {
 func (p *dockerProvisioner) initDockerCluster(i interface{}) error {
 	var err error
 	if p.storage == nil {
-		p.storage, err = buildClusterStorage()
+		w, _ := i.(Docker)
+		p.storage, err = buildClusterStorage(w)
 		if err != nil {
 			return err
 		}
 	}
 	if w, ok := i.(Docker); ok {
 		var nodes []cluster.Node
+		p.units = make(map[string]map[string]string)
 		for i := 0; i < len(w.Regions); i++ {
+			p.units[w.Regions[i].DockerZone] = w.Regions[i].toUnits()
 			m := w.Regions[i].toMap()
 			n := cluster.Node{
 				Address:  m[cluster.DOCKER_SWARM], //swarm endpoint
@@ -99,6 +117,11 @@ func (p *dockerProvisioner) initDockerCluster(i interface{}) error {
 		if err != nil {
 			return err
 		}
+		p.cluster.Healer = cluster.NewExponentialHealer(w.HealerThreshold, time.Duration(w.HealerBackoff), time.Duration(w.HealerMaxBackoff))
+		p.cluster.StartActiveMonitoring(time.Duration(w.HealerProbe))
+		if p.cluster.Scheduler, err = cluster.GetScheduler(w.Scheduler); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -113,11 +136,37 @@ func (c Region) toMap() map[string]string {
 	m[cluster.DOCKER_REGISTRY] = c.Registry
 	m[cluster.DOCKER_CPUPERIOD] = c.CPUPeriod.String()
 	m[cluster.DOCKER_CPUQUOTA] = c.CPUQuota.String()
+	for k, v := range c.Labels {
+		m[cluster.LABEL_PREFIX+k] = v
+	}
 	return m
 }
 
-func buildClusterStorage() (cluster.Storage, error) {
-	return &cluster.MapStorage{}, nil
+//the default units and costs of the region to bill the containers.
+func (c Region) toUnits() map[string]string {
+	return map[string]string{
+		metrix.CPU_UNIT:          c.CpuUnit,
+		metrix.MEMORY_UNIT:       c.MemoryUnit,
+		metrix.DISK_UNIT:         c.DiskUnit,
+		metrix.CPU_COST_PER_HOUR: c.CpuCostPerHour,
+		metrix.RAM_COST_PER_HOUR: c.RamCostPerHour,
+	}
+}
+
+// buildClusterStorage returns the storage of the nodes, in memory unless a
+// bolt file, shared by the vertice opening it, is configured.
+func buildClusterStorage(w Docker) (cluster.Storage, error) {
+	switch w.ClusterStorage {
+	case "", cluster.MEMORY_STORAGE:
+		return &cluster.MapStorage{}, nil
+	case cluster.BOLT_STORAGE:
+		path := w.ClusterStoragePath
+		if path == "" {
+			path = filepath.Join(meta.MC.Dir, "docker_cluster.db")
+		}
+		return cluster.NewBoltStorage(path)
+	}
+	return nil, fmt.Errorf("unknown cluster storage %q", w.ClusterStorage)
 }
 
 func getRouterForBox(box *provision.Box) (router.Router, error) {
@@ -179,6 +228,11 @@ func (p *dockerProvisioner) deployPipeline(box *provision.Box, imageId string, w
 		&MileStoneUpdate,
 		&updateStatusInScylla,
 	}
+	if len(box.QuotaId) > 0 {
+		actions = append(actions[:1], append([]*action.Action{&checkQuota}, actions[1:]...)...)
+	} else if events.IsEnabled(constants.BILLMGR) {
+		actions = append(actions[:1], append([]*action.Action{&checkBalances}, actions[1:]...)...)
+	}
 
 	pipeline := action.NewPipeline(actions...)
 
@@ -225,6 +279,10 @@ func (p *dockerProvisioner) Destroy(box *provision.Box, w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	carton.Reservations.Release(box.AccountId, box.CartonId)
+	if err := carton.ReleaseQuota(box); err != nil {
+		log.Errorf("  release quota (%s) of box (%s) : %s", box.QuotaId, box.GetFullName(), err)
+	}
 	return nil
 }
 
@@ -420,15 +478,12 @@ func (p *dockerProvisioner) DetachDisk(box *provision.Box, w io.Writer) error {
 	return nil
 }
 
-func (p *dockerProvisioner) TriggerBills(account_id, cat_id, name string) error {
+func (p *dockerProvisioner) TriggerBills(box *provision.Box) error {
 	cont := &container.Container{
-		Name:      name,
-		CartonId:  cat_id,
-		AccountId: account_id,
+		Name:      box.GetFullName(),
+		Region:    box.Region,
+		CartonId:  box.CartonId,
+		AccountId: box.AccountId,
 	}
-	err := cont.Deduct()
-	if err != nil {
-		return err
-	}
-	return nil
+	return cont.Deduct(box, p.units[box.Region])
 }
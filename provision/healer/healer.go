@@ -0,0 +1,194 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+// Package healer disables the failing nodes of the one, docker and rancher
+// clusters and probes them back.
+package healer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultThreshold is the failures disabling a node.
+	DefaultThreshold = 1
+
+	// DefaultBackoff is how long a node is first disabled.
+	DefaultBackoff = 1 * time.Minute
+
+	// DefaultMaxBackoff caps how long a node is disabled.
+	DefaultMaxBackoff = 1 * time.Hour
+
+	// DefaultProbeInterval is how often the disabled nodes are probed.
+	DefaultProbeInterval = 30 * time.Second
+
+	NODE_DISABLED  = "node_disabled"
+	NODE_RECOVERED = "node_recovered"
+)
+
+// Exponential disables a node once it failed Threshold times in a row,
+// for Backoff doubled on every failure after, up to MaxBackoff.
+type Exponential struct {
+	Threshold  int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewExponential returns a healer, the defaults replacing the zero values.
+func NewExponential(threshold int, backoff, maxBackoff time.Duration) Exponential {
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	if maxBackoff < backoff {
+		maxBackoff = backoff
+	}
+	return Exponential{Threshold: threshold, Backoff: backoff, MaxBackoff: maxBackoff}
+}
+
+// Disable returns how long a node failing that many times is disabled,
+// zero when it stays enabled.
+func (h Exponential) Disable(failures int) time.Duration {
+	if failures < h.Threshold {
+		return 0
+	}
+	d := h.Backoff
+	for i := h.Threshold; i < failures && d < h.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > h.MaxBackoff {
+		d = h.MaxBackoff
+	}
+	return d
+}
+
+// Monitor runs a probe every interval till stopped.
+type Monitor struct {
+	interval time.Duration
+	probe    func()
+	done     chan bool
+}
+
+// StartMonitor runs the probe now and every interval after.
+func StartMonitor(interval time.Duration, probe func()) *Monitor {
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	m := &Monitor{interval: interval, probe: probe, done: make(chan bool)}
+	go m.run()
+	return m
+}
+
+func (m *Monitor) Stop() {
+	m.done <- true
+}
+
+func (m *Monitor) run() {
+	for {
+		m.probe()
+		select {
+		case <-m.done:
+			return
+		case <-time.After(m.interval):
+		}
+	}
+}
+
+// Probe runs the probes together, calling up with the key of every one
+// answering.
+func Probe(probes map[string]func() error, up func(key string)) {
+	var wg sync.WaitGroup
+	for key, probe := range probes {
+		wg.Add(1)
+		go func(key string, probe func() error) {
+			defer wg.Done()
+			if err := probe(); err != nil {
+				log.Debugf("  > [healer] node %s still down: %s", key, err)
+				return
+			}
+			up(key)
+		}(key, probe)
+	}
+	wg.Wait()
+}
+
+// Notifier tells of the nodes disabled and recovered. The events of libgo
+// have no node actions, so they go through a notifier of vertice instead.
+type Notifier interface {
+	Notify(action string, mi map[string]string) error
+}
+
+// LogNotifier logs the node events, it notifies till another is set.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(action string, mi map[string]string) error {
+	log.Warnf("  > [healer] %s %s", action, Describe(mi))
+	return nil
+}
+
+var (
+	notifierMu sync.RWMutex
+	notifier   Notifier = LogNotifier{}
+)
+
+// SetNotifier sets the notifier of the node events.
+func SetNotifier(n Notifier) {
+	notifierMu.Lock()
+	defer notifierMu.Unlock()
+	notifier = n
+}
+
+// Describe returns the data of a node event as sorted key=value pairs.
+func Describe(mi map[string]string) string {
+	keys := make([]string, 0, len(mi))
+	for k := range mi {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+mi[k])
+	}
+	return strings.Join(pairs, " ")
+}
+
+// NodeDisabled notifies a node disabled for the duration.
+func NodeDisabled(mi map[string]string, duration time.Duration) error {
+	mi["disabled_until"] = time.Now().Add(duration).Format(time.RFC3339)
+	return notify(NODE_DISABLED, mi)
+}
+
+// NodeRecovered notifies a disabled node answering again.
+func NodeRecovered(mi map[string]string) error {
+	return notify(NODE_RECOVERED, mi)
+}
+
+func notify(action string, mi map[string]string) error {
+	notifierMu.RLock()
+	n := notifier
+	notifierMu.RUnlock()
+	return n.Notify(action, mi)
+}
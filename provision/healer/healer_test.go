@@ -0,0 +1,105 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+package healer
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestNewExponentialDefaults(c *check.C) {
+	h := NewExponential(0, 0, 0)
+	c.Assert(h, check.DeepEquals, Exponential{Threshold: DefaultThreshold, Backoff: DefaultBackoff, MaxBackoff: DefaultMaxBackoff})
+	h = NewExponential(2, time.Hour, time.Minute)
+	c.Assert(h.MaxBackoff, check.Equals, time.Hour)
+}
+
+func (s *S) TestExponentialDisable(c *check.C) {
+	h := NewExponential(2, time.Minute, 5*time.Minute)
+	c.Assert(h.Disable(0), check.Equals, time.Duration(0))
+	c.Assert(h.Disable(1), check.Equals, time.Duration(0))
+	c.Assert(h.Disable(2), check.Equals, time.Minute)
+	c.Assert(h.Disable(3), check.Equals, 2*time.Minute)
+	c.Assert(h.Disable(4), check.Equals, 4*time.Minute)
+	c.Assert(h.Disable(5), check.Equals, 5*time.Minute)
+	c.Assert(h.Disable(100), check.Equals, 5*time.Minute)
+}
+
+func (s *S) TestProbeCallsUpForTheAnswering(c *check.C) {
+	var (
+		mu sync.Mutex
+		up []string
+	)
+	probes := map[string]func() error{
+		"a": func() error { return nil },
+		"b": func() error { return errors.New("down") },
+		"c": func() error { return nil },
+	}
+	Probe(probes, func(key string) {
+		mu.Lock()
+		defer mu.Unlock()
+		up = append(up, key)
+	})
+	sort.Strings(up)
+	c.Assert(up, check.DeepEquals, []string{"a", "c"})
+}
+
+func (s *S) TestMonitorProbesTillStopped(c *check.C) {
+	probed := make(chan bool, 10)
+	m := StartMonitor(time.Millisecond, func() {
+		select {
+		case probed <- true:
+		default:
+		}
+	})
+	<-probed
+	<-probed
+	m.Stop()
+	for len(probed) > 0 {
+		<-probed
+	}
+	time.Sleep(5 * time.Millisecond)
+	c.Assert(probed, check.HasLen, 0)
+}
+
+type fakeNotifier struct {
+	actions []string
+	data    []map[string]string
+}
+
+func (f *fakeNotifier) Notify(action string, mi map[string]string) error {
+	f.actions = append(f.actions, action)
+	f.data = append(f.data, mi)
+	return nil
+}
+
+func (s *S) TestNodeEventsGoToTheNotifier(c *check.C) {
+	f := &fakeNotifier{}
+	SetNotifier(f)
+	defer SetNotifier(LogNotifier{})
+	c.Assert(NodeDisabled(map[string]string{"address": "http://one:2633/RPC2"}, time.Minute), check.IsNil)
+	c.Assert(NodeRecovered(map[string]string{"address": "http://one:2633/RPC2"}), check.IsNil)
+	c.Assert(f.actions, check.DeepEquals, []string{NODE_DISABLED, NODE_RECOVERED})
+	c.Assert(f.data[0]["disabled_until"], check.Not(check.Equals), "")
+}
+
+func (s *S) TestDescribeSortsTheData(c *check.C) {
+	c.Assert(Describe(map[string]string{"region": "chennai", "address": "http://one"}), check.Equals, "address=http://one region=chennai")
+}
@@ -121,7 +121,7 @@ var checkBalances = action.Action{
 			writer = ioutil.Discard
 		}
 		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check balance for user (%s) machine (%s)", args.box.AccountId, args.box.GetFullName())))
-		err := mach.CheckCredits(args.box, writer)
+		err := mach.CheckCredits(args.box, args.provisioner.units[args.box.Region], writer)
 		if err != nil {
 			_ = mach.SetMileStone(constants.StateMachineParked)
 			_ = mach.SetStatus(constants.StatusInsufficientFund)
@@ -132,6 +132,34 @@ var checkBalances = action.Action{
 		return mach, nil
 	},
 	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(runMachineActionsArgs)
+		carton.Reservations.Release(args.box.AccountId, args.box.CartonId)
+	},
+}
+
+var checkQuota = action.Action{
+	Name: "quota-check",
+	Forward: func(ctx action.FWContext) (action.Result, error) {
+		mach := ctx.Previous.(machine.Machine)
+		args := ctx.Params[0].(runMachineActionsArgs)
+		writer := args.writer
+		if writer == nil {
+			writer = ioutil.Discard
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check quota (%s) for machine (%s)", args.box.QuotaId, args.box.GetFullName())))
+		if err := carton.EnforceQuota(args.box, writer); err != nil {
+			_ = mach.SetMileStone(constants.StateMachineParked)
+			_ = mach.SetStatus(constants.StatusError)
+			return nil, err
+		}
+		fmt.Fprintf(writer, lb.W(lb.DEPLOY, lb.INFO, fmt.Sprintf(" check quota (%s) for machine (%s) OK", args.box.QuotaId, args.box.GetFullName())))
+		return mach, nil
+	},
+	Backward: func(ctx action.BWContext) {
+		args := ctx.Params[0].(runMachineActionsArgs)
+		if err := carton.ReleaseQuota(args.box); err != nil {
+			log.Errorf("  release quota (%s) of box (%s) : %s", args.box.QuotaId, args.box.GetFullName(), err)
+		}
 	},
 }
 
@@ -0,0 +1,155 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/megamsys/vertice/provision/boltstore"
+)
+
+const (
+	MEMORY_STORAGE = "memory"
+	BOLT_STORAGE   = "bolt"
+)
+
+var nodesBucket = []byte("nodes")
+
+// BoltStorage keeps the nodes in a bolt file, so their health outlives a
+// restart. The vertice opening the same file share them, see boltstore.
+type BoltStorage struct {
+	*boltstore.Store
+	holder string
+}
+
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	s, err := boltstore.Open(path, boltstore.DefaultTimeout, nodesBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStorage{Store: s, holder: boltstore.Holder()}, nil
+}
+
+// the nodes are stored with their healing data, left out of their json.
+type storedNode Node
+
+func getNode(b *bolt.Bucket, region string) (Node, error) {
+	var n storedNode
+	if err := boltstore.Get(b, region, &n, ErrNoSuchNode); err != nil {
+		return Node{}, err
+	}
+	if n.Metadata == nil {
+		n.Metadata = make(map[string]string)
+	}
+	return Node(n), nil
+}
+
+func putNode(b *bolt.Bucket, node Node) error {
+	return boltstore.Put(b, node.Region, storedNode(node))
+}
+
+func (s *BoltStorage) StoreNode(node Node) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		if boltstore.Has(b, node.Region) {
+			return ErrDuplicatedNodeAddress
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		return putNode(b, node)
+	})
+}
+
+func (s *BoltStorage) RetrieveNodes() ([]Node, error) {
+	nodes := []Node{}
+	err := s.View(nodesBucket, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			n, err := getNode(b, string(k))
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, n)
+			return nil
+		})
+	})
+	return nodes, err
+}
+
+func (s *BoltStorage) RetrieveNode(region string) (Node, error) {
+	var node Node
+	err := s.View(nodesBucket, func(b *bolt.Bucket) error {
+		var err error
+		node, err = getNode(b, region)
+		return err
+	})
+	if err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
+func (s *BoltStorage) UpdateNode(node Node) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		if !boltstore.Has(b, node.Region) {
+			return ErrNoSuchNode
+		}
+		return putNode(b, node)
+	})
+}
+
+func (s *BoltStorage) RemoveNode(region string) error {
+	return s.RemoveNodes([]string{region})
+}
+
+func (s *BoltStorage) RemoveNodes(regions []string) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		return boltstore.Remove(b, regions, ErrNoSuchNode)
+	})
+}
+
+// LockNodeForHealing leases the node to this vertice till the timeout, the
+// lease of another ending at the timeout it took.
+func (s *BoltStorage) LockNodeForHealing(region string, isFailure bool, timeout time.Duration) (bool, error) {
+	locked := false
+	err := s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, region)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if n.Healing.LockedUntil.After(now) {
+			return nil
+		}
+		n.Healing = HealingData{LockedUntil: now.Add(timeout), IsFailure: isFailure, Holder: s.holder}
+		locked = true
+		return putNode(b, n)
+	})
+	return locked && err == nil, err
+}
+
+func (s *BoltStorage) ExtendNodeLock(region string, timeout time.Duration) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, region)
+		if err != nil {
+			return err
+		}
+		if n.Healing.Holder != s.holder {
+			return boltstore.ErrLeaseLost
+		}
+		n.Healing.LockedUntil = time.Now().UTC().Add(timeout)
+		return putNode(b, n)
+	})
+}
+
+func (s *BoltStorage) UnlockNode(region string) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, region)
+		if err != nil {
+			return err
+		}
+		if n.Healing.Holder != s.holder {
+			return boltstore.ErrLeaseLost
+		}
+		n.Healing = HealingData{}
+		return putNode(b, n)
+	})
+}
@@ -0,0 +1,75 @@
+package cluster
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/megamsys/vertice/provision/boltstore"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestBoltStorage(c *check.C) {
+	path := filepath.Join(c.MkDir(), "one_cluster.db")
+	stor, err := NewBoltStorage(path)
+	c.Assert(err, check.IsNil)
+	chennai := Node{Address: "http://one.chennai:2633/RPC2", Region: "chennai"}
+	paris := Node{Address: "http://one.paris:2633/RPC2", Region: "paris", Metadata: map[string]string{"Failures": "2"}}
+	c.Assert(stor.StoreNode(chennai), check.IsNil)
+	c.Assert(stor.StoreNode(paris), check.IsNil)
+	c.Assert(stor.StoreNode(chennai), check.Equals, ErrDuplicatedNodeAddress)
+	nodes, err := stor.RetrieveNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 2)
+	n, err := stor.RetrieveNode("chennai")
+	c.Assert(err, check.IsNil)
+	c.Assert(n.Address, check.Equals, chennai.Address)
+	c.Assert(n.Metadata, check.NotNil)
+	_, err = stor.RetrieveNode("tokyo")
+	c.Assert(err, check.Equals, ErrNoSuchNode)
+	c.Assert(stor.UpdateNode(Node{Region: "tokyo"}), check.Equals, ErrNoSuchNode)
+	locked, err := stor.LockNodeForHealing("paris", true, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	locked, err = stor.LockNodeForHealing("paris", false, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, false)
+	c.Assert(stor.Close(), check.IsNil)
+	stor, err = NewBoltStorage(path)
+	c.Assert(err, check.IsNil)
+	defer stor.Close()
+	n, err = stor.RetrieveNode("paris")
+	c.Assert(err, check.IsNil)
+	c.Assert(n.FailureCount(), check.Equals, 2)
+	c.Assert(n.Healing.IsFailure, check.Equals, true)
+	c.Assert(stor.UnlockNode("paris"), check.IsNil)
+	locked, err = stor.LockNodeForHealing("paris", false, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	c.Assert(stor.RemoveNodes([]string{"paris", "tokyo"}), check.IsNil)
+	c.Assert(stor.RemoveNode("paris"), check.Equals, ErrNoSuchNode)
+	nodes, err = stor.RetrieveNodes()
+	c.Assert(err, check.IsNil)
+	c.Assert(nodes, check.HasLen, 1)
+}
+
+func (s *S) TestBoltStorageLeasesTheNodeToOneHolder(c *check.C) {
+	path := filepath.Join(c.MkDir(), "one_cluster.db")
+	stor, err := NewBoltStorage(path)
+	c.Assert(err, check.IsNil)
+	other, err := NewBoltStorage(path)
+	c.Assert(err, check.IsNil)
+	other.holder = "other.vertice/1"
+	c.Assert(stor.StoreNode(Node{Address: "http://one.paris:2633/RPC2", Region: "paris"}), check.IsNil)
+	locked, err := stor.LockNodeForHealing("paris", true, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+	locked, err = other.LockNodeForHealing("paris", true, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, false)
+	c.Assert(other.ExtendNodeLock("paris", time.Minute), check.Equals, boltstore.ErrLeaseLost)
+	c.Assert(other.UnlockNode("paris"), check.Equals, boltstore.ErrLeaseLost)
+	c.Assert(stor.UnlockNode("paris"), check.IsNil)
+	locked, err = other.LockNodeForHealing("paris", false, time.Minute)
+	c.Assert(err, check.IsNil)
+	c.Assert(locked, check.Equals, true)
+}
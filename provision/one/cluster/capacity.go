@@ -0,0 +1,94 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/megamsys/opennebula-go/compute"
+)
+
+const (
+	HOSTPOOL_INFO = "one.hostpool.info"
+
+	// the hosts monitored by opennebula, the other ones take no vm.
+	HOST_MONITORED = 2
+)
+
+// ClusterCapacity is the room left in the monitored hosts of a cluster, the
+// cpu in cores and the memory in MB.
+type ClusterCapacity struct {
+	FreeCpu    float64
+	FreeMemory float64
+	// the most room left in a single host.
+	HostCpu    float64
+	HostMemory float64
+}
+
+// Fits tells if a host of the cluster may take the vm.
+func (c ClusterCapacity) Fits(opts compute.VirtualMachine) bool {
+	cpu, _ := strconv.ParseFloat(opts.Cpu, 64)
+	memory, _ := strconv.ParseFloat(opts.Memory, 64)
+	return c.HostCpu >= cpu && c.HostMemory >= memory
+}
+
+type hostPool struct {
+	Hosts []host `xml:"HOST"`
+}
+
+type host struct {
+	ClusterId string    `xml:"CLUSTER_ID"`
+	State     int       `xml:"STATE"`
+	Share     hostShare `xml:"HOST_SHARE"`
+}
+
+// the cpu in hundredths of a core and the memory in KB.
+type hostShare struct {
+	MaxCpu   float64 `xml:"MAX_CPU"`
+	CpuUsage float64 `xml:"CPU_USAGE"`
+	MaxMem   float64 `xml:"MAX_MEM"`
+	MemUsage float64 `xml:"MEM_USAGE"`
+}
+
+// capacities returns the free capacity of the clusters of a node by id.
+func (c *Cluster) capacities(nodeo Node) (map[string]ClusterCapacity, error) {
+	node, err := c.getNodeByObject(nodeo)
+	if err != nil {
+		return nil, err
+	}
+	res, err := node.Client.Call(HOSTPOOL_INFO, []interface{}{node.Client.Key})
+	if err != nil {
+		return nil, wrapError(node, err)
+	}
+	if len(res) < 2 {
+		return nil, fmt.Errorf("unexpected %s response from %s", HOSTPOOL_INFO, nodeo.Address)
+	}
+	pool, ok := res[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %s response from %s", HOSTPOOL_INFO, nodeo.Address)
+	}
+	return parseCapacities([]byte(pool))
+}
+
+func parseCapacities(pool []byte) (map[string]ClusterCapacity, error) {
+	var hp hostPool
+	if err := xml.Unmarshal(pool, &hp); err != nil {
+		return nil, err
+	}
+	free := make(map[string]ClusterCapacity)
+	for _, h := range hp.Hosts {
+		if h.State != HOST_MONITORED {
+			continue
+		}
+		cpu := (h.Share.MaxCpu - h.Share.CpuUsage) / 100
+		memory := (h.Share.MaxMem - h.Share.MemUsage) / 1024
+		cc := free[h.ClusterId]
+		cc.FreeCpu += cpu
+		cc.FreeMemory += memory
+		cc.HostCpu = math.Max(cc.HostCpu, cpu)
+		cc.HostMemory = math.Max(cc.HostMemory, memory)
+		free[h.ClusterId] = cc
+	}
+	return free, nil
+}
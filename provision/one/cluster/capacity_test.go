@@ -0,0 +1,31 @@
+package cluster
+
+import (
+	"github.com/megamsys/opennebula-go/compute"
+	"gopkg.in/check.v1"
+)
+
+const testHostPool = `<HOST_POOL>
+<HOST><ID>0</ID><STATE>2</STATE><CLUSTER_ID>100</CLUSTER_ID>
+<HOST_SHARE><MAX_CPU>800</MAX_CPU><CPU_USAGE>200</CPU_USAGE><MAX_MEM>16777216</MAX_MEM><MEM_USAGE>4194304</MEM_USAGE></HOST_SHARE></HOST>
+<HOST><ID>1</ID><STATE>2</STATE><CLUSTER_ID>100</CLUSTER_ID>
+<HOST_SHARE><MAX_CPU>400</MAX_CPU><CPU_USAGE>0</CPU_USAGE><MAX_MEM>8388608</MAX_MEM><MEM_USAGE>0</MEM_USAGE></HOST_SHARE></HOST>
+<HOST><ID>2</ID><STATE>4</STATE><CLUSTER_ID>101</CLUSTER_ID>
+<HOST_SHARE><MAX_CPU>800</MAX_CPU><CPU_USAGE>0</CPU_USAGE><MAX_MEM>16777216</MAX_MEM><MEM_USAGE>0</MEM_USAGE></HOST_SHARE></HOST>
+</HOST_POOL>`
+
+func (s *S) TestParseCapacities(c *check.C) {
+	free, err := parseCapacities([]byte(testHostPool))
+	c.Assert(err, check.IsNil)
+	c.Assert(free, check.HasLen, 1)
+	c.Assert(free["100"], check.DeepEquals, ClusterCapacity{FreeCpu: 10, FreeMemory: 20480, HostCpu: 6, HostMemory: 12288})
+	_, err = parseCapacities([]byte("<HOST_POOL>"))
+	c.Assert(err, check.NotNil)
+}
+
+func (s *S) TestClusterCapacityFits(c *check.C) {
+	cc := ClusterCapacity{FreeCpu: 10, FreeMemory: 20480, HostCpu: 6, HostMemory: 12288}
+	c.Assert(cc.Fits(compute.VirtualMachine{Cpu: "4", Memory: "8192"}), check.Equals, true)
+	c.Assert(cc.Fits(compute.VirtualMachine{Cpu: "8", Memory: "8192"}), check.Equals, false)
+	c.Assert(cc.Fits(compute.VirtualMachine{Cpu: "4", Memory: "16384"}), check.Equals, false)
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"github.com/megamsys/libgo/utils"
 	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/provision/healer"
+	"sort"
 	"sync/atomic"
 	"time"
 )
@@ -44,9 +46,10 @@ type ClusterHook interface {
 // Cluster is the basic type of the package. It manages internal nodes, and
 // provide methods for interaction with those nodes
 type Cluster struct {
-	Healer Healer
-	Hook   ClusterHook
-	stor   Storage
+	Healer  Healer
+	Hook    ClusterHook
+	stor    Storage
+	monitor *healer.Monitor
 }
 
 type OneNodeError struct {
@@ -96,6 +99,9 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	if len(nodes) > 0 {
 		for _, n := range nodes {
 			err = c.Register(n)
+			if err == ErrDuplicatedNodeAddress {
+				err = c.refresh(n)
+			}
 			if err != nil {
 				return &c, err
 			}
@@ -104,6 +110,16 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	return &c, err
 }
 
+// refresh replaces a node stored before, by an earlier run or another
+// vertice, keeping its health.
+func (c *Cluster) refresh(node Node) error {
+	stored, err := c.storage().RetrieveNode(node.Region)
+	if err != nil {
+		return err
+	}
+	return c.storage().UpdateNode(node.withHealthOf(stored))
+}
+
 // Register adds new nodes to the cluster.
 func (c *Cluster) Register(node Node) error {
 	if node.Region == "" {
@@ -176,7 +192,9 @@ func (c *Cluster) handleNodeError(region string, lastErr error, incrementFailure
 		if duration > 0 {
 			node.updateDisabled(time.Now().Add(duration))
 		}
-		c.storage().UpdateNode(node)
+		if err = c.storage().UpdateNode(node); err == nil && duration > 0 {
+			nodeDisabled(node, duration)
+		}
 		if fn := nodeUpdatedOnError.Val(); fn != nil {
 			fn()
 		}
@@ -208,8 +226,35 @@ func (c *Cluster) handleNodeSuccess(region string) error {
 	if err != nil {
 		return err
 	}
+	disabled := node.wasDisabled()
+	recovered := node
 	node.updateSuccess()
-	return c.storage().UpdateNode(node)
+	if err = c.storage().UpdateNode(node); err == nil && disabled {
+		nodeRecovered(recovered)
+	}
+	return err
+}
+
+// handleNodeProbed enables a disabled node answering its probe.
+func (c *Cluster) handleNodeProbed(region string) error {
+	unlock, err := c.lockWithTimeout(region, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	node, err := c.storage().RetrieveNode(region)
+	if err != nil {
+		return err
+	}
+	if !node.wasDisabled() {
+		return nil
+	}
+	recovered := node
+	node.updateEnabled()
+	if err = c.storage().UpdateNode(node); err == nil {
+		nodeRecovered(recovered)
+	}
+	return err
 }
 
 func (c *Cluster) lockWithTimeout(region string, isFailure bool) (func(), error) {
@@ -264,18 +309,32 @@ func (c *Cluster) getNodeByObject(nodeo Node) (node, error) {
 	return node{addr: nodeo.Address, template: template, Client: client}, nil
 }
 
-//return vnets and cluster id which is choosen
-func (c *Cluster) getVnets(nodeo Node, m map[string]string, st string) (map[string]string, string) {
+//return the vnets of the cluster turned on in m.
+func (c *Cluster) clusterVnets(nodeo Node, m map[string]string, clusterId string) (map[string]string, string) {
 	res := make(map[string]string)
+	if clusterId == "" {
+		return res, ""
+	}
+	for i, j := range nodeo.Clusters[clusterId] {
+		if m[i] == utils.TRUE {
+			res[i] = j
+		}
+	}
+	return res, clusterId
+}
+
+// clusterIds returns the clusters of the node with the storage type, in the
+// order they are tried. A node without one creates the vm from the image.
+func clusterIds(nodeo Node, st string) []string {
+	var ids []string
 	for k, v := range nodeo.Clusters {
 		if v[utils.STORAGE_TYPE] == st && v[utils.VONE_CLOUD] != utils.TRUE {
-			for i, j := range nodeo.Clusters[k] {
-				if m[i] == utils.TRUE {
-					res[i] = j
-				}
-			}
-			return res, k
+			ids = append(ids, k)
 		}
 	}
-	return res, ""
+	if len(ids) == 0 {
+		return []string{""}
+	}
+	sort.Strings(ids)
+	return ids
 }
@@ -1,6 +1,15 @@
 package cluster
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/provision/healer"
+)
+
+const SYSTEM_VERSION = "one.system.version"
 
 type Healer interface {
 	HandleError(node *Node) time.Duration
@@ -11,3 +20,83 @@ type DefaultHealer struct{}
 func (DefaultHealer) HandleError(node *Node) time.Duration {
 	return 1 * time.Minute
 }
+
+// ExponentialHealer disables a node by its failures, see healer.Exponential.
+type ExponentialHealer struct {
+	healer.Exponential
+}
+
+// NewExponentialHealer returns a healer, the defaults replacing the zero
+// values.
+func NewExponentialHealer(threshold int, backoff, maxBackoff time.Duration) ExponentialHealer {
+	return ExponentialHealer{healer.NewExponential(threshold, backoff, maxBackoff)}
+}
+
+func (h ExponentialHealer) HandleError(node *Node) time.Duration {
+	return h.Disable(node.FailureCount())
+}
+
+// StartActiveMonitoring probes the disabled nodes every interval, the ones
+// answering are enabled before their backoff ends.
+func (c *Cluster) StartActiveMonitoring(interval time.Duration) {
+	c.monitor = healer.StartMonitor(interval, c.probeDisabledNodes)
+}
+
+func (c *Cluster) StopActiveMonitoring() {
+	if c.monitor != nil {
+		c.monitor.Stop()
+	}
+}
+
+func (c *Cluster) probeDisabledNodes() {
+	nodes, err := c.UnfilteredNodes()
+	if err != nil {
+		log.Errorf("  > [healer] retrieving the nodes: %s", err)
+		return
+	}
+	probes := make(map[string]func() error)
+	for _, n := range nodes {
+		if !n.wasDisabled() || n.isEnabled() || n.isHealing() {
+			continue
+		}
+		nodeo := n
+		probes[n.Region] = func() error { return c.probeNode(nodeo) }
+	}
+	healer.Probe(probes, func(region string) { c.handleNodeProbed(region) })
+}
+
+// probeNode asks the opennebula of a node for its version.
+func (c *Cluster) probeNode(nodeo Node) error {
+	node, err := c.getNodeByObject(nodeo)
+	if err != nil {
+		return err
+	}
+	res, err := node.Client.Call(SYSTEM_VERSION, []interface{}{node.Client.Key})
+	if err != nil {
+		return wrapError(node, err)
+	}
+	if len(res) < 2 {
+		return fmt.Errorf("unexpected %s response from %s", SYSTEM_VERSION, nodeo.Address)
+	}
+	if ok, _ := res[0].(bool); !ok {
+		return fmt.Errorf("%s failed on %s: %v", SYSTEM_VERSION, nodeo.Address, res[1])
+	}
+	return nil
+}
+
+func nodeDisabled(node Node, duration time.Duration) error {
+	return healer.NodeDisabled(nodeEventData(node), duration)
+}
+
+func nodeRecovered(node Node) error {
+	return healer.NodeRecovered(nodeEventData(node))
+}
+
+func nodeEventData(node Node) map[string]string {
+	mi := make(map[string]string)
+	mi["address"] = node.Address
+	mi["region"] = node.Region
+	mi["failures"] = strconv.Itoa(node.FailureCount())
+	mi["last_error"] = node.Metadata["LastError"]
+	return mi
+}
@@ -0,0 +1,21 @@
+package cluster
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestExponentialHealerHandleError(c *check.C) {
+	h := NewExponentialHealer(2, time.Minute, 3*time.Minute)
+	node := Node{Address: "http://one.chennai:2633/RPC2", Region: "chennai"}
+	c.Assert(h.HandleError(&node), check.Equals, time.Duration(0))
+	node.Metadata = map[string]string{"Failures": "1"}
+	c.Assert(h.HandleError(&node), check.Equals, time.Duration(0))
+	node.Metadata["Failures"] = "2"
+	c.Assert(h.HandleError(&node), check.Equals, time.Minute)
+	node.Metadata["Failures"] = "3"
+	c.Assert(h.HandleError(&node), check.Equals, 2*time.Minute)
+	node.Metadata["Failures"] = "9"
+	c.Assert(h.HandleError(&node), check.Equals, 3*time.Minute)
+}
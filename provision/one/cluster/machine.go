@@ -13,12 +13,12 @@ import (
 	"github.com/megamsys/opennebula-go/virtualmachine"
 	"net"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 )
 
-// CreateVM creates a vm in the specified node.
-// It returns the vm, or an error, in case of failures.
 const (
 	START   = "start"
 	STOP    = "stop"
@@ -27,68 +27,151 @@ const (
 
 var ErrConnRefused = errors.New("connection refused")
 
+var (
+	// CreateVMTries is the number of rounds over the nodes of a region.
+	CreateVMTries = 5
+
+	// CreateVMBackoff is the wait before the second round, doubled after.
+	CreateVMBackoff = 2 * time.Second
+)
+
+// CreateVMError lists every failed attempt to create a vm in a region.
+type CreateVMError struct {
+	Region   string
+	Attempts []string
+}
+
+func (e *CreateVMError) Error() string {
+	return fmt.Sprintf("CreateVM: maximum number of tries exceeded in region %s, %d attempts:\n  %s", e.Region, len(e.Attempts), strings.Join(e.Attempts, "\n  "))
+}
+
+// CreateVM creates a vm in a node of the region. A failed vm is created again
+// in the other clusters of the node, in the order of the scheduler of the
+// node, and a node failing to answer is disabled and the vm is created in the
+// other nodes of the region. As the create may have gone through before the
+// node stopped answering, the vm is looked up by its name in the nodes that
+// failed before it is created again. Every round over the enabled nodes (all
+// of them when none is) is retried with a backoff, up to CreateVMTries.
+// It returns the address of the node, the vm, or an error listing every
+// attempt.
 func (c *Cluster) CreateVM(opts compute.VirtualMachine, throttle, storage string) (string, string, string, error) {
+	all, err := c.UnfilteredNodes()
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(NodeList(all).inRegion(opts.Region)) == 0 {
+		return "", "", "", fmt.Errorf("%s", cmd.Colorfy("Unavailable region ( "+opts.Region+" ) nodes (hint: start or beat it).\n", "red", "", ""))
+	}
 
-	var (
-		addr    string
-		machine string
-		vmid    string
-		err     error
-	)
-	maxTries := 5
-	for ; maxTries > 0; maxTries-- {
-
-		nodlist, err := c.Nodes()
-
-		for _, v := range nodlist {
-			if v.Metadata[api.ONEZONE] == opts.Region {
-				addr = v.Address
-				opts.Vnets, opts.ClusterId = c.getVnets(v, opts.Vnets, storage)
-				if v.Metadata[api.VCPU_PERCENTAGE] != "" {
-					opts.Cpu = cpuThrottle(v.Metadata[api.VCPU_PERCENTAGE], opts.Cpu)
+	so := &SchedulerOptions{StorageType: storage, Group: opts.ContextMap[compute.ASSEMBLIES_ID]}
+	failed := &CreateVMError{Region: opts.Region}
+	var unsure NodeList
+	delay := CreateVMBackoff
+	for try := 1; try <= CreateVMTries; try++ {
+		if try > 1 {
+			log.Warnf("  > retrying CreateVM %s in %s (%d of %d)", opts.Name, delay, try, CreateVMTries)
+			time.Sleep(delay)
+			delay *= 2
+		}
+		nodes, err := c.Nodes()
+		if err != nil {
+			return "", "", "", err
+		}
+		if nodes = NodeList(nodes).inRegion(opts.Region); len(nodes) == 0 {
+			// all disabled, the nodes of the region are retried anyway.
+			all, _ = c.UnfilteredNodes()
+			nodes = NodeList(all).inRegion(opts.Region)
+		}
+		sort.Sort(byPreference{nodes, opts.Region})
+
+		for _, n := range nodes {
+			if len(unsure) > 0 {
+				var found Node
+				var vmid string
+				if found, vmid, unsure = c.createdVM(nodes, unsure, opts.Name); vmid != "" {
+					c.handleNodeSuccess(found.Region)
+					return found.Address, opts.Name, vmid, nil
+				}
+			}
+			for _, clusterId := range c.schedule(n, opts, so) {
+				o := opts
+				o.Vnets, o.ClusterId = c.clusterVnets(n, opts.Vnets, clusterId)
+				if n.Metadata[api.VCPU_PERCENTAGE] != "" {
+					o.Cpu = cpuThrottle(n.Metadata[api.VCPU_PERCENTAGE], opts.Cpu)
 				} else {
-					opts.Cpu = cpuThrottle(throttle, opts.Cpu)
+					o.Cpu = cpuThrottle(throttle, opts.Cpu)
+				}
+				machine, vmid, err := c.createVMInNode(o, n)
+				if err == nil {
+					c.handleNodeSuccess(n.Region)
+					return n.Address, machine, vmid, nil
+				}
+				log.Errorf("  > CreateVM %s in %s cluster (%s) : %s", opts.Name, n.Address, clusterId, err)
+				failed.Attempts = append(failed.Attempts, fmt.Sprintf("try %d: %s cluster (%s): %s", try, n.Address, clusterId, err))
+				if isNodeFailure(err) {
+					c.handleNodeError(n.Region, err, true)
+					unsure = unsure.add(n)
+					break
 				}
 			}
 		}
-
-		if addr == "" {
-			return addr, machine, vmid, fmt.Errorf("%s", cmd.Colorfy("Unavailable region ( "+opts.Region+" ) nodes (hint: start or beat it).\n", "red", "", ""))
+	}
+	if len(unsure) > 0 {
+		if found, vmid, _ := c.createdVM(NodeList(all).inRegion(opts.Region), unsure, opts.Name); vmid != "" {
+			c.handleNodeSuccess(found.Region)
+			return found.Address, opts.Name, vmid, nil
 		}
-		if err == nil {
-			machine, vmid, err = c.createVMInNode(opts, addr)
-			if err == nil {
-				c.handleNodeSuccess(addr)
-				break
+	}
+	return "", "", "", failed
+}
+
+// createdVM looks up the vm named name in the zones of the nodes that failed
+// to answer its create, through any of the nodes of the zone as they share
+// its opennebula. It returns the node that found the vm and the vm, else the
+// nodes still unsure: the ones of a zone where no node answered.
+func (c *Cluster) createdVM(nodes, unsure NodeList, name string) (Node, string, NodeList) {
+	var still NodeList
+	for _, u := range unsure {
+		answered := false
+		for _, n := range append(NodeList{u}, nodes...) {
+			if zoneOf(n) != zoneOf(u) {
+				continue
 			}
-			log.Errorf("  > Trying... %s", addr)
-		}
-		shouldIncrementFailures := false
-		isCreateMachineErr := false
-		baseErr := err
-		if nodeErr, ok := baseErr.(OneNodeError); ok {
-			isCreateMachineErr = nodeErr.cmd == "createVM"
-			baseErr = nodeErr.BaseError()
-		}
-		if urlErr, ok := baseErr.(*url.Error); ok {
-			baseErr = urlErr.Err
+			vmid, found, err := c.lookupVM(n, name)
+			if err != nil {
+				log.Warnf("  > CreateVM %s lookup in %s : %s", name, n.Address, err)
+				continue
+			}
+			if found {
+				log.Warnf("  > CreateVM %s went through in %s as vm %s", name, n.Address, vmid)
+				return n, vmid, nil
+			}
+			answered = true
+			break
 		}
-		_, isNetErr := baseErr.(*net.OpError)
-		if isNetErr || isCreateMachineErr || baseErr == ErrConnRefused {
-			shouldIncrementFailures = true
+		if !answered {
+			still = append(still, u)
 		}
-		c.handleNodeError(addr, err, shouldIncrementFailures)
-		return addr, machine, vmid, err
 	}
-	if err != nil {
-		return addr, machine, vmid, fmt.Errorf("CreateVM: maximum number of tries exceeded, last error: %s", err.Error())
+	return Node{}, "", still
+}
+
+// the node didn't answer, as opposed to refusing the vm.
+func isNodeFailure(err error) bool {
+	baseErr := err
+	if nodeErr, ok := baseErr.(OneNodeError); ok {
+		baseErr = nodeErr.BaseError()
+	}
+	if urlErr, ok := baseErr.(*url.Error); ok {
+		baseErr = urlErr.Err
 	}
-	return addr, machine, vmid, err
+	_, isNetErr := baseErr.(*net.OpError)
+	return isNetErr || baseErr == ErrConnRefused || strings.Contains(baseErr.Error(), ErrConnRefused.Error())
 }
 
 //create a vm in a node.
-func (c *Cluster) createVMInNode(opts compute.VirtualMachine, nodeAddress string) (string, string, error) {
-	node, err := c.getNodeRegion(opts.Region)
+func (c *Cluster) createVMInNode(opts compute.VirtualMachine, nodeo Node) (string, string, error) {
+	node, err := c.getNodeByObject(nodeo)
 	if err != nil {
 		return "", "", err
 	}
@@ -103,7 +186,7 @@ func (c *Cluster) createVMInNode(opts compute.VirtualMachine, nodeAddress string
 
 	res, err := opts.Create()
 	if err != nil {
-		return "", "", err
+		return "", "", wrapError(node, err)
 	}
 	vmid := res.(string)
 	return opts.Name, vmid, nil
@@ -2196,3 +2196,28 @@ func TestInspectExec(t *testing.T) {
 	}
 }
 */
+
+import (
+	"errors"
+	"net"
+	"net/url"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestIsNodeFailure(c *check.C) {
+	opErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("i/o timeout")}
+	c.Assert(isNodeFailure(opErr), check.Equals, true)
+	c.Assert(isNodeFailure(&url.Error{Op: "Post", URL: "http://one:2633/RPC2", Err: opErr}), check.Equals, true)
+	c.Assert(isNodeFailure(OneNodeError{node: node{addr: "http://one:2633/RPC2"}, err: opErr}), check.Equals, true)
+	c.Assert(isNodeFailure(OneNodeError{node: node{addr: "http://one:2633/RPC2"}, err: ErrConnRefused}), check.Equals, true)
+	c.Assert(isNodeFailure(errors.New("dial tcp 10.0.0.1:2633: connection refused")), check.Equals, true)
+	c.Assert(isNodeFailure(errors.New("[VirtualMachineAllocate] User couldn't be authenticated")), check.Equals, false)
+	c.Assert(isNodeFailure(OneNodeError{node: node{addr: "http://one:2633/RPC2"}, err: errors.New("not enough capacity")}), check.Equals, false)
+}
+
+func (s *S) TestParseVMPool(c *check.C) {
+	vms, err := parseVMPool([]byte(`<VM_POOL><VM><ID>42</ID><NAME>hello.megambox.com</NAME></VM><VM><ID>43</ID><NAME>world.megambox.com</NAME></VM></VM_POOL>`))
+	c.Assert(err, check.IsNil)
+	c.Assert(vms, check.DeepEquals, []poolVM{{Id: "42", Name: "hello.megambox.com"}, {Id: "43", Name: "world.megambox.com"}})
+}
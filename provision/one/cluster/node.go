@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"strconv"
 	"time"
+
+	"github.com/megamsys/opennebula-go/api"
 )
 
 // Node represents a farm with endpoint of One. Each node has an Address
@@ -21,6 +23,7 @@ type Node struct {
 type HealingData struct {
 	LockedUntil time.Time
 	IsFailure   bool
+	Holder      string // the vertice leasing the node, in a shared storage.
 }
 
 type NodeList []Node
@@ -108,6 +111,46 @@ func (nodes NodeList) filterDisabled() NodeList {
 	return filtered
 }
 
+// inRegion returns the nodes of the region, its failover nodes included.
+func (nodes NodeList) inRegion(region string) NodeList {
+	filtered := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Region == region || node.Metadata[api.ONEZONE] == region {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// add returns the nodes with n, once.
+func (nodes NodeList) add(n Node) NodeList {
+	for _, node := range nodes {
+		if node.Address == n.Address {
+			return nodes
+		}
+	}
+	return append(nodes, n)
+}
+
+// byPreference sorts the node registered for the region first, then the
+// nodes with the least failures.
+type byPreference struct {
+	NodeList
+	region string
+}
+
+func (a byPreference) Less(i, j int) bool {
+	pi, pj := a.NodeList[i].Region == a.region, a.NodeList[j].Region == a.region
+	if pi != pj {
+		return pi
+	}
+	fi, fj := a.NodeList[i].FailureCount(), a.NodeList[j].FailureCount()
+	if fi != fj {
+		return fi < fj
+	}
+	return a.NodeList[i].Address < a.NodeList[j].Address
+}
+
 func (n *Node) updateError(lastErr error, incrementFailures bool) {
 	if n.Metadata == nil {
 		n.Metadata = make(map[string]string)
@@ -130,6 +173,12 @@ func (n *Node) updateSuccess() {
 	n.Metadata["LastSuccess"] = time.Now().Format(time.RFC3339)
 }
 
+// updateEnabled enables a disabled node answering its probe, its failures
+// are kept till an operation succeeds on it.
+func (n *Node) updateEnabled() {
+	delete(n.Metadata, "DisabledUntil")
+}
+
 func (n *Node) FailureCount() int {
 	if n.Metadata == nil {
 		return 0
@@ -139,6 +188,28 @@ func (n *Node) FailureCount() int {
 	return failures
 }
 
+// withHealthOf returns the node with the failures and healing of stored.
+func (n Node) withHealthOf(stored Node) Node {
+	metadata := make(map[string]string)
+	for k, v := range n.Metadata {
+		metadata[k] = v
+	}
+	for _, k := range []string{"Failures", "DisabledUntil", "LastSuccess", "LastError"} {
+		if v, ok := stored.Metadata[k]; ok {
+			metadata[k] = v
+		}
+	}
+	n.Metadata = metadata
+	n.Healing = stored.Healing
+	return n
+}
+
+// wasDisabled tells if the healer disabled the node since its last success.
+func (n *Node) wasDisabled() bool {
+	_, isDisabled := n.Metadata["DisabledUntil"]
+	return isDisabled
+}
+
 func (n *Node) ResetFailures() {
 	if n.Metadata == nil {
 		n.Metadata = make(map[string]string)
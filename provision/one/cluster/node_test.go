@@ -0,0 +1,45 @@
+package cluster
+
+import (
+	"sort"
+
+	"github.com/megamsys/opennebula-go/api"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestInRegion(c *check.C) {
+	nodes := NodeList{
+		{Address: "http://one.chennai:2633/RPC2", Region: "chennai"},
+		{Address: "http://one2.chennai:2633/RPC2", Region: "chennai@http://one2.chennai:2633/RPC2",
+			Metadata: map[string]string{api.ONEZONE: "chennai"}},
+		{Address: "http://one.paris:2633/RPC2", Region: "paris"},
+	}
+	in := nodes.inRegion("chennai")
+	c.Assert(in, check.HasLen, 2)
+	c.Assert(in[0].Address, check.Equals, "http://one.chennai:2633/RPC2")
+	c.Assert(in[1].Address, check.Equals, "http://one2.chennai:2633/RPC2")
+	c.Assert(nodes.inRegion("tokyo"), check.HasLen, 0)
+}
+
+func (s *S) TestByPreference(c *check.C) {
+	nodes := NodeList{
+		{Address: "http://c.chennai", Region: "chennai@http://c.chennai", Metadata: map[string]string{"Failures": "1"}},
+		{Address: "http://b.chennai", Region: "chennai@http://b.chennai", Metadata: map[string]string{"Failures": "3"}},
+		{Address: "http://a.chennai", Region: "chennai", Metadata: map[string]string{"Failures": "5"}},
+		{Address: "http://d.chennai", Region: "chennai@http://d.chennai"},
+	}
+	sort.Sort(byPreference{nodes, "chennai"})
+	var addrs []string
+	for _, n := range nodes {
+		addrs = append(addrs, n.Address)
+	}
+	c.Assert(addrs, check.DeepEquals, []string{"http://a.chennai", "http://d.chennai", "http://c.chennai", "http://b.chennai"})
+}
+
+func (s *S) TestNodeListAdd(c *check.C) {
+	var nodes NodeList
+	nodes = nodes.add(Node{Address: "http://a.chennai"})
+	nodes = nodes.add(Node{Address: "http://a.chennai"})
+	nodes = nodes.add(Node{Address: "http://b.chennai"})
+	c.Assert(nodes, check.HasLen, 2)
+}
@@ -0,0 +1,197 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/opennebula-go/compute"
+)
+
+const (
+	// SCHEDULER is the metadata of a node naming its scheduler.
+	SCHEDULER = "scheduler"
+
+	FIRST_SCHEDULER         = "first"
+	LEAST_LOADED_SCHEDULER  = "least_loaded"
+	ROUND_ROBIN_SCHEDULER   = "round_robin"
+	AFFINITY_SCHEDULER      = "affinity"
+	ANTI_AFFINITY_SCHEDULER = "anti_affinity"
+)
+
+var errNoClusters = errors.New("no clusters to schedule")
+
+// SchedulerOptions are the placement hints of a vm.
+type SchedulerOptions struct {
+	StorageType string
+	Group       string // the assemblies of the vm, placed by the affinity schedulers.
+}
+
+// Scheduler places the vms in the opennebula clusters of a node. It returns
+// the clusters, among the ones with the storage type of the vm, in the order
+// they are tried.
+type Scheduler interface {
+	Schedule(c *Cluster, n Node, clusters []string, opts compute.VirtualMachine, schedulerOpts *SchedulerOptions) ([]string, error)
+}
+
+var (
+	schedulersMu sync.RWMutex
+	schedulers   = make(map[string]Scheduler)
+)
+
+// RegisterScheduler makes a scheduler available to the regions by name.
+func RegisterScheduler(name string, s Scheduler) {
+	schedulersMu.Lock()
+	defer schedulersMu.Unlock()
+	schedulers[name] = s
+}
+
+func init() {
+	RegisterScheduler(FIRST_SCHEDULER, firstClusterScheduler{})
+	RegisterScheduler(LEAST_LOADED_SCHEDULER, leastLoadedScheduler{})
+	RegisterScheduler(ROUND_ROBIN_SCHEDULER, &roundRobinScheduler{next: make(map[string]int)})
+	RegisterScheduler(AFFINITY_SCHEDULER, affinityScheduler{anti: false})
+	RegisterScheduler(ANTI_AFFINITY_SCHEDULER, affinityScheduler{anti: true})
+}
+
+// GetScheduler returns the scheduler registered with name, the first one
+// when name is empty.
+func GetScheduler(name string) (Scheduler, error) {
+	if name == "" {
+		name = FIRST_SCHEDULER
+	}
+	schedulersMu.RLock()
+	defer schedulersMu.RUnlock()
+	s, ok := schedulers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown scheduler %q", name)
+	}
+	return s, nil
+}
+
+// schedule returns the clusters of the node the vm is tried in, the ones of
+// its storage type sorted by id when the scheduler fails.
+func (c *Cluster) schedule(n Node, opts compute.VirtualMachine, so *SchedulerOptions) []string {
+	candidates := clusterIds(n, so.StorageType)
+	if len(candidates) < 2 {
+		return candidates
+	}
+	s, err := GetScheduler(n.Metadata[SCHEDULER])
+	if err == nil {
+		var ids []string
+		if ids, err = s.Schedule(c, n, candidates, opts, so); err == nil && len(ids) > 0 {
+			return ids
+		}
+	}
+	log.Warnf("  > scheduler %q of %s failed, using the clusters by id : %v", n.Metadata[SCHEDULER], n.Region, err)
+	return candidates
+}
+
+// firstClusterScheduler fills the clusters by id.
+type firstClusterScheduler struct{}
+
+func (firstClusterScheduler) Schedule(c *Cluster, n Node, clusters []string, opts compute.VirtualMachine, so *SchedulerOptions) ([]string, error) {
+	if len(clusters) == 0 {
+		return nil, errNoClusters
+	}
+	return clusters, nil
+}
+
+// roundRobinScheduler starts every vm in the cluster after the one of the
+// previous vm of the node.
+type roundRobinScheduler struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func (s *roundRobinScheduler) Schedule(c *Cluster, n Node, clusters []string, opts compute.VirtualMachine, so *SchedulerOptions) ([]string, error) {
+	if len(clusters) == 0 {
+		return nil, errNoClusters
+	}
+	s.mu.Lock()
+	i := s.next[zoneOf(n)] % len(clusters)
+	s.next[zoneOf(n)] = i + 1
+	s.mu.Unlock()
+	return append(append([]string{}, clusters[i:]...), clusters[:i]...), nil
+}
+
+// leastLoadedScheduler prefers the clusters with the most free memory, then
+// the most free cpu, the clusters without room for the vm last.
+type leastLoadedScheduler struct{}
+
+func (leastLoadedScheduler) Schedule(c *Cluster, n Node, clusters []string, opts compute.VirtualMachine, so *SchedulerOptions) ([]string, error) {
+	if len(clusters) == 0 {
+		return nil, errNoClusters
+	}
+	free, err := c.capacities(n)
+	if err != nil {
+		return nil, err
+	}
+	ids := byFreeCapacity{append([]string{}, clusters...), free, opts}
+	sort.Stable(ids)
+	return ids.ids, nil
+}
+
+type byFreeCapacity struct {
+	ids  []string
+	free map[string]ClusterCapacity
+	opts compute.VirtualMachine
+}
+
+func (a byFreeCapacity) Len() int      { return len(a.ids) }
+func (a byFreeCapacity) Swap(i, j int) { a.ids[i], a.ids[j] = a.ids[j], a.ids[i] }
+func (a byFreeCapacity) Less(i, j int) bool {
+	fi, fj := a.free[a.ids[i]], a.free[a.ids[j]]
+	if ri, rj := fi.Fits(a.opts), fj.Fits(a.opts); ri != rj {
+		return ri
+	}
+	if fi.FreeMemory != fj.FreeMemory {
+		return fi.FreeMemory > fj.FreeMemory
+	}
+	return fi.FreeCpu > fj.FreeCpu
+}
+
+// affinityScheduler prefers the clusters with the most vms of the same
+// assemblies, or with the least of them when anti, as opennebula reports
+// them.
+type affinityScheduler struct {
+	anti bool
+}
+
+func (s affinityScheduler) Schedule(c *Cluster, n Node, clusters []string, opts compute.VirtualMachine, so *SchedulerOptions) ([]string, error) {
+	if len(clusters) == 0 {
+		return nil, errNoClusters
+	}
+	placed, err := c.placements(n, so.Group)
+	if err != nil {
+		return nil, err
+	}
+	ids := byPlacements{append([]string{}, clusters...), placed}
+	if s.anti {
+		sort.Stable(ids)
+	} else {
+		sort.Stable(sort.Reverse(ids))
+	}
+	return ids.ids, nil
+}
+
+// byPlacements sorts the clusters with the least vms of a group first.
+type byPlacements struct {
+	ids    []string
+	placed map[string]int
+}
+
+func (a byPlacements) Len() int           { return len(a.ids) }
+func (a byPlacements) Swap(i, j int)      { a.ids[i], a.ids[j] = a.ids[j], a.ids[i] }
+func (a byPlacements) Less(i, j int) bool { return a.placed[a.ids[i]] < a.placed[a.ids[j]] }
+
+// the failover nodes of a region share the clusters of its opennebula.
+func zoneOf(n Node) string {
+	if zone := n.Metadata[api.ONEZONE]; zone != "" {
+		return zone
+	}
+	return n.Region
+}
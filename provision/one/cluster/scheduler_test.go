@@ -0,0 +1,78 @@
+package cluster
+
+import (
+	"sort"
+
+	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/opennebula-go/compute"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestGetScheduler(c *check.C) {
+	sc, err := GetScheduler("")
+	c.Assert(err, check.IsNil)
+	c.Assert(sc, check.FitsTypeOf, firstClusterScheduler{})
+	_, err = GetScheduler(LEAST_LOADED_SCHEDULER)
+	c.Assert(err, check.IsNil)
+	_, err = GetScheduler("busiest")
+	c.Assert(err, check.ErrorMatches, `unknown scheduler "busiest"`)
+}
+
+func (s *S) TestByFreeCapacity(c *check.C) {
+	free := map[string]ClusterCapacity{
+		"100": {FreeCpu: 4, FreeMemory: 4096, HostCpu: 4, HostMemory: 4096},
+		"101": {FreeCpu: 8, FreeMemory: 8192, HostCpu: 2, HostMemory: 8192},
+		"102": {FreeCpu: 16, FreeMemory: 4096, HostCpu: 8, HostMemory: 4096},
+	}
+	ids := byFreeCapacity{[]string{"100", "101", "102", "103"}, free, compute.VirtualMachine{Cpu: "4", Memory: "2048"}}
+	sort.Stable(ids)
+	c.Assert(ids.ids, check.DeepEquals, []string{"102", "100", "101", "103"})
+}
+
+func (s *S) TestRoundRobinScheduler(c *check.C) {
+	sc := &roundRobinScheduler{next: make(map[string]int)}
+	chennai := Node{Region: "chennai"}
+	failover := Node{Region: "chennai@http://one2", Metadata: map[string]string{api.ONEZONE: "chennai"}}
+	clusters := []string{"100", "101", "102"}
+	var got [][]string
+	for _, n := range []Node{chennai, failover, chennai, chennai} {
+		ids, err := sc.Schedule(nil, n, clusters, compute.VirtualMachine{}, &SchedulerOptions{})
+		c.Assert(err, check.IsNil)
+		got = append(got, ids)
+	}
+	c.Assert(got, check.DeepEquals, [][]string{
+		{"100", "101", "102"},
+		{"101", "102", "100"},
+		{"102", "100", "101"},
+		{"100", "101", "102"},
+	})
+	c.Assert(clusters, check.DeepEquals, []string{"100", "101", "102"})
+	_, err := sc.Schedule(nil, chennai, nil, compute.VirtualMachine{}, &SchedulerOptions{})
+	c.Assert(err, check.Equals, errNoClusters)
+}
+
+func (s *S) TestPlacementsOf(c *check.C) {
+	vms, err := parseVMPool([]byte(`<VM_POOL>
+<VM><ID>1</ID><TEMPLATE><CONTEXT><ASSEMBLIES_ID>AMS001</ASSEMBLIES_ID></CONTEXT></TEMPLATE>
+<HISTORY_RECORDS><HISTORY><CID>100</CID></HISTORY><HISTORY><CID>101</CID></HISTORY></HISTORY_RECORDS></VM>
+<VM><ID>2</ID><TEMPLATE><CONTEXT><ASSEMBLIES_ID>AMS001</ASSEMBLIES_ID></CONTEXT></TEMPLATE>
+<HISTORY_RECORDS><HISTORY><CID>101</CID></HISTORY></HISTORY_RECORDS></VM>
+<VM><ID>3</ID><TEMPLATE><CONTEXT><ASSEMBLIES_ID>AMS001</ASSEMBLIES_ID></CONTEXT></TEMPLATE></VM>
+<VM><ID>4</ID><TEMPLATE><CONTEXT><ASSEMBLIES_ID>AMS002</ASSEMBLIES_ID></CONTEXT></TEMPLATE>
+<HISTORY_RECORDS><HISTORY><CID>100</CID></HISTORY></HISTORY_RECORDS></VM>
+</VM_POOL>`))
+	c.Assert(err, check.IsNil)
+	c.Assert(placementsOf(vms, "AMS001"), check.DeepEquals, map[string]int{"101": 2})
+	c.Assert(placementsOf(vms, "AMS002"), check.DeepEquals, map[string]int{"100": 1})
+	c.Assert(placementsOf(vms, ""), check.HasLen, 0)
+}
+
+func (s *S) TestAffinityOrdering(c *check.C) {
+	placed := map[string]int{"100": 1, "101": 3}
+	ids := byPlacements{[]string{"100", "101", "102"}, placed}
+	sort.Stable(ids)
+	c.Assert(ids.ids, check.DeepEquals, []string{"102", "100", "101"})
+	ids = byPlacements{[]string{"100", "101", "102"}, placed}
+	sort.Stable(sort.Reverse(ids))
+	c.Assert(ids.ids, check.DeepEquals, []string{"101", "100", "102"})
+}
@@ -0,0 +1,101 @@
+package cluster
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+const (
+	VMPOOL_INFO = "one.vmpool.info"
+
+	// the vms of every user, from the first to the last, in any state but done.
+	vmPoolAll   = -2
+	vmPoolFirst = -1
+	vmPoolLast  = -1
+	vmPoolState = -1
+)
+
+type vmPool struct {
+	VMs []poolVM `xml:"VM"`
+}
+
+type poolVM struct {
+	Id    string `xml:"ID"`
+	Name  string `xml:"NAME"`
+	Group string `xml:"TEMPLATE>CONTEXT>ASSEMBLIES_ID"`
+	// the clusters the vm was deployed in, the current one last.
+	ClusterIds []string `xml:"HISTORY_RECORDS>HISTORY>CID"`
+}
+
+// clusterId returns the cluster the vm is in, empty when it wasn't deployed.
+func (vm poolVM) clusterId() string {
+	if len(vm.ClusterIds) == 0 {
+		return ""
+	}
+	return vm.ClusterIds[len(vm.ClusterIds)-1]
+}
+
+// vms returns the vms of a node.
+func (c *Cluster) vms(nodeo Node) ([]poolVM, error) {
+	node, err := c.getNodeByObject(nodeo)
+	if err != nil {
+		return nil, err
+	}
+	res, err := node.Client.Call(VMPOOL_INFO, []interface{}{node.Client.Key, vmPoolAll, vmPoolFirst, vmPoolLast, vmPoolState})
+	if err != nil {
+		return nil, wrapError(node, err)
+	}
+	if len(res) < 2 {
+		return nil, fmt.Errorf("unexpected %s response from %s", VMPOOL_INFO, nodeo.Address)
+	}
+	pool, ok := res[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected %s response from %s", VMPOOL_INFO, nodeo.Address)
+	}
+	return parseVMPool([]byte(pool))
+}
+
+func parseVMPool(pool []byte) ([]poolVM, error) {
+	var vp vmPool
+	if err := xml.Unmarshal(pool, &vp); err != nil {
+		return nil, err
+	}
+	return vp.VMs, nil
+}
+
+// lookupVM returns the id of the vm named name in the node, found is false
+// when the node has none.
+func (c *Cluster) lookupVM(n Node, name string) (string, bool, error) {
+	vms, err := c.vms(n)
+	if err != nil {
+		return "", false, err
+	}
+	for _, vm := range vms {
+		if vm.Name == name {
+			return vm.Id, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// placements counts the vms of the group in every cluster of the node.
+func (c *Cluster) placements(n Node, group string) (map[string]int, error) {
+	vms, err := c.vms(n)
+	if err != nil {
+		return nil, err
+	}
+	return placementsOf(vms, group), nil
+}
+
+func placementsOf(vms []poolVM, group string) map[string]int {
+	placed := make(map[string]int)
+	if group == "" {
+		return placed
+	}
+	for _, vm := range vms {
+		if id := vm.clusterId(); vm.Group == group && id != "" {
+			placed[id]++
+		}
+	}
+	return placed
+}
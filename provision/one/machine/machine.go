@@ -10,9 +10,6 @@ import (
 
 	log "github.com/Sirupsen/logrus"
 	nsqp "github.com/crackcomm/nsqueue/producer"
-	"github.com/megamsys/libgo/events"
-	"github.com/megamsys/libgo/events/alerts"
-	"github.com/megamsys/libgo/events/bills"
 	"github.com/megamsys/libgo/safe"
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
@@ -23,6 +20,7 @@ import (
 	"github.com/megamsys/vertice/carton"
 	lb "github.com/megamsys/vertice/logbox"
 	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/one/cluster"
 )
@@ -101,25 +99,15 @@ func (m *Machine) Create(args *CreateArgs) error {
 	return nil
 }
 
-func (m *Machine) CheckCredits(b *provision.Box, w io.Writer) error {
-	bal, err := bills.NewBalances(b.AccountId, meta.MC.ToMap())
-	if err != nil || bal == nil {
-		return err
-	}
-
-	//have to decide what to do whether balance record is empty
-	i, err := strconv.ParseFloat(bal.Credit, 64)
+// CheckCredits reserves the estimated cost of the box for a billing period
+// against the credit balance of the user, units are the ones of its region.
+func (m *Machine) CheckCredits(b *provision.Box, units map[string]string, w io.Writer) error {
+	asm, err := carton.NewAssembly(m.CartonId, m.AccountId, "")
 	if err != nil {
 		return err
 	}
-
-	if i <= 0 {
-		carton.DoneNotify(b, w, alerts.INSUFFICIENT_FUND)
-		log.Debugf(" credit balance insufficient for the user (%s)", b.AccountId)
-		return fmt.Errorf("credit balance insufficient")
-	}
-
-	return nil
+	estimate := metrix.EstimateCost(b, asm.GetVMCpuCost(), asm.GetVMMemoryCost(), asm.GetVMHDDCost(), units)
+	return carton.ReserveCredits(b, estimate, w)
 }
 
 func (m *Machine) VmHostIpPort(args *CreateArgs) error {
@@ -220,33 +208,15 @@ func isDeleteOk(state constants.State) bool {
 	return state != constants.StateInitialized && state != constants.StateInitializing && state != constants.StatePreError
 }
 
-//trigger multi event in the order
-func (m *Machine) Deduct() error {
-	mi := make(map[string]string)
-	mi[constants.ACCOUNTID] = m.AccountId
-	mi[constants.ASSEMBLYID] = m.CartonId
-	mi[constants.ASSEMBLYNAME] = m.Name
-	mi[constants.CONSUMED] = "0.1"
-	mi[constants.START_TIME] = time.Now().Add(-10 * time.Minute).String()
-	mi[constants.END_TIME] = time.Now().String()
-	newEvent := events.NewMulti(
-		[]*events.Event{
-			&events.Event{
-				AccountsId:  m.AccountId,
-				EventAction: alerts.DEDUCT,
-				EventType:   constants.EventBill,
-				EventData:   alerts.EventData{M: mi},
-				Timestamp:   time.Now().Local(),
-			},
-			&events.Event{
-				AccountsId:  m.AccountId,
-				EventAction: alerts.BILLEDHISTORY, //Change type to transaction
-				EventType:   constants.EventBill,
-				EventData:   alerts.EventData{M: mi},
-				Timestamp:   time.Now().Local(),
-			},
-		})
-	return newEvent.Write()
+// Deduct bills the compute of the box for the time elapsed since the
+// assembly was billed last, priced with the units of its region.
+func (m *Machine) Deduct(b *provision.Box, units map[string]string) error {
+	asm, err := carton.NewAssembly(m.CartonId, m.AccountId, "")
+	if err != nil {
+		return err
+	}
+	costs := metrix.Costs{Cpu: asm.GetVMCpuCost(), Memory: asm.GetVMMemoryCost(), Disk: asm.GetVMHDDCost()}
+	return metrix.Deduct(asm, m.Name, b, units, costs)
 }
 
 func (m *Machine) LifecycleOps(p OneProvisioner, action string) error {
@@ -535,7 +505,7 @@ func (m *Machine) UpdateQuotas(id string) error {
 	if err != nil {
 		return err
 	}
-	quota.AllocatedTo = m.CartonId
+	quota.Allocate(m.CartonId)
 	if err = quota.Update(); err != nil {
 		return err
 	}
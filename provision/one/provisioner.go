@@ -20,8 +20,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path/filepath"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/megamsys/libgo/action"
@@ -33,12 +35,15 @@ import (
 	"github.com/megamsys/opennebula-go/api"
 	"github.com/megamsys/vertice/carton"
 	lb "github.com/megamsys/vertice/logbox"
+	"github.com/megamsys/vertice/meta"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/one/cluster"
 	"github.com/megamsys/vertice/provision/one/machine"
 	"github.com/megamsys/vertice/repository"
 	"github.com/megamsys/vertice/router"
 	_ "github.com/megamsys/vertice/router/route53"
+	"github.com/megamsys/vertice/toml"
 )
 
 var mainOneProvisioner *oneProvisioner
@@ -53,30 +58,49 @@ type oneProvisioner struct {
 	vcpuThrottle string
 	cluster      *cluster.Cluster
 	storage      cluster.Storage
+	units        map[string]map[string]string
 }
 
 type One struct {
-	Enabled        bool     `json:"enabled" toml:"enabled"`
-	Regions        []Region `json:"region" toml:"region"`
-	Image          string   `json:"image" toml:"image"`
-	VCPUPercentage string   `json:"vcpu_percentage" toml:"vcpu_percentage"`
-	OneTemplate    string   `json:"one_template" toml:"one_template"`
+	Enabled            bool          `json:"enabled" toml:"enabled"`
+	Regions            []Region      `json:"region" toml:"region"`
+	Image              string        `json:"image" toml:"image"`
+	VCPUPercentage     string        `json:"vcpu_percentage" toml:"vcpu_percentage"`
+	OneTemplate        string        `json:"one_template" toml:"one_template"`
+	HealerThreshold    int           `json:"healer_threshold" toml:"healer_threshold"`
+	HealerBackoff      toml.Duration `json:"healer_backoff" toml:"healer_backoff"`
+	HealerMaxBackoff   toml.Duration `json:"healer_max_backoff" toml:"healer_max_backoff"`
+	HealerProbe        toml.Duration `json:"healer_probe" toml:"healer_probe"`
+	ClusterStorage     string        `json:"cluster_storage" toml:"cluster_storage"`
+	ClusterStoragePath string        `json:"cluster_storage_path" toml:"cluster_storage_path"`
+}
+
+// Validate returns an error when a region names a scheduler not registered.
+func (c One) Validate() error {
+	for _, r := range c.Regions {
+		if _, err := cluster.GetScheduler(r.Scheduler); err != nil {
+			return fmt.Errorf("region %s : %s", r.OneZone, err)
+		}
+	}
+	return nil
 }
 
 type Region struct {
-	OneZone        string    `json:"one_zone" toml:"one_zone"`
-	OneEndPoint    string    `json:"one_endpoint" toml:"one_endpoint"`
-	OneUserid      string    `json:"one_user" toml:"one_user"`
-	OnePassword    string    `json:"one_password" toml:"one_password"`
-	OneMasterKey   string    `json:"one_masterkey" toml:"one_masterkey"`
-	OneTemplate    string    `json:"one_template" toml:"one_template"`
-	Image          string    `json:"image" toml:"image"`
-	VCPUPercentage string    `json:"vcpu_percentage" toml:"vcpu_percentage"`
-	Certificate    string    `json:"certificate" toml:"certificate"`
-	Clusters       []Cluster `json:"cluster" toml:"cluster"`
-	CpuUnit        string    `json:"cpu_unit" toml:"cpu_unit"`
-	MemoryUnit     string    `json:"memory_unit" toml:"memory_unit"`
-	DiskUnit       string    `json:"disk_unit" toml:"disk_unit"`
+	OneZone           string    `json:"one_zone" toml:"one_zone"`
+	OneEndPoint       string    `json:"one_endpoint" toml:"one_endpoint"`
+	FailoverEndPoints []string  `json:"one_failover_endpoints" toml:"one_failover_endpoints"`
+	OneUserid         string    `json:"one_user" toml:"one_user"`
+	OnePassword       string    `json:"one_password" toml:"one_password"`
+	OneMasterKey      string    `json:"one_masterkey" toml:"one_masterkey"`
+	OneTemplate       string    `json:"one_template" toml:"one_template"`
+	Image             string    `json:"image" toml:"image"`
+	VCPUPercentage    string    `json:"vcpu_percentage" toml:"vcpu_percentage"`
+	Certificate       string    `json:"certificate" toml:"certificate"`
+	Clusters          []Cluster `json:"cluster" toml:"cluster"`
+	CpuUnit           string    `json:"cpu_unit" toml:"cpu_unit"`
+	MemoryUnit        string    `json:"memory_unit" toml:"memory_unit"`
+	DiskUnit          string    `json:"disk_unit" toml:"disk_unit"`
+	Scheduler         string    `json:"scheduler" toml:"scheduler"`
 }
 
 type Cluster struct {
@@ -111,7 +135,8 @@ func (p *oneProvisioner) Initialize(m interface{}) error {
 func (p *oneProvisioner) initOneCluster(i interface{}) error {
 	var err error
 	if p.storage == nil {
-		p.storage, err = buildClusterStorage()
+		w, _ := i.(One)
+		p.storage, err = buildClusterStorage(w)
 		if err != nil {
 			return err
 		}
@@ -121,7 +146,9 @@ func (p *oneProvisioner) initOneCluster(i interface{}) error {
 		var nodes []cluster.Node
 		p.defaultImage = w.Image
 		p.vcpuThrottle = w.VCPUPercentage
+		p.units = make(map[string]map[string]string)
 		for i := 0; i < len(w.Regions); i++ {
+			p.units[w.Regions[i].OneZone] = w.Regions[i].toUnits()
 			m := w.Regions[i].toMap()
 			c := w.Regions[i].toClusterMap()
 			n := cluster.Node{
@@ -131,6 +158,7 @@ func (p *oneProvisioner) initOneCluster(i interface{}) error {
 				Clusters: c,
 			}
 			nodes = append(nodes, n)
+			nodes = append(nodes, w.Regions[i].failoverNodes(c)...)
 		}
 
 		//register nodes using the map.
@@ -138,6 +166,8 @@ func (p *oneProvisioner) initOneCluster(i interface{}) error {
 		if err != nil {
 			return err
 		}
+		p.cluster.Healer = cluster.NewExponentialHealer(w.HealerThreshold, time.Duration(w.HealerBackoff), time.Duration(w.HealerMaxBackoff))
+		p.cluster.StartActiveMonitoring(time.Duration(w.HealerProbe))
 	}
 	return nil
 }
@@ -152,9 +182,36 @@ func (c Region) toMap() map[string]string {
 	m[api.TEMPLATE] = c.OneTemplate
 	m[api.IMAGE] = c.Image
 	m[api.VCPU_PERCENTAGE] = c.VCPUPercentage
+	m[cluster.SCHEDULER] = c.Scheduler
 	return m
 }
 
+//the frontends of the region tried when its endpoint fails, they share its
+//opennebula and are registered apart as zone@endpoint.
+func (c Region) failoverNodes(clusters map[string]map[string]string) []cluster.Node {
+	var nodes []cluster.Node
+	for _, ep := range c.FailoverEndPoints {
+		m := c.toMap()
+		m[api.ENDPOINT] = ep
+		nodes = append(nodes, cluster.Node{
+			Address:  ep,
+			Region:   c.OneZone + "@" + ep,
+			Metadata: m,
+			Clusters: clusters,
+		})
+	}
+	return nodes
+}
+
+//the default units of the region to bill the vms.
+func (c Region) toUnits() map[string]string {
+	return map[string]string{
+		metrix.CPU_UNIT:    c.CpuUnit,
+		metrix.MEMORY_UNIT: c.MemoryUnit,
+		metrix.DISK_UNIT:   c.DiskUnit,
+	}
+}
+
 func (c Region) toClusterMap() map[string]map[string]string {
 	clData := make(map[string]map[string]string)
 	for i := 0; i < len(c.Clusters); i++ {
@@ -178,8 +235,20 @@ func (c Region) toClusterMap() map[string]map[string]string {
 	return clData
 }
 
-func buildClusterStorage() (cluster.Storage, error) {
-	return &cluster.MapStorage{}, nil
+// buildClusterStorage returns the storage of the nodes, in memory unless a
+// bolt file, shared by the vertice opening it, is configured.
+func buildClusterStorage(w One) (cluster.Storage, error) {
+	switch w.ClusterStorage {
+	case "", cluster.MEMORY_STORAGE:
+		return &cluster.MapStorage{}, nil
+	case cluster.BOLT_STORAGE:
+		path := w.ClusterStoragePath
+		if path == "" {
+			path = filepath.Join(meta.MC.Dir, "one_cluster.db")
+		}
+		return cluster.NewBoltStorage(path)
+	}
+	return nil, fmt.Errorf("unknown cluster storage %q", w.ClusterStorage)
 }
 
 func getRouterForBox(box *provision.Box) (router.Router, error) {
@@ -230,6 +299,7 @@ func (p *oneProvisioner) ImageDeploy(box *provision.Box, imageId string, w io.Wr
 
 //start by validating the image.
 //1. &updateStatus in Scylla - Deploying..
+//   &check the balance, or the quota the box is launched on.
 //2. &create an inmemory machine type from a Box.
 //3. &updateStatus in Scylla - Creating..
 //4. &followLogs by posting it in the queue.
@@ -241,6 +311,9 @@ func (p *oneProvisioner) deployPipeline(box *provision.Box, imageId string, w io
 	if events.IsEnabled(constants.BILLMGR) && !(len(box.QuotaId) > 0) {
 		actions = append(actions, &checkBalances, &updateStatusInScylla)
 	}
+	if len(box.QuotaId) > 0 {
+		actions = append(actions, &checkQuota)
+	}
 	actions = append(actions, &mileStoneUpdate, &createMachine, &getVmHostIpPort, &mileStoneUpdate, &updateStatusInScylla)
 	actions = append(actions, &updateVnchostPostInScylla, &updateStatusInScylla, &setFinalStatus, &updateStatusInScylla, &followLogs)
 
@@ -298,6 +371,10 @@ func (p *oneProvisioner) Destroy(box *provision.Box, w io.Writer) error {
 	}
 
 	fmt.Fprintf(w, lb.W(lb.DESTORYING, lb.INFO, fmt.Sprintf("--- destroying box (%s)OK", box.GetFullName())))
+	carton.Reservations.Release(box.AccountId, box.CartonId)
+	if err := carton.ReleaseQuota(box); err != nil {
+		log.Errorf("  release quota (%s) of box (%s) : %s", box.QuotaId, box.GetFullName(), err)
+	}
 	err = carton.DoneNotify(box, w, alerts.DESTROYED)
 	return nil
 }
@@ -548,10 +625,6 @@ func (p *oneProvisioner) Stop(box *provision.Box, process string, w io.Writer) e
 	return nil
 }
 
-func (p *oneProvisioner) Shell(provision.ShellOptions) error {
-	return provision.ErrNotImplemented
-}
-
 func (*oneProvisioner) Addr(box *provision.Box) (string, error) {
 	r, err := getRouterForBox(box)
 	if err != nil {
@@ -580,17 +653,14 @@ func (p *oneProvisioner) MetricEnvs(start int64, end int64, region string, w io.
 	return res, nil
 }
 
-func (p *oneProvisioner) TriggerBills(account_id, cat_id, name string) error {
+func (p *oneProvisioner) TriggerBills(box *provision.Box) error {
 	mach := &machine.Machine{
-		Name:      name,
-		CartonId:  cat_id,
-		AccountId: account_id,
+		Name:      box.GetFullName(),
+		Region:    box.Region,
+		CartonId:  box.CartonId,
+		AccountId: box.AccountId,
 	}
-	err := mach.Deduct()
-	if err != nil {
-		return err
-	}
-	return nil
+	return mach.Deduct(box, p.units[box.Region])
 }
 
 func (p *oneProvisioner) SetBoxStatus(box *provision.Box, w io.Writer, status utils.Status) error {
@@ -659,20 +729,3 @@ func (p *oneProvisioner) getBuildImage(re *repository.Repo, version string) stri
 func (p *oneProvisioner) usePlatformImage(re *repository.Repo) bool {
 	return !re.OneClick
 }
-
-func (p *oneProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, box *provision.Box, cmd string, args ...string) error {
-	/*if boxs, err := p.listRunnableMachinesByBox(box.GetName()); err ! =nil {
-					return err
-	    }
-
-		if err := nil; err != nil {
-			return err
-		}
-		if len(boxs) == 0 {
-			return provision.ErrBoxNotFound
-		}
-		box := boxs[0]
-		return box.Exec(p, stdout, stderr, cmd, args...)
-	*/
-	return nil
-}
@@ -25,7 +25,9 @@ import (
 	"sort"
 	"sync/atomic"
 	"time"*/
-
+	"github.com/megamsys/opennebula-go/api"
+	"github.com/megamsys/vertice/provision/one/cluster"
+	"gopkg.in/check.v1"
 )
 
 /*
@@ -886,3 +888,29 @@ func (s *S) TestMetricEnvs(c *check.C) {
 	c.Assert(envs, check.DeepEquals, expected)
 }
 */
+
+func (s *S) TestFailoverNodes(c *check.C) {
+	r := Region{
+		OneZone:           "chennai",
+		OneEndPoint:       "http://one.chennai:2633/RPC2",
+		FailoverEndPoints: []string{"http://one2.chennai:2633/RPC2"},
+		OneUserid:         "oneadmin",
+	}
+	clusters := map[string]map[string]string{"100": {}}
+	nodes := r.failoverNodes(clusters)
+	c.Assert(nodes, check.HasLen, 1)
+	c.Assert(nodes[0].Address, check.Equals, "http://one2.chennai:2633/RPC2")
+	c.Assert(nodes[0].Region, check.Equals, "chennai@http://one2.chennai:2633/RPC2")
+	c.Assert(nodes[0].Metadata[api.ENDPOINT], check.Equals, "http://one2.chennai:2633/RPC2")
+	c.Assert(nodes[0].Metadata[api.ONEZONE], check.Equals, "chennai")
+	c.Assert(nodes[0].Metadata[api.USERID], check.Equals, "oneadmin")
+	c.Assert(nodes[0].Clusters, check.DeepEquals, clusters)
+	c.Assert(Region{OneZone: "paris"}.failoverNodes(clusters), check.HasLen, 0)
+}
+
+func (s *S) TestOneValidateRejectsUnknownSchedulers(c *check.C) {
+	o := One{Regions: []Region{{OneZone: "chennai", Scheduler: cluster.AFFINITY_SCHEDULER}, {OneZone: "paris"}}}
+	c.Assert(o.Validate(), check.IsNil)
+	o.Regions[1].Scheduler = "busiest"
+	c.Assert(o.Validate(), check.ErrorMatches, `region paris : unknown scheduler "busiest"`)
+}
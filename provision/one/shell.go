@@ -0,0 +1,190 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+package one
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	sshPort        = "22"
+	sshDialTimeout = 10 * time.Second
+	defaultSSHUser = "root"
+	defaultTerm    = "xterm"
+	defaultWidth   = 80
+	defaultHeight  = 24
+)
+
+var (
+	ErrNoPublicIp      = errors.New("box has no public ip to ssh")
+	ErrHostKeyMismatch = errors.New("ssh host key of the box doesn't match the pinned one")
+)
+
+// sshConfig returns the client config to login as the ssh user of the box
+// with its private key.
+func sshConfig(box *provision.Box) (*ssh.ClientConfig, error) {
+	key, err := carton.GetSshKey(box.SSH.Prefix, box.ApiArgs)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(key.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+	user := box.SSH.User
+	if user == "" {
+		user = defaultSSHUser
+	}
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback(box),
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+// hostKeyCallback verifies the host key of the vm against the one pinned in
+// the outputs of its assembly. The vms are launched by us, so their host keys
+// aren't known beforehand: the key seen on the first ssh is the one pinned.
+func hostKeyCallback(box *provision.Box) func(string, net.Addr, ssh.PublicKey) error {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if strings.TrimSpace(box.SSH.HostKey) == "" {
+			return pinHostKey(box, key)
+		}
+		return verifyHostKey(box.SSH.HostKey, key)
+	}
+}
+
+func verifyHostKey(pinned string, key ssh.PublicKey) error {
+	want, _, _, _, err := ssh.ParseAuthorizedKey([]byte(pinned))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want.Marshal(), key.Marshal()) {
+		return ErrHostKeyMismatch
+	}
+	return nil
+}
+
+func pinHostKey(box *provision.Box, key ssh.PublicKey) error {
+	hostKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+	asm, err := carton.NewAssembly(box.CartonId, box.AccountId, "")
+	if err != nil {
+		return err
+	}
+	if err = asm.NukeAndSetOutputs(map[string][]string{carton.SSH_HOSTKEY: []string{hostKey}}); err != nil {
+		return err
+	}
+	log.Debugf("  ssh host key of %s pinned (%s)", box.GetFullName(), key.Type())
+	box.SSH.HostKey = hostKey
+	return nil
+}
+
+func sshDial(box *provision.Box) (*ssh.Client, error) {
+	if strings.TrimSpace(box.PublicIp) == "" {
+		return nil, ErrNoPublicIp
+	}
+	config, err := sshConfig(box)
+	if err != nil {
+		return nil, err
+	}
+	addr := net.JoinHostPort(box.PublicIp, sshPort)
+	log.Debugf("  ssh %s@%s", config.User, addr)
+	return ssh.Dial("tcp", addr, config)
+}
+
+// Shell opens an interactive login shell on the vm over ssh, wired to the
+// connection of the options.
+func (p *oneProvisioner) Shell(opts provision.ShellOptions) error {
+	client, err := sshDial(opts.Box)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	width, height, term := ptySize(opts)
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	if err = session.RequestPty(term, height, width, modes); err != nil {
+		return err
+	}
+	session.Stdin = opts.Conn
+	session.Stdout = opts.Conn
+	session.Stderr = opts.Conn
+	if err = session.Shell(); err != nil {
+		return err
+	}
+	return session.Wait()
+}
+
+func ptySize(opts provision.ShellOptions) (int, int, string) {
+	width, height, term := opts.Width, opts.Height, opts.Term
+	if width <= 0 {
+		width = defaultWidth
+	}
+	if height <= 0 {
+		height = defaultHeight
+	}
+	if term == "" {
+		term = defaultTerm
+	}
+	return width, height, term
+}
+
+// ExecuteCommandOnce runs the command on the vm over ssh.
+func (p *oneProvisioner) ExecuteCommandOnce(stdout, stderr io.Writer, box *provision.Box, cmd string, args ...string) error {
+	client, err := sshDial(box)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(shellCommand(cmd, args...))
+}
+
+//quotes the arguments, so they reach the remote shell as they are.
+func shellCommand(cmd string, args ...string) string {
+	parts := []string{cmd}
+	for _, a := range args {
+		parts = append(parts, "'"+strings.Replace(a, "'", `'\''`, -1)+"'")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,60 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+package one
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+
+	"github.com/megamsys/vertice/provision"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestShellCommandQuotesArgs(c *check.C) {
+	c.Assert(shellCommand("uptime"), check.Equals, "uptime")
+	c.Assert(shellCommand("ls", "-l", "/tmp/my dir"), check.Equals, "ls '-l' '/tmp/my dir'")
+	c.Assert(shellCommand("echo", "it's"), check.Equals, `echo 'it'\''s'`)
+}
+
+func (s *S) TestPtySizeDefaults(c *check.C) {
+	w, h, t := ptySize(provision.ShellOptions{})
+	c.Assert(w, check.Equals, defaultWidth)
+	c.Assert(h, check.Equals, defaultHeight)
+	c.Assert(t, check.Equals, defaultTerm)
+	w, h, t = ptySize(provision.ShellOptions{Width: 120, Height: 40, Term: "vt100"})
+	c.Assert(w, check.Equals, 120)
+	c.Assert(h, check.Equals, 40)
+	c.Assert(t, check.Equals, "vt100")
+}
+
+func (s *S) TestVerifyHostKey(c *check.C) {
+	pinned, other := newHostKey(c), newHostKey(c)
+	line := string(ssh.MarshalAuthorizedKey(pinned))
+	c.Assert(verifyHostKey(line, pinned), check.IsNil)
+	c.Assert(verifyHostKey(line, other), check.Equals, ErrHostKeyMismatch)
+	c.Assert(verifyHostKey("garbage", pinned), check.NotNil)
+}
+
+func newHostKey(c *check.C) ssh.PublicKey {
+	k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	c.Assert(err, check.IsNil)
+	pub, err := ssh.NewPublicKey(&k.PublicKey)
+	c.Assert(err, check.IsNil)
+	return pub
+}
@@ -18,6 +18,7 @@ package provision
 import (
 	"errors"
 	"fmt"
+	"github.com/megamsys/libgo/events/alerts"
 	"github.com/megamsys/libgo/utils"
 	"github.com/megamsys/vertice/carton/bind"
 	"io"
@@ -32,6 +33,12 @@ var (
 	ErrNotImplemented = errors.New("I'am on diet.")
 )
 
+// The libgo pinned in Godeps has no quota actions, these are numbered past
+// its own.
+const (
+	QUOTA_EXCEEDED alerts.EventAction = iota + 100
+)
+
 // Named is something that has a name, providing the GetName method.
 type Named interface {
 	GetName() string
@@ -149,7 +156,8 @@ type Provisioner interface {
 	// Returns the metric backend collected
 	MetricEnvs(int64, int64,string,io.Writer) ([]interface{}, error)
 
-	TriggerBills(string,string,string) error
+	// TriggerBills bills the compute of the box since its last billing.
+	TriggerBills(*Box) error
 }
 
 type MessageProvisioner interface {
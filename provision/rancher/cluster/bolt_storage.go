@@ -0,0 +1,225 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/megamsys/vertice/provision/boltstore"
+)
+
+const (
+	MEMORY_STORAGE = "memory"
+	BOLT_STORAGE   = "bolt"
+)
+
+var (
+	nodesBucket      = []byte("nodes")
+	containersBucket = []byte("containers")
+)
+
+// BoltStorage keeps the nodes and containers in a bolt file, so the
+// health of the nodes and the hosts of the containers outlive a restart.
+// The vertice opening the same file share them, see boltstore.
+type BoltStorage struct {
+	*boltstore.Store
+	holder string
+}
+
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	s, err := boltstore.Open(path, boltstore.DefaultTimeout, nodesBucket, containersBucket)
+	if err != nil {
+		return nil, err
+	}
+	return &BoltStorage{Store: s, holder: boltstore.Holder()}, nil
+}
+
+// the nodes are stored with their healing data, left out of their json.
+type storedNode Node
+
+func getNode(b *bolt.Bucket, address string) (Node, error) {
+	var n storedNode
+	if err := boltstore.Get(b, address, &n, ErrNoSuchNode); err != nil {
+		return Node{}, err
+	}
+	if n.Metadata == nil {
+		n.Metadata = make(map[string]string)
+	}
+	return Node(n), nil
+}
+
+func putNode(b *bolt.Bucket, node Node) error {
+	return boltstore.Put(b, node.Address, storedNode(node))
+}
+
+func (s *BoltStorage) StoreNode(node Node) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		if boltstore.Has(b, node.Address) {
+			return ErrDuplicatedNodeAddress
+		}
+		if node.Metadata == nil {
+			node.Metadata = make(map[string]string)
+		}
+		return putNode(b, node)
+	})
+}
+
+func (s *BoltStorage) RetrieveNodes() ([]Node, error) {
+	nodes := []Node{}
+	err := s.View(nodesBucket, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			n, err := getNode(b, string(k))
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, n)
+			return nil
+		})
+	})
+	return nodes, err
+}
+
+func (s *BoltStorage) RetrieveNode(address string) (Node, error) {
+	var node Node
+	err := s.View(nodesBucket, func(b *bolt.Bucket) error {
+		var err error
+		node, err = getNode(b, address)
+		return err
+	})
+	if err != nil {
+		return Node{}, err
+	}
+	return node, nil
+}
+
+func (s *BoltStorage) UpdateNode(node Node) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		if !boltstore.Has(b, node.Address) {
+			return ErrNoSuchNode
+		}
+		return putNode(b, node)
+	})
+}
+
+func (s *BoltStorage) RemoveNode(address string) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		return boltstore.Remove(b, []string{address}, ErrNoSuchNode)
+	})
+}
+func (s *BoltStorage) RetrieveNodesByMetadata(metadata map[string]string) ([]Node, error) {
+	nodes, err := s.RetrieveNodes()
+	if err != nil {
+		return nil, err
+	}
+	filteredNodes := []Node{}
+	for _, node := range nodes {
+		matches := true
+		for key, value := range metadata {
+			if node.Metadata[key] != value {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filteredNodes = append(filteredNodes, node)
+		}
+	}
+	return filteredNodes, nil
+}
+
+// LockNodeForHealing leases the node to this vertice till the timeout, the
+// lease of another ending at the timeout it took.
+func (s *BoltStorage) LockNodeForHealing(address string, isFailure bool, timeout time.Duration) (bool, error) {
+	locked := false
+	err := s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, address)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		if n.Healing.LockedUntil.After(now) {
+			return nil
+		}
+		n.Healing = HealingData{LockedUntil: now.Add(timeout), IsFailure: isFailure, Holder: s.holder}
+		locked = true
+		return putNode(b, n)
+	})
+	return locked && err == nil, err
+}
+
+func (s *BoltStorage) ExtendNodeLock(address string, timeout time.Duration) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, address)
+		if err != nil {
+			return err
+		}
+		if n.Healing.Holder != s.holder {
+			return boltstore.ErrLeaseLost
+		}
+		n.Healing.LockedUntil = time.Now().UTC().Add(timeout)
+		return putNode(b, n)
+	})
+}
+
+func (s *BoltStorage) UnlockNode(address string) error {
+	return s.Update(nodesBucket, func(b *bolt.Bucket) error {
+		n, err := getNode(b, address)
+		if err != nil {
+			return err
+		}
+		if n.Healing.Holder != s.holder {
+			return boltstore.ErrLeaseLost
+		}
+		n.Healing = HealingData{}
+		return putNode(b, n)
+	})
+}
+
+func (s *BoltStorage) StoreContainerByName(containerID, name string) error {
+	return s.Update(containersBucket, func(b *bolt.Bucket) error {
+		return b.Put([]byte(name), []byte(containerID))
+	})
+}
+
+func (s *BoltStorage) RetrieveContainerByName(name string) (string, error) {
+	return s.getContainer(name)
+}
+
+func (s *BoltStorage) StoreContainer(containerID, hostID string) error {
+	return s.Update(containersBucket, func(b *bolt.Bucket) error {
+		return b.Put([]byte(containerID), []byte(hostID))
+	})
+}
+
+func (s *BoltStorage) RetrieveContainer(containerID string) (string, error) {
+	return s.getContainer(containerID)
+}
+
+func (s *BoltStorage) getContainer(key string) (string, error) {
+	var value string
+	err := s.View(containersBucket, func(b *bolt.Bucket) error {
+		v := b.Get([]byte(key))
+		if v == nil {
+			return ErrNoSuchContainer
+		}
+		value = string(v)
+		return nil
+	})
+	return value, err
+}
+
+func (s *BoltStorage) RemoveContainer(containerID string) error {
+	return s.Update(containersBucket, func(b *bolt.Bucket) error {
+		return b.Delete([]byte(containerID))
+	})
+}
+
+func (s *BoltStorage) RetrieveContainers() ([]Container, error) {
+	entries := []Container{}
+	err := s.View(containersBucket, func(b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			entries = append(entries, Container{Id: string(k), Host: string(v)})
+			return nil
+		})
+	})
+	return entries, err
+}
@@ -10,6 +10,7 @@ import (
 	"time"
 
  "github.com/megamsys/go-rancher/v2"
+	"github.com/megamsys/vertice/provision/healer"
 )
 
 const (
@@ -98,13 +99,13 @@ type Storage interface {
 // provide methods for interaction with those nodes, like CreateContainer,
 // which creates a container in one node of the cluster.
 type Cluster struct {
-	Healer         Healer
-	stor           Storage
-	bridges        Bridges
-	gulp           Gulp
-	VNets          map[string]string
-	monitoringDone chan bool
-	Region         string
+	Healer  Healer
+	stor    Storage
+	bridges Bridges
+	gulp    Gulp
+	VNets   map[string]string
+	monitor *healer.Monitor
+	Region  string
 }
 
 type RancherNodeError struct {
@@ -162,6 +163,9 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	if len(nodes) > 0 {
 		for _, n := range nodes {
 			err = c.Register(n)
+			if err == ErrDuplicatedNodeAddress {
+				err = c.refresh(n)
+			}
 			if err != nil {
 				return &c, err
 			}
@@ -170,6 +174,16 @@ func New(storage Storage, nodes ...Node) (*Cluster, error) {
 	return &c, err
 }
 
+// refresh replaces a node stored before, by an earlier run or another
+// vertice, keeping its health.
+func (c *Cluster) refresh(node Node) error {
+	stored, err := c.storage().RetrieveNode(node.Address)
+	if err != nil {
+		return err
+	}
+	return c.storage().UpdateNode(node.withHealthOf(stored))
+}
+
 // Register adds new nodes to the cluster.
 func (c *Cluster) Register(node Node) error {
 	if node.Address == "" {
@@ -271,7 +285,9 @@ func (c *Cluster) handleNodeError(addr string, lastErr error, incrementFailures
 		if duration > 0 {
 			node.updateDisabled(time.Now().Add(duration))
 		}
-		c.storage().UpdateNode(node)
+		if err = c.storage().UpdateNode(node); err == nil && duration > 0 {
+			nodeDisabled(node, duration)
+		}
 	}()
 	return nil
 }
@@ -286,8 +302,35 @@ func (c *Cluster) handleNodeSuccess(addr string) error {
 	if err != nil {
 		return err
 	}
+	disabled := node.wasDisabled()
+	recovered := node
 	node.updateSuccess()
-	return c.storage().UpdateNode(node)
+	if err = c.storage().UpdateNode(node); err == nil && disabled {
+		nodeRecovered(recovered)
+	}
+	return err
+}
+
+// handleNodeProbed enables a disabled node answering its probe.
+func (c *Cluster) handleNodeProbed(addr string) error {
+	unlock, err := c.lockWithTimeout(addr, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	node, err := c.storage().RetrieveNode(addr)
+	if err != nil {
+		return err
+	}
+	if !node.wasDisabled() {
+		return nil
+	}
+	recovered := node
+	node.updateEnabled()
+	if err = c.storage().UpdateNode(node); err == nil {
+		nodeRecovered(recovered)
+	}
+	return err
 }
 
 func (c *Cluster) storage() Storage {
@@ -8,7 +8,14 @@
 
 package cluster
 
-import "time"
+import (
+	"strconv"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/go-rancher/v2"
+	"github.com/megamsys/vertice/provision/healer"
+)
 
 type Healer interface {
 	HandleError(node *Node) time.Duration
@@ -19,3 +26,79 @@ type DefaultHealer struct{}
 func (DefaultHealer) HandleError(node *Node) time.Duration {
 	return 1 * time.Minute
 }
+
+// ExponentialHealer disables a node by its failures, see healer.Exponential.
+type ExponentialHealer struct {
+	healer.Exponential
+}
+
+// NewExponentialHealer returns a healer, the defaults replacing the zero
+// values.
+func NewExponentialHealer(threshold int, backoff, maxBackoff time.Duration) ExponentialHealer {
+	return ExponentialHealer{healer.NewExponential(threshold, backoff, maxBackoff)}
+}
+
+func (h ExponentialHealer) HandleError(node *Node) time.Duration {
+	return h.Disable(node.FailureCount())
+}
+
+// StartActiveMonitoring probes the disabled nodes every interval, the ones
+// answering are enabled before their backoff ends.
+func (c *Cluster) StartActiveMonitoring(interval time.Duration) {
+	c.monitor = healer.StartMonitor(interval, c.probeDisabledNodes)
+}
+
+func (c *Cluster) StopActiveMonitoring() {
+	if c.monitor != nil {
+		c.monitor.Stop()
+	}
+}
+
+func (c *Cluster) probeDisabledNodes() {
+	nodes, err := c.UnfilteredNodes()
+	if err != nil {
+		log.Errorf("  > [healer] retrieving the nodes: %s", err)
+		return
+	}
+	probes := make(map[string]func() error)
+	for _, n := range nodes {
+		if !n.wasDisabled() || n.isEnabled() || n.isHealing() {
+			continue
+		}
+		nodeo := n
+		probes[n.Address] = func() error { return c.probeNode(nodeo) }
+	}
+	healer.Probe(probes, func(address string) { c.handleNodeProbed(address) })
+}
+
+// probeNode lists the hosts of the rancher of a node.
+func (c *Cluster) probeNode(nodeo Node) error {
+	n, err := c.getNodeByAddr(client.ClientOpts{
+		Url:       nodeo.Address,
+		AccountId: nodeo.Metadata[ADMIN_ID],
+		AccessKey: nodeo.Metadata[ACCESSKEY],
+		SecretKey: nodeo.Metadata[SECRETKEY],
+	})
+	if err != nil {
+		return err
+	}
+	_, err = n.RancherClient.Host.List(&client.ListOpts{})
+	return err
+}
+
+func nodeDisabled(node Node, duration time.Duration) error {
+	return healer.NodeDisabled(nodeEventData(node), duration)
+}
+
+func nodeRecovered(node Node) error {
+	return healer.NodeRecovered(nodeEventData(node))
+}
+
+func nodeEventData(node Node) map[string]string {
+	mi := make(map[string]string)
+	mi["address"] = node.Address
+	mi["region"] = node.Metadata[RANCHER_ZONE]
+	mi["failures"] = strconv.Itoa(node.FailureCount())
+	mi["last_error"] = node.Metadata["LastError"]
+	return mi
+}
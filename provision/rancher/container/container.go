@@ -2,7 +2,7 @@ package container
 
 import (
 	//"fmt"
-	//"io"
+	"io"
 	"net"
 	"net/url"
 	"time"
@@ -13,10 +13,9 @@ import (
 	"github.com/megamsys/go-rancher/v2"
 	"github.com/megamsys/libgo/utils"
 	constants "github.com/megamsys/libgo/utils"
-	//"github.com/megamsys/libgo/events"
-	//	"github.com/megamsys/libgo/events/alerts"
 	"github.com/megamsys/libgo/safe"
 	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/provision"
 	"github.com/megamsys/vertice/provision/rancher/cluster"
 )
@@ -147,6 +146,30 @@ func (c *Container) SetMileStone(state utils.State) error {
 	return nil
 }
 
+// CheckCredits reserves the estimated cost of the box for a billing period
+// against the credit balance of the user. units has the default units of the
+// region and its costs per hour, used when the assembly has none.
+func (c *Container) CheckCredits(b *provision.Box, units map[string]string, w io.Writer) error {
+	asm, err := carton.NewAssembly(c.CartonId, c.AccountId, "")
+	if err != nil {
+		return err
+	}
+	costs := metrix.ContainerCosts(asm, units)
+	estimate := metrix.EstimateCost(b, costs.Cpu, costs.Memory, "", units)
+	return carton.ReserveCredits(b, estimate, w)
+}
+
+// Deduct bills the compute of the box for the time elapsed since the
+// assembly was billed last. units has the default units of the region and
+// its costs per hour, used when the assembly has none.
+func (c *Container) Deduct(b *provision.Box, units map[string]string) error {
+	asm, err := carton.NewAssembly(c.CartonId, c.AccountId, "")
+	if err != nil {
+		return err
+	}
+	return metrix.Deduct(asm, c.Name, b, units, metrix.ContainerCosts(asm, units))
+}
+
 func (c *Container) UpdateContId() error {
 	log.Debugf("  update Id[%s] of container (%s) in cassandra", c.Id, c.Name)
 
@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"strings"
+	"sync"
+)
+
+// Counter is a value that only goes up, per set of label values.
+type Counter struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// NewCounter returns a counter with the label names, registered for exposition.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+	Register(c)
+	return c
+}
+
+// Inc adds one to the counter of the label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds v (a positive value) to the counter of the label values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	k := strings.Join(values, "\xff")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cv, ok := c.values[k]
+	if !ok {
+		cv = &counterValue{labels: values}
+		c.values[k] = cv
+	}
+	cv.value += v
+}
+
+// Value returns the counter of the label values.
+func (c *Counter) Value(values ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cv, ok := c.values[strings.Join(values, "\xff")]; ok {
+		return cv.value
+	}
+	return 0
+}
+
+func (c *Counter) Collect() []*Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := &Family{Name: c.name, Help: c.help, Type: COUNTER}
+	for _, k := range sortedKeys(c.values) {
+		cv := c.values[k]
+		f.Samples = append(f.Samples, Sample{Labels: Labels(c.labels, cv.labels), Value: cv.value})
+	}
+	return []*Family{f}
+}
@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets suit latencies in seconds, from a request to a provisioning.
+var DefaultBuckets = []float64{.1, .5, 1, 5, 10, 30, 60, 120, 300, 600, 1800}
+
+// Histogram counts the observations in buckets, per set of label values.
+type Histogram struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram returns a histogram with the upper bounds of buckets (the
+// default ones when empty), registered for exposition.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	h := &Histogram{name: name, help: help, labels: labels, buckets: b, values: make(map[string]*histogramValue)}
+	Register(h)
+	return h
+}
+
+// Observe adds v to the histogram of the label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	k := strings.Join(values, "\xff")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hv, ok := h.values[k]
+	if !ok {
+		hv = &histogramValue{labels: values, counts: make([]uint64, len(h.buckets))}
+		h.values[k] = hv
+	}
+	for i, ub := range h.buckets {
+		if v <= ub {
+			hv.counts[i]++
+		}
+	}
+	hv.count++
+	hv.sum += v
+}
+
+func (h *Histogram) Collect() []*Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	f := &Family{Name: h.name, Help: h.help, Type: HISTOGRAM}
+	for _, k := range sortedKeys(h.values) {
+		hv := h.values[k]
+		ls := Labels(h.labels, hv.labels)
+		for i, ub := range h.buckets {
+			f.Samples = append(f.Samples, Sample{Suffix: "_bucket", Labels: withLe(ls, ub), Value: float64(hv.counts[i])})
+		}
+		f.Samples = append(f.Samples,
+			Sample{Suffix: "_bucket", Labels: withLe(ls, math.Inf(1)), Value: float64(hv.count)},
+			Sample{Suffix: "_sum", Labels: ls, Value: hv.sum},
+			Sample{Suffix: "_count", Labels: ls, Value: float64(hv.count)})
+	}
+	return []*Family{f}
+}
+
+func withLe(ls []Label, ub float64) []Label {
+	le := "+Inf"
+	if !math.IsInf(ub, 1) {
+		le = strconv.FormatFloat(ub, 'g', -1, 64)
+	}
+	return append(append([]Label(nil), ls...), Label{Name: "le", Value: le})
+}
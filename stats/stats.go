@@ -0,0 +1,185 @@
+/*
+** Copyright [2013-2016] [Megam Systems]
+**
+** Licensed under the Apache License, Version 2.0 (the "License");
+** you may not use this file except in compliance with the License.
+** You may obtain a copy of the License at
+**
+** http://www.apache.org/licenses/LICENSE-2.0
+**
+** Unless required by applicable law or agreed to in writing, software
+** distributed under the License is distributed on an "AS IS" BASIS,
+** WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+** See the License for the specific language governing permissions and
+** limitations under the License.
+ */
+
+// Package stats keeps the internal counters of vertice and exposes them,
+// along with the ones of the registered collectors, in the Prometheus text
+// format.
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	COUNTER   = "counter"
+	GAUGE     = "gauge"
+	HISTOGRAM = "histogram"
+
+	// ContentType is the content type of the Prometheus text format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// Label is a dimension of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a value of a family, Suffix is appended to the name of the family
+// (_bucket, _sum, _count of the histograms).
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a named metric and its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Labels pairs the label names with their values.
+func Labels(names, values []string) []Label {
+	ls := make([]Label, 0, len(names))
+	for i, n := range names {
+		var v string
+		if i < len(values) {
+			v = values[i]
+		}
+		ls = append(ls, Label{Name: n, Value: v})
+	}
+	return ls
+}
+
+// Collector returns the families it exposes.
+type Collector interface {
+	Collect() []*Family
+}
+
+// CollectorFunc adapts a function to a Collector.
+type CollectorFunc func() []*Family
+
+func (f CollectorFunc) Collect() []*Family {
+	return f()
+}
+
+type registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+var defaultRegistry = &registry{}
+
+// Register adds a collector to the exposed ones.
+func Register(c Collector) {
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	defaultRegistry.collectors = append(defaultRegistry.collectors, c)
+}
+
+// Gather returns the families of the registered collectors sorted by name.
+func Gather() []*Family {
+	defaultRegistry.mu.RLock()
+	defer defaultRegistry.mu.RUnlock()
+	var fs []*Family
+	for _, c := range defaultRegistry.collectors {
+		fs = append(fs, c.Collect()...)
+	}
+	sort.Sort(byName(fs))
+	return fs
+}
+
+type byName []*Family
+
+func (l byName) Len() int           { return len(l) }
+func (l byName) Swap(a, b int)      { l[a], l[b] = l[b], l[a] }
+func (l byName) Less(a, b int) bool { return l[a].Name < l[b].Name }
+
+// WritePrometheus writes the registered families in the Prometheus text format.
+func WritePrometheus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range Gather() {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *Family) write(w *bufio.Writer) {
+	if f.Help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.Name, f.Type)
+	for _, s := range f.Samples {
+		w.WriteString(f.Name + s.Suffix)
+		if len(s.Labels) > 0 {
+			w.WriteByte('{')
+			for i, l := range s.Labels {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				w.WriteString(l.Name + `="` + escapeLabel(l.Value) + `"`)
+			}
+			w.WriteByte('}')
+		}
+		w.WriteString(" " + formatValue(s.Value) + "\n")
+	}
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m interface{}) []string {
+	var ks []string
+	switch v := m.(type) {
+	case map[string]*counterValue:
+		for k := range v {
+			ks = append(ks, k)
+		}
+	case map[string]*histogramValue:
+		for k := range v {
+			ks = append(ks, k)
+		}
+	}
+	sort.Strings(ks)
+	return ks
+}
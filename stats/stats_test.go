@@ -0,0 +1,39 @@
+package stats
+
+import (
+	"bytes"
+	"strings"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestCounterWritesPrometheus(c *check.C) {
+	ct := NewCounter("test_messages_total", "Messages \"received\".", "topic")
+	ct.Inc("vms")
+	ct.Add(2, "vms")
+	ct.Inc("con\"tainers")
+	c.Assert(ct.Value("vms"), check.Equals, 3.0)
+	var b bytes.Buffer
+	c.Assert(WritePrometheus(&b), check.IsNil)
+	c.Assert(strings.Contains(b.String(), `# HELP test_messages_total Messages "received".
+# TYPE test_messages_total counter
+test_messages_total{topic="con\"tainers"} 1
+test_messages_total{topic="vms"} 3
+`), check.Equals, true)
+}
+
+func (s *S) TestHistogramBuckets(c *check.C) {
+	h := NewHistogram("test_latency_seconds", "", []float64{5, 1}, "action")
+	h.Observe(0.5, "create")
+	h.Observe(3, "create")
+	fs := h.Collect()
+	c.Assert(fs, check.HasLen, 1)
+	c.Assert(fs[0].Samples, check.HasLen, 5)
+	c.Assert(fs[0].Samples[0].Labels, check.DeepEquals, []Label{{"action", "create"}, {"le", "1"}})
+	c.Assert(fs[0].Samples[0].Value, check.Equals, 1.0)
+	c.Assert(fs[0].Samples[1].Value, check.Equals, 2.0)
+	c.Assert(fs[0].Samples[2].Labels[1].Value, check.Equals, "+Inf")
+	c.Assert(fs[0].Samples[3].Suffix, check.Equals, "_sum")
+	c.Assert(fs[0].Samples[3].Value, check.Equals, 3.5)
+	c.Assert(fs[0].Samples[4].Value, check.Equals, 2.0)
+}
@@ -0,0 +1,15 @@
+package stats
+
+import (
+	"testing"
+
+	"gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) {
+	check.TestingT(t)
+}
+
+type S struct{}
+
+var _ = check.Suite(&S{})
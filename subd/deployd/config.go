@@ -131,6 +131,14 @@ func (c Config) String() string {
 	return strings.TrimSpace(b.String())
 }
 
+// Validate returns an error when the regions of one are misconfigured.
+func (c Config) Validate() error {
+	if !c.One.Enabled {
+		return nil
+	}
+	return c.One.Validate()
+}
+
 //convert the config to just an interface.
 func (c Config) toInterface() interface{} {
 	return c.One
@@ -2,7 +2,7 @@ package deployd
 
 import (
 	"fmt"
-	"sync"
+	"path/filepath"
 
 	log "github.com/Sirupsen/logrus"
 	nsq "github.com/crackcomm/nsqueue/consumer"
@@ -21,10 +21,10 @@ const (
 
 // Service manages the listener and handler for an HTTP endpoint.
 type Service struct {
-	wg       sync.WaitGroup
 	err      chan error
 	Handler  *Handler
 	Consumer *nsq.Consumer
+	Requests *carton.ReqConsumer
 	Meta     *meta.Config
 	Deployd  *Config
 }
@@ -37,15 +37,22 @@ func NewService(c *meta.Config, d *Config) *Service {
 		Deployd: d,
 	}
 	s.Handler = NewHandler(s.Deployd)
+	s.Requests = carton.NewReqConsumer(TOPIC, s.Handler.serveNSQ)
 	//c.MkGlobal() //a setter for global meta config
 	return s
 }
 
 // Open starts the service
 func (s *Service) Open() error {
+	if err := carton.Ledger.Open(filepath.Join(s.Meta.Dir, carton.DefaultLedgerJournal)); err != nil {
+		return err
+	}
+	if err := carton.Reservations.Open(filepath.Join(s.Meta.Dir, carton.DefaultReservationsJournal)); err != nil {
+		return err
+	}
 	go func() error {
 		log.Info("starting deployd service")
-		if err := nsq.Register(TOPIC, "engine", maxInFlight, s.processNSQ); err != nil {
+		if err := nsq.Register(TOPIC, "engine", maxInFlight, s.Requests.Process); err != nil {
 			return err
 		}
 		if err := nsq.Connect(s.Meta.NSQd...); err != nil {
@@ -63,28 +70,13 @@ func (s *Service) Open() error {
 	return nil
 }
 
-func (s *Service) processNSQ(msg *nsq.Message) {
-	log.Debugf(TOPIC + "queue received message  :" + string(msg.Body))
-	p, err := carton.NewPayload(msg.Body)
-	if err != nil {
-		return
-	}
-	re, err := p.Convert()
-	if err != nil {
-		log.Errorf("%s",err)
-		return
-	}
-	go s.Handler.serveNSQ(re)
-	return
-}
-
 // Close closes the underlying subscribe channel.
 func (s *Service) Close() error {
 	if s.Consumer != nil {
 		s.Consumer.Stop()
 	}
 
-	s.wg.Wait()
+	s.Requests.Wait()
 	return nil
 }
 
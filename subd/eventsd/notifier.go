@@ -0,0 +1,47 @@
+package eventsd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/megamsys/vertice/provision/healer"
+)
+
+// Modified by tests
+var slackPostMessage = "https://slack.com/api/chat.postMessage"
+
+// SlackNotifier posts the node events of the healer to the slack channel.
+type SlackNotifier struct {
+	Slack  Slack
+	client *http.Client
+}
+
+func NewSlackNotifier(s Slack) *SlackNotifier {
+	return &SlackNotifier{Slack: s, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (n *SlackNotifier) Notify(action string, mi map[string]string) error {
+	res, err := n.client.PostForm(slackPostMessage, url.Values{
+		"token":   {n.Slack.Token},
+		"channel": {n.Slack.Channel},
+		"text":    {fmt.Sprintf("vertice %s: %s", action, healer.Describe(mi))},
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	var posted struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err = json.NewDecoder(res.Body).Decode(&posted); err != nil {
+		return err
+	}
+	if !posted.Ok {
+		return fmt.Errorf("slack %s: %s", action, posted.Error)
+	}
+	return nil
+}
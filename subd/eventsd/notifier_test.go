@@ -0,0 +1,42 @@
+package eventsd
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSlackNotifierPostsTheNodeEvent(c *check.C) {
+	var form map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = map[string]string{"token": r.Form.Get("token"), "channel": r.Form.Get("channel"), "text": r.Form.Get("text")}
+		w.Write([]byte(`{"ok": true}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { slackPostMessage = u }(slackPostMessage)
+	slackPostMessage = srv.URL
+
+	n := NewSlackNotifier(Slack{Enabled: true, Token: "temp", Channel: "ahoy"})
+	err := n.Notify("node_disabled", map[string]string{"address": "http://one:2633/RPC2", "region": "chennai"})
+	c.Assert(err, check.IsNil)
+	c.Assert(form, check.DeepEquals, map[string]string{
+		"token":   "temp",
+		"channel": "ahoy",
+		"text":    "vertice node_disabled: address=http://one:2633/RPC2 region=chennai",
+	})
+}
+
+func (s *S) TestSlackNotifierFailsWhenNotPosted(c *check.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok": false, "error": "channel_not_found"}`))
+	}))
+	defer srv.Close()
+	defer func(u string) { slackPostMessage = u }(slackPostMessage)
+	slackPostMessage = srv.URL
+
+	n := NewSlackNotifier(Slack{Enabled: true, Token: "temp", Channel: "ahoy"})
+	err := n.Notify("node_recovered", map[string]string{"address": "http://one:2633/RPC2"})
+	c.Assert(err, check.ErrorMatches, "slack node_recovered: channel_not_found")
+}
@@ -4,6 +4,8 @@ import (
 	"sync"
 	log "github.com/Sirupsen/logrus"
 	nsq "github.com/crackcomm/nsqueue/consumer"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/provision/healer"
 	"github.com/megamsys/vertice/subd/deployd"
 	"github.com/megamsys/libgo/events"
 	"github.com/megamsys/vertice/meta"
@@ -41,6 +43,9 @@ func (s *Service) Open() error {
 	if err := s.setEventsWrap(s.Eventsd); err != nil {
 		return err
 	}
+	if s.Eventsd.Slack.Enabled {
+		healer.SetNotifier(NewSlackNotifier(s.Eventsd.Slack))
+	}
 	go func() error {
 		log.Info("starting eventsd service")
 		if err := nsq.Register(TOPIC, "engine", maxInFlight, s.processNSQ); err != nil {
@@ -60,6 +65,7 @@ func (s *Service) Open() error {
 
 func (s *Service) processNSQ(msg *nsq.Message) {
 	log.Debugf(TOPIC + "queue received message  :" + string(msg.Body))
+	carton.MessagesReceived.Inc(TOPIC)
 	pe, err := events.NewParseEvent(msg.Body)
 	if err != nil {
 		return
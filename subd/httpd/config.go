@@ -8,14 +8,20 @@ import (
 	"text/tabwriter"
 
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/auth"
+	"github.com/megamsys/vertice/toml"
 )
 
 type Config struct {
-	Enabled     bool   `toml:"enabled"`
-	BindAddress string `toml:"bind_address"`
-	UseTls      bool   `toml:"use_tls"`
-	CertFile    string `toml:"cert_file"`
-	KeyFile     string `toml:"key_file"`
+	Enabled      bool          `toml:"enabled"`
+	BindAddress  string        `toml:"bind_address"`
+	UseTls       bool          `toml:"use_tls"`
+	CertFile     string        `toml:"cert_file"`
+	KeyFile      string        `toml:"key_file"`
+	TokenTTL     toml.Duration `toml:"token_ttl"`
+	TokenKey     string        `toml:"token_key"`
+	UIHosts      []string      `toml:"ui_hosts"`
+	MetricsToken string        `toml:"metrics_token"`
 }
 
 func (c Config) String() string {
@@ -27,6 +33,8 @@ func (c Config) String() string {
 	b.Write([]byte("enabled     " + "\t" + strconv.FormatBool(c.Enabled) + "\n"))
 	b.Write([]byte("bind_address" + "\t" + c.BindAddress + "\n"))
 	b.Write([]byte("usetls      " + "\t" + strconv.FormatBool(c.UseTls) + "\n"))
+	b.Write([]byte("token_ttl   " + "\t" + c.TokenTTL.String() + "\n"))
+	b.Write([]byte("ui_hosts    " + "\t" + strings.Join(c.UIHosts, ",") + "\n"))
 	b.Write([]byte("---\n"))
 	fmt.Fprintln(w)
 	w.Flush()
@@ -38,5 +46,6 @@ func NewConfig() *Config {
 		Enabled:     true,
 		BindAddress: "localhost:7777",
 		UseTls:      false,
+		TokenTTL:    toml.Duration(auth.DefaultTokenTTL),
 	}
 }
@@ -2,6 +2,7 @@ package httpd
 
 import (
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"gopkg.in/check.v1"
@@ -25,10 +26,16 @@ func (s *S) TestHttpdConfig_Parse(c *check.C) {
 enabled = true
 bind_address = ":8080"
 use_tls =  false
+token_ttl = "2h"
+token_key = "s3cr3t"
+ui_hosts = ["console.megam.io"]
 `, &cm); err != nil {
 		c.Fatal(err)
 	}
 
 	c.Assert(cm.BindAddress, check.Equals, ":8080")
 	c.Assert(cm.UseTls, check.Equals, false)
+	c.Assert(time.Duration(cm.TokenTTL), check.Equals, 2*time.Hour)
+	c.Assert(cm.TokenKey, check.Equals, "s3cr3t")
+	c.Assert(cm.UIHosts, check.DeepEquals, []string{"console.megam.io"})
 }
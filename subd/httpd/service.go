@@ -11,6 +11,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/negroni"
 	"github.com/megamsys/vertice/api"
+	"github.com/megamsys/vertice/auth"
 	"github.com/megamsys/vertice/subd/httpd/shutdown"
 	"gopkg.in/tylerb/graceful.v1"
 )
@@ -29,6 +30,17 @@ type Service struct {
 
 // NewService returns a new instance of Service.
 func NewService(c *Config) *Service {
+	auth.SetBackend(auth.NewGatewayBackend())
+	if c.TokenTTL > 0 {
+		auth.TokenTTL = time.Duration(c.TokenTTL)
+	}
+	if c.TokenKey != "" {
+		auth.TokenKey = []byte(c.TokenKey)
+	} else {
+		log.Warnf("httpd has no token_key, its tokens are valid till it restarts")
+	}
+	api.UIHosts = c.UIHosts
+	api.MetricsToken = c.MetricsToken
 	s := &Service{
 		addr:     c.BindAddress,
 		tls:      c.UseTls,
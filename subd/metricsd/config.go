@@ -9,22 +9,41 @@ import (
 	"time"
 
 	"github.com/megamsys/libgo/cmd"
+	"github.com/megamsys/vertice/carton"
+	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/toml"
 )
 
 const (
 	DefaultCollectInterval = 10 * time.Minute
+
+	// DefaultCollectorTimeout is the time a collector is given to collect.
+	DefaultCollectorTimeout = 5 * time.Minute
+
+	// DefaultBillingLedger is the journal of the billed periods, in the meta dir.
+	DefaultBillingLedger = "billing.ledger"
 )
 
 type Config struct {
-	Enabled         bool          `toml:"enabled"`
-	CollectInterval toml.Duration `toml:"collect_interval"`
+	Enabled          bool                 `toml:"enabled"`
+	CollectInterval  toml.Duration        `toml:"collect_interval"`
+	CollectorTimeout toml.Duration        `toml:"collector_timeout"`
+	RatePlans        []metrix.RatePlan    `toml:"rate_plan"`
+	BillingLedger    string               `toml:"billing_ledger"`
+	MaxBackfill      toml.Duration        `toml:"max_backfill"`
+	AutoSuspend      bool                 `toml:"auto_suspend"`
+	SuspendGrace     toml.Duration        `toml:"suspend_grace"`
+	Outputs          metrix.OutputsConfig `toml:"outputs"`
 }
 
 func NewConfig() *Config {
 	return &Config{
-		Enabled:         false,
-		CollectInterval: toml.Duration(DefaultCollectInterval),
+		Enabled:          false,
+		CollectInterval:  toml.Duration(DefaultCollectInterval),
+		CollectorTimeout: toml.Duration(DefaultCollectorTimeout),
+		MaxBackfill:      toml.Duration(metrix.DefaultMaxBackfill),
+		SuspendGrace:     toml.Duration(carton.DefaultSuspendGrace),
+		Outputs:          metrix.OutputsConfig{Scylla: true},
 	}
 }
 
@@ -36,6 +55,15 @@ func (c Config) String() string {
 		cmd.Colorfy("Metricsd", "cyan", "", "") + "\n"))
 	b.Write([]byte("enabled" + "\t" + strconv.FormatBool(c.Enabled) + "\n"))
 	b.Write([]byte("collect_interval" + "\t" + c.CollectInterval.String() + "\n"))
+	b.Write([]byte("collector_timeout" + "\t" + c.CollectorTimeout.String() + "\n"))
+	b.Write([]byte("billing_ledger" + "\t" + c.BillingLedger + "\n"))
+	b.Write([]byte("max_backfill" + "\t" + c.MaxBackfill.String() + "\n"))
+	b.Write([]byte("auto_suspend" + "\t" + strconv.FormatBool(c.AutoSuspend) + "\n"))
+	b.Write([]byte("suspend_grace" + "\t" + c.SuspendGrace.String() + "\n"))
+	b.Write([]byte("outputs" + "\t" + strings.Join(c.Outputs.Enabled(), ", ") + "\n"))
+	for _, p := range c.RatePlans {
+		b.Write([]byte("rate_plan" + "\t" + p.Name + " (" + p.Metric + " " + p.Type + " " + p.Region + ")\n"))
+	}
 	b.Write([]byte("---\n"))
 	fmt.Fprintln(w)
 	w.Flush()
@@ -14,11 +14,54 @@ func (s *S) TestMetrics_Parse(c *check.C) {
 	if _, err := toml.Decode(`
 		enabled = false
 		collect_interval  = "10m"
+		collector_timeout = "3m"
+		billing_ledger = "/var/lib/megam/vertice/billing.ledger"
+		max_backfill = "6h"
+		auto_suspend = true
+		suspend_grace = "12h"
+
+		[outputs]
+		scylla = false
+		[outputs.influxdb]
+		enabled = true
+		url = "http://localhost:8086"
+		database = "vertice"
+		batch_size = 1000
+		retry_backoff = "2s"
+		[outputs.file]
+		enabled = true
+		path = "/var/log/megam/vertice/metrics.json"
+		max_size = 50
+
+		[[rate_plan]]
+		name = "vm-ram"
+		metric = "memory_cost"
+		region = "chennai"
+		type = "tiered"
+		free_units = 1.0
+		[[rate_plan.tier]]
+		up_to = 4.0
+		rate = 0.02
+		[[rate_plan.tier]]
+		rate = 0.01
 `, &cm); err != nil {
 		c.Fatal(err)
 	}
+	c.Assert(cm.RatePlans, check.HasLen, 1)
+	c.Assert(cm.RatePlans[0].Type, check.Equals, "tiered")
+	c.Assert(cm.RatePlans[0].Tiers, check.HasLen, 2)
+	c.Assert(cm.RatePlans[0].Tiers[0].UpTo, check.Equals, 4.0)
 
+	c.Assert(cm.Outputs.Enabled(), check.DeepEquals, []string{"influxdb", "file"})
+	c.Assert(cm.Outputs.InfluxDB.BatchSize, check.Equals, 1000)
+	c.Assert(time.Duration(cm.Outputs.InfluxDB.RetryBackoff), check.Equals, 2*time.Second)
+	c.Assert(cm.Outputs.File.MaxSize, check.Equals, 50)
+	c.Assert(cm.BillingLedger, check.Equals, "/var/lib/megam/vertice/billing.ledger")
+	c.Assert(time.Duration(cm.MaxBackfill), check.Equals, 6*time.Hour)
+	c.Assert(cm.AutoSuspend, check.Equals, true)
+	c.Assert(time.Duration(cm.SuspendGrace), check.Equals, 12*time.Hour)
 	c.Assert(time.Duration(cm.CollectInterval), check.Equals, 10*time.Minute)
+	c.Assert(time.Duration(cm.CollectorTimeout), check.Equals, 3*time.Minute)
 	c.Assert(cm.Enabled, check.Equals, false)
 
 }
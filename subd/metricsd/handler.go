@@ -12,10 +12,15 @@ func NewHandler() *Handler {
 	return &Handler{}
 }
 
+// processCollector collects the sensors and writes them, unless the
+// collection expired before billing. Billed sensors are always written.
 func (h *Handler) processCollector(mh *metrix.MetricHandler,
-	output *metrix.OutputHandler, c metrix.MetricCollector) error {
+	output *metrix.OutputHandler, c metrix.MetricCollector, expired <-chan struct{}) error {
 
-	all, err := mh.Collect(c)
+	all, err := mh.Collect(c, expired)
+	if err == metrix.ErrExpired {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,112 @@
+package metricsd
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/stats"
+)
+
+var (
+	collectorOverruns = stats.NewCounter("vertice_metrics_collector_overruns_total", "Collections skipped as the previous one was still running.", "collector")
+	collectorTimeouts = stats.NewCounter("vertice_metrics_collector_timeouts_total", "Collections abandoned after the collector timeout.", "collector")
+	collectorDuration = stats.NewHistogram("vertice_metrics_collector_duration_seconds", "Time taken by a collection.", nil, "collector")
+)
+
+// job collects once, expired is closed when the collector timeout is over
+// and the job must not bill anything anymore.
+type job func(expired <-chan struct{}) error
+
+// scheduler runs a job per collector at most once at a time. A job still
+// running when the collector is due again is an overrun, skipped. A job past
+// its timeout keeps its collector till it returns, it may still be billing.
+type scheduler struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	running map[string]time.Time
+	ended   chan struct{} //closed and renewed when a job returns.
+}
+
+func newScheduler(timeout time.Duration) *scheduler {
+	return &scheduler{
+		timeout: timeout,
+		running: make(map[string]time.Time),
+		ended:   make(chan struct{}),
+	}
+}
+
+// Run starts the job of the collector, it returns false when the previous
+// job of the collector is still running.
+func (s *scheduler) Run(collector string, j job) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if started, ok := s.running[collector]; ok {
+		log.Warnf("  metrics collector %s overrun, running since %s", collector, started.Format(time.RFC3339))
+		collectorOverruns.Inc(collector)
+		return false
+	}
+	s.running[collector] = time.Now()
+	go s.run(collector, j)
+	return true
+}
+
+func (s *scheduler) run(collector string, j job) {
+	started := time.Now()
+	defer func() {
+		collectorDuration.Observe(time.Since(started).Seconds(), collector)
+		s.mu.Lock()
+		delete(s.running, collector)
+		close(s.ended)
+		s.ended = make(chan struct{})
+		s.mu.Unlock()
+	}()
+
+	expired := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- j(expired) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(s.timeout):
+		close(expired)
+		log.Errorf("  metrics collector %s timed out after %s", collector, s.timeout)
+		collectorTimeouts.Inc(collector)
+		err = <-done
+	}
+	if err != nil {
+		log.Errorf("  metrics collector %s failed : %s", collector, err)
+	}
+}
+
+// Running returns the collectors running.
+func (s *scheduler) Running() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.running))
+	for name := range s.running {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Wait waits for the running jobs, it returns false when some are still
+// running after grace.
+func (s *scheduler) Wait(grace time.Duration) bool {
+	timeout := time.After(grace)
+	for {
+		s.mu.Lock()
+		running, ended := len(s.running), s.ended
+		s.mu.Unlock()
+		if running == 0 {
+			return true
+		}
+		select {
+		case <-ended:
+		case <-timeout:
+			return false
+		}
+	}
+}
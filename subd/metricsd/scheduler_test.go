@@ -0,0 +1,59 @@
+package metricsd
+
+import (
+	"time"
+
+	"gopkg.in/check.v1"
+)
+
+func (s *S) TestSchedulerSkipsOverruns(c *check.C) {
+	sc := newScheduler(time.Minute)
+	overruns := collectorOverruns.Value("one/chennai")
+	release := make(chan struct{})
+	c.Assert(sc.Run("one/chennai", func(<-chan struct{}) error { <-release; return nil }), check.Equals, true)
+	c.Assert(sc.Run("one/chennai", func(<-chan struct{}) error { return nil }), check.Equals, false)
+	c.Assert(sc.Run("one/paris", func(<-chan struct{}) error { return nil }), check.Equals, true)
+	c.Assert(collectorOverruns.Value("one/chennai"), check.Equals, overruns+1)
+	close(release)
+	c.Assert(sc.Wait(time.Second), check.Equals, true)
+	c.Assert(sc.Running(), check.HasLen, 0)
+	c.Assert(sc.Run("one/chennai", func(<-chan struct{}) error { return nil }), check.Equals, true)
+	c.Assert(sc.Wait(time.Second), check.Equals, true)
+}
+
+func (s *S) TestSchedulerExpiresSlowCollectors(c *check.C) {
+	sc := newScheduler(10 * time.Millisecond)
+	timeouts := collectorTimeouts.Value("docker/chennai")
+	wrote := make(chan bool, 1)
+	sc.Run("docker/chennai", func(expired <-chan struct{}) error {
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-expired:
+			wrote <- false
+		default:
+			wrote <- true
+		}
+		return nil
+	})
+	c.Assert(sc.Run("docker/chennai", func(<-chan struct{}) error { return nil }), check.Equals, false)
+	c.Assert(sc.Wait(time.Second), check.Equals, true)
+	c.Assert(<-wrote, check.Equals, false)
+	c.Assert(collectorTimeouts.Value("docker/chennai"), check.Equals, timeouts+1)
+}
+
+func (s *S) TestSchedulerKeepsHungCollectors(c *check.C) {
+	sc := newScheduler(10 * time.Millisecond)
+	timeouts := collectorTimeouts.Value("one/paris")
+	overruns := collectorOverruns.Value("one/paris")
+	hung := make(chan struct{})
+	c.Assert(sc.Run("one/paris", func(<-chan struct{}) error { <-hung; return nil }), check.Equals, true)
+	c.Assert(sc.Wait(50*time.Millisecond), check.Equals, false)
+	c.Assert(collectorTimeouts.Value("one/paris"), check.Equals, timeouts+1)
+	c.Assert(sc.Running(), check.DeepEquals, []string{"one/paris"})
+	c.Assert(sc.Run("one/paris", func(<-chan struct{}) error { return nil }), check.Equals, false)
+	c.Assert(collectorOverruns.Value("one/paris"), check.Equals, overruns+1)
+	close(hung)
+	c.Assert(sc.Wait(time.Second), check.Equals, true)
+	c.Assert(sc.Run("one/paris", func(<-chan struct{}) error { return nil }), check.Equals, true)
+	c.Assert(sc.Wait(time.Second), check.Equals, true)
+}
@@ -2,38 +2,46 @@ package metricsd
 
 import (
 	log "github.com/Sirupsen/logrus"
+	"github.com/megamsys/vertice/carton"
 	"github.com/megamsys/vertice/meta"
 	"github.com/megamsys/vertice/metrix"
 	"github.com/megamsys/vertice/storage"
 	"github.com/megamsys/vertice/snapshots"
 	"github.com/megamsys/vertice/subd/deployd"
 	"github.com/megamsys/vertice/subd/docker"
+	"path/filepath"
 	"time"
 
 )
 
+// SUSPENDER is the job suspending the accounts with a negative balance.
+const SUSPENDER = "suspender"
+
 // Service manages the listener and handler for an HTTP endpoint.
 type Service struct {
-	err     chan error
-	Handler *Handler
-	stop    chan struct{}
-	Meta    *meta.Config
-	Deployd *deployd.Config
-	Dockerd *docker.Config
-	Config  *Config
-	Storage *storage.Config
+	err       chan error
+	Handler   *Handler
+	stop      chan struct{}
+	done      chan struct{}
+	Meta      *meta.Config
+	Deployd   *deployd.Config
+	Dockerd   *docker.Config
+	Config    *Config
+	Storage   *storage.Config
 	Snapshots *snapshots.Config
+	output    *metrix.OutputHandler
+	scheduler *scheduler
 }
 
 // NewService returns a new instance of Service.
 func NewService(c *meta.Config, one *deployd.Config, doc *docker.Config, f *Config, strg *storage.Config, snp *snapshots.Config) *Service {
 	s := &Service{
-		err:     make(chan error),
-		Meta:    c,
-		Deployd: one,
-		Dockerd: doc,
-		Config:  f,
-		Storage: strg,
+		err:       make(chan error),
+		Meta:      c,
+		Deployd:   one,
+		Dockerd:   doc,
+		Config:    f,
+		Storage:   strg,
 		Snapshots: snp,
 	}
 	s.Handler = NewHandler()
@@ -47,32 +55,64 @@ func (s *Service) Open() error {
 		return nil
 	}
 
+	ledger, err := metrix.OpenBillingLedger(s.billingLedger(), time.Duration(s.Config.MaxBackfill))
+	if err != nil {
+		return err
+	}
+	metrix.Billed = ledger
+
+	if s.output, err = metrix.NewOutputHandler(&s.Config.Outputs); err != nil {
+		return err
+	}
+
+	carton.Suspensions.Grace = time.Duration(s.Config.SuspendGrace)
+	if err = carton.Suspensions.Open(filepath.Join(s.Meta.Dir, carton.DefaultSuspensionsJournal)); err != nil {
+		return err
+	}
+	s.scheduler = newScheduler(s.collectorTimeout())
 	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
 	go s.backgroundLoop()
 	return nil
 }
 
+func (s *Service) billingLedger() string {
+	if s.Config.BillingLedger != "" {
+		return s.Config.BillingLedger
+	}
+	return filepath.Join(s.Meta.Dir, DefaultBillingLedger)
+}
+
+// the collector timeout can't be over the collect interval.
+func (s *Service) collectorTimeout() time.Duration {
+	t, interval := time.Duration(s.Config.CollectorTimeout), time.Duration(s.Config.CollectInterval)
+	if t <= 0 || t > interval {
+		return interval
+	}
+	return t
+}
+
 func (s *Service) backgroundLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(time.Duration(s.Config.CollectInterval))
+	defer ticker.Stop()
 	for {
 		select {
 		case <-s.stop:
 			log.Info("metricsd terminating")
-			break
-		case <-time.After(time.Duration(s.Config.CollectInterval)):
+			return
+		case <-ticker.C:
 			s.runMetricsCollectors()
 		}
 	}
-
 }
 
 func (s *Service) runMetricsCollectors() error {
-	output := &metrix.OutputHandler{
-		ScyllaAddress: s.Meta.Api,
-	}
-
+	output := s.output
 	metrix.MetricsInterval = time.Duration(s.Config.CollectInterval)
+	metrix.Prices.SetPlans(s.Config.RatePlans)
 
-  if s.Deployd.One.Enabled {
+	if s.Deployd.One.Enabled {
 		s.onedCollectors(output)
 	}
 
@@ -87,22 +127,49 @@ func (s *Service) runMetricsCollectors() error {
 	if s.Snapshots.Enabled {
 		s.snapshotsCollectors(output)
 	}
+
+	if s.Config.AutoSuspend {
+		s.suspendAccounts()
+	}
 	return nil
 }
 
+// suspendAccounts enforces the balances of the accounts billed so far, the
+// ones billed by the collections running now are enforced on the next tick.
+func (s *Service) suspendAccounts() {
+	s.scheduler.Run(SUSPENDER, func(expired <-chan struct{}) error {
+		return carton.Suspensions.Enforce()
+	})
+}
+
+// collect schedules a collection of the collector of a region.
+func (s *Service) collect(region string, mh *metrix.MetricHandler, output *metrix.OutputHandler, c metrix.MetricCollector) {
+	s.scheduler.Run(c.Prefix()+"/"+region, func(expired <-chan struct{}) error {
+		return s.Handler.processCollector(mh, output, c, expired)
+	})
+}
+
+// Close stops collecting and waits for the running collections, up to the
+// collector timeout. The outputs and the billing ledger are left open when
+// some are still running, they may still be billing.
 func (s *Service) Close() error {
 	if s.stop == nil {
 		return nil
 	}
 	close(s.stop)
+	<-s.done
 	s.stop = nil
-	return nil
+	if !s.scheduler.Wait(s.collectorTimeout()) {
+		log.Warnf("metricsd closing with collectors still running %v, the billing is left open", s.scheduler.Running())
+		return nil
+	}
+	s.output.Close()
+	return metrix.Billed.Close()
 }
 
 // Err returns a channel for fatal errors that occur on the listener.
 func (s *Service) Err() <-chan error { return s.err }
 
-
 func (s *Service) onedCollectors(output *metrix.OutputHandler) {
 	// One VirtualMachine Metrics collectors
 	 if s.Deployd.One.Enabled {
@@ -118,7 +185,7 @@ func (s *Service) onedCollectors(output *metrix.OutputHandler) {
 			 mh := &metrix.MetricHandler{}
 
 			 for _, collector := range collectors {
-				 go s.Handler.processCollector(mh, output, collector)
+				 s.collect(region.OneZone, mh, output, collector)
 			 }
 		 }
 	 }
@@ -131,13 +198,13 @@ func (s *Service) dockerCollectors(output *metrix.OutputHandler) {
   if s.Dockerd.Docker.Enabled {
  	 for _, region := range s.Dockerd.Docker.Regions {
  		 collectors := map[string]metrix.MetricCollector{
- 			 metrix.DOCKER: &metrix.Swarm{Url: region.SwarmEndPoint, DefaultUnits: map[string]string{metrix.MEMORY_UNIT: region.MemoryUnit, metrix.CPU_UNIT: region.CpuUnit, metrix.DISK_UNIT: region.DiskUnit}},
+ 			 metrix.DOCKER: &metrix.Swarm{Url: region.SwarmEndPoint, Region: region.DockerZone, DefaultUnits: map[string]string{metrix.MEMORY_UNIT: region.MemoryUnit, metrix.CPU_UNIT: region.CpuUnit, metrix.DISK_UNIT: region.DiskUnit}},
  		 }
 
  		 mh := &metrix.MetricHandler{}
 
  		 for _, collector := range collectors {
- 			 go s.Handler.processCollector(mh, output, collector)
+ 			 s.collect(region.DockerZone, mh, output, collector)
  		 }
 
  	 }
@@ -150,6 +217,7 @@ func (s *Service) storageCollectors(output *metrix.OutputHandler) {
  	for _, region := range s.Storage.RgwStorage.Regions {
  		collectors := map[string]metrix.MetricCollector{
  			metrix.CEPHRGW: &metrix.CephRGWStats{Url: region.EndPoint,
+ 				Region: region.Zone,
  				DefaultUnits: map[string]string{metrix.STORAGE_UNIT: region.StorageUnit,  metrix.STORAGE_COST_PER_HOUR: region.CostPerHour},
  				AdminUser: region.AdminUser,
  				MasterKey: s.Meta.MasterKey,
@@ -161,7 +229,7 @@ func (s *Service) storageCollectors(output *metrix.OutputHandler) {
  		mh := &metrix.MetricHandler{}
 
  		for _, collector := range collectors {
- 			go s.Handler.processCollector(mh, output, collector)
+ 			s.collect(region.Zone, mh, output, collector)
  		}
 
  	}
@@ -178,6 +246,6 @@ func (s *Service) snapshotsCollectors(output *metrix.OutputHandler) {
  		mh := &metrix.MetricHandler{}
 
  		for _, collector := range collectors {
- 			go s.Handler.processCollector(mh, output, collector)
+ 			s.collect("", mh, output, collector)
  		}
 }
@@ -63,6 +63,8 @@ func NewConfig() *Config {
 		AdminSecret:    DefaultSecretKey,
 		CPUPeriod:      toml.Duration(DefaultCPUPeriod),
 		CPUQuota:       toml.Duration(DefaultCPUQuota),
+		CpuCostPerHour: "0.1",
+		RamCostPerHour: "0.1",
 	}
 
 	o := rancher.Rancher{
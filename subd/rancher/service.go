@@ -2,7 +2,7 @@ package rancher
 
 import (
 	"fmt"
-	"sync"
+	"path/filepath"
 
 	log "github.com/Sirupsen/logrus"
 	nsq "github.com/crackcomm/nsqueue/consumer"
@@ -20,10 +20,10 @@ const (
 
 // Service manages the listener and handler for an HTTP endpoint.
 type Service struct {
-	wg       sync.WaitGroup
 	err      chan error
 	Handler  *Handler
 	Consumer *nsq.Consumer
+	Requests *carton.ReqConsumer
 	Meta     *meta.Config
 	Rancherd  *Config
 }
@@ -36,14 +36,21 @@ func NewService(c *meta.Config, d *Config) *Service {
 		Rancherd: d,
 	}
 	s.Handler = NewHandler(s.Rancherd)
+	s.Requests = carton.NewReqConsumer(TOPIC, s.Handler.serveNSQ)
 	return s
 }
 
 // Open starts the service
 func (s *Service) Open() error {
+	if err := carton.Ledger.Open(filepath.Join(s.Meta.Dir, carton.DefaultLedgerJournal)); err != nil {
+		return err
+	}
+	if err := carton.Reservations.Open(filepath.Join(s.Meta.Dir, carton.DefaultReservationsJournal)); err != nil {
+		return err
+	}
 	go func() error {
 		log.Info("starting rancherd service")
-		if err := nsq.Register(TOPIC, "engine", maxInFlight, s.processNSQ); err != nil {
+		if err := nsq.Register(TOPIC, "engine", maxInFlight, s.Requests.Process); err != nil {
 			return err
 		}
 		if err := nsq.Connect(s.Meta.NSQd...); err != nil {
@@ -60,28 +67,13 @@ func (s *Service) Open() error {
 	return nil
 }
 
-func (s *Service) processNSQ(msg *nsq.Message) {
-	log.Debugf(TOPIC + "queue received message  :" + string(msg.Body))
-	p, err := carton.NewPayload(msg.Body)
-	if err != nil {
-		return
-	}
-
-	re, err := p.Convert()
-	if err != nil {
-		return
-	}
-	go s.Handler.serveNSQ(re)
-	return
-}
-
 // Close closes the underlying subscribe channel.
 func (s *Service) Close() error {
 	if s.Consumer != nil {
 		s.Consumer.Stop()
 	}
 
-	s.wg.Wait()
+	s.Requests.Wait()
 	return nil
 }
 